@@ -0,0 +1,336 @@
+package gah
+
+import (
+	"math"
+	"math/rand"
+
+	opensimplex "github.com/ojrac/opensimplex-go"
+)
+
+// Noise is a seeded base noise that can be layered by CoherentNoise
+// all built-in implementations are deterministic from their seed and return values within [-1, 1]
+type Noise interface {
+	Eval2(x, y float64) float64
+	Eval3(x, y, z float64) float64
+	Eval4(x, y, z, w float64) float64
+}
+
+var (
+	_ Noise = (*OpenSimplexNoise)(nil)
+	_ Noise = (*PerlinNoise)(nil)
+	_ Noise = (*ValueNoise)(nil)
+	_ Noise = (*WorleyNoise)(nil)
+	_ Noise = (*WhiteNoise)(nil)
+)
+
+// paramSigner is implemented by base noises that can contribute to the signature of the CoherentNoise layering them
+type paramSigner interface {
+	GetParamSignature() []byte
+}
+
+// noiseSignature returns the signature of a built-in base noise from its type tag and seed
+func noiseSignature(tag string, seed int64) (signature []byte) {
+	signature = make([]byte, 0, 16+len(tag))
+	signature = append(signature, IntToBytes(len(tag))...)
+	signature = append(signature, tag...)
+	signature = append(signature, IntToBytes(int(seed))...)
+	return signature
+}
+
+// OpenSimplexNoise is opensimplex noise, the default base noise of CoherentNoise
+// Eval2 and Eval4 are the ones of the opensimplex package, Eval3 differs slightly where the package drops vertices, see Eval3Deriv
+type OpenSimplexNoise struct {
+	opensimplex.Noise
+	seed int64
+	perm *openSimplexPerm
+}
+
+// NewOpenSimplexNoise returns opensimplex noise with the given seed
+func NewOpenSimplexNoise(seed int64) *OpenSimplexNoise {
+	return &OpenSimplexNoise{opensimplex.New(seed), seed, newOpenSimplexPerm(seed)}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (osn *OpenSimplexNoise) GetParamSignature() []byte {
+	return noiseSignature("opensimplex", osn.seed)
+}
+
+// latticePerm is a seeded permutation of [0, 255], repeated once so that chained lookups never need wrapping
+type latticePerm [512]int
+
+// newLatticePerm returns the permutation for the given seed
+func newLatticePerm(seed int64) *latticePerm {
+	var p latticePerm
+	perm := rand.New(rand.NewSource(seed)).Perm(256)
+	for i := range p {
+		p[i] = perm[i&255]
+	}
+	return &p
+}
+
+// hash returns the hash in [0, 255] of the lattice point, only the first n coordinates are used
+func (p *latticePerm) hash(cell [4]int, n int) int {
+	h := 0
+	for i := 0; i < n; i++ {
+		h = p[h+cell[i]&255]
+	}
+	return h
+}
+
+// fade is the quintic interpolation curve of improved perlin noise, its first and second derivative are 0 at 0 and 1
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+// latticeEval interpolates the corner values of the lattice cell containing p using the fade curve, only the first n coordinates are used
+// corner receives the hash of every corner and the offset of p from that corner
+func latticeEval(perm *latticePerm, p [4]float64, n int, corner func(h int, d [4]float64) float64) float64 {
+	var cell [4]int
+	var f, u [4]float64
+	for i := 0; i < n; i++ {
+		fl := math.Floor(p[i])
+		cell[i] = int(fl)
+		f[i] = p[i] - fl
+		u[i] = fade(f[i])
+	}
+	sum := 0.0
+	for c := 0; c < 1<<n; c++ {
+		var cc [4]int
+		var d [4]float64
+		weight := 1.0
+		for i := 0; i < n; i++ {
+			if c&(1<<i) == 0 {
+				cc[i], d[i] = cell[i], f[i]
+				weight *= 1 - u[i]
+			} else {
+				cc[i], d[i] = cell[i]+1, f[i]-1
+				weight *= u[i]
+			}
+		}
+		sum += weight * corner(perm.hash(cc, n), d)
+	}
+	return sum
+}
+
+// PerlinNoise is classic improved perlin gradient noise
+type PerlinNoise struct {
+	seed int64
+	perm *latticePerm
+}
+
+// NewPerlinNoise returns perlin noise with the given seed
+func NewPerlinNoise(seed int64) *PerlinNoise {
+	return &PerlinNoise{seed, newLatticePerm(seed)}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (pn *PerlinNoise) GetParamSignature() []byte {
+	return noiseSignature("perlin", pn.seed)
+}
+
+// perlinGradients2 are the 8 unit gradients of 2D perlin noise
+var perlinGradients2 = [8][2]float64{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1},
+	{math.Sqrt2 / 2, math.Sqrt2 / 2}, {-math.Sqrt2 / 2, math.Sqrt2 / 2}, {math.Sqrt2 / 2, -math.Sqrt2 / 2}, {-math.Sqrt2 / 2, -math.Sqrt2 / 2},
+}
+
+// perlinGradient returns the gradient selected by h for n dimensions
+// 3D uses the 12 cube edge directions of improved perlin noise, 4D the 32 directions with one zero and three unit components
+func perlinGradient(h int, n int) (g [4]float64) {
+	switch n {
+	case 2:
+		g2 := perlinGradients2[h&7]
+		return [4]float64{g2[0], g2[1]}
+	case 3:
+		h %= 12
+		zeroAxis := h >> 2
+		sign := [2]float64{1 - 2*float64(h&1), 1 - 2*float64(h>>1&1)}
+		for i, s := 0, 0; i < 3; i++ {
+			if i != 2-zeroAxis {
+				g[i] = sign[s]
+				s++
+			}
+		}
+		return g
+	}
+	h &= 31
+	zeroAxis := h >> 3
+	for i, s := 0, 0; i < 4; i++ {
+		if i != zeroAxis {
+			g[i] = 1 - 2*float64(h>>s&1)
+			s++
+		}
+	}
+	return g
+}
+
+// perlinNormalization scales n dimensional perlin noise to [-1, 1], the maximum of perlin noise is sqrt(n)/2 times the gradient length
+var perlinNormalization = [5]float64{0, 0, math.Sqrt2, 2 / (math.Sqrt2 * math.Sqrt(3)), 1 / math.Sqrt(3)}
+
+// eval returns the noise at p, only the first n coordinates are used
+func (pn *PerlinNoise) eval(p [4]float64, n int) float64 {
+	return perlinNormalization[n] * latticeEval(pn.perm, p, n, func(h int, d [4]float64) float64 {
+		g := perlinGradient(h, n)
+		return g[0]*d[0] + g[1]*d[1] + g[2]*d[2] + g[3]*d[3]
+	})
+}
+
+// Eval2 returns the noise at the given position
+func (pn *PerlinNoise) Eval2(x, y float64) float64 {
+	return pn.eval([4]float64{x, y}, 2)
+}
+
+// Eval3 returns the noise at the given position
+func (pn *PerlinNoise) Eval3(x, y, z float64) float64 {
+	return pn.eval([4]float64{x, y, z}, 3)
+}
+
+// Eval4 returns the noise at the given position
+func (pn *PerlinNoise) Eval4(x, y, z, w float64) float64 {
+	return pn.eval([4]float64{x, y, z, w}, 4)
+}
+
+// ValueNoise interpolates random values placed on the integer lattice
+type ValueNoise struct {
+	seed int64
+	perm *latticePerm
+}
+
+// NewValueNoise returns value noise with the given seed
+func NewValueNoise(seed int64) *ValueNoise {
+	return &ValueNoise{seed, newLatticePerm(seed)}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (vn *ValueNoise) GetParamSignature() []byte {
+	return noiseSignature("value", vn.seed)
+}
+
+// eval returns the noise at p, only the first n coordinates are used
+func (vn *ValueNoise) eval(p [4]float64, n int) float64 {
+	return latticeEval(vn.perm, p, n, func(h int, d [4]float64) float64 {
+		return float64(h)/127.5 - 1
+	})
+}
+
+// Eval2 returns the noise at the given position
+func (vn *ValueNoise) Eval2(x, y float64) float64 {
+	return vn.eval([4]float64{x, y}, 2)
+}
+
+// Eval3 returns the noise at the given position
+func (vn *ValueNoise) Eval3(x, y, z float64) float64 {
+	return vn.eval([4]float64{x, y, z}, 3)
+}
+
+// Eval4 returns the noise at the given position
+func (vn *ValueNoise) Eval4(x, y, z, w float64) float64 {
+	return vn.eval([4]float64{x, y, z, w}, 4)
+}
+
+// WorleyNoise is cellular noise, the distance to the closest of the feature points that are jittered inside of every lattice cell
+// the distance is measured in cell units, clamped to 1 and remapped to [-1, 1], so -1 is right on a feature point
+type WorleyNoise struct {
+	seed int64
+}
+
+// NewWorleyNoise returns worley noise with the given seed
+func NewWorleyNoise(seed int64) *WorleyNoise {
+	return &WorleyNoise{seed}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (wn *WorleyNoise) GetParamSignature() []byte {
+	return noiseSignature("worley", wn.seed)
+}
+
+// featurePoint returns the offset of the feature point inside of the lattice cell, only the first n coordinates are used
+func (wn *WorleyNoise) featurePoint(cell [4]int, n int) (fp [4]float64) {
+	h := HashInts(uint64(wn.seed), cell[:n]...)
+	for i := 0; i < n; i++ {
+		fp[i] = HashToUnit(HashInts(h, i))
+	}
+	return fp
+}
+
+// nearest returns the distance from p to the closest feature point and the offset of p from it, only the first n coordinates are used
+func (wn *WorleyNoise) nearest(p [4]float64, n int) (dist float64, delta [4]float64) {
+	var cell [4]int
+	var f [4]float64
+	for i := 0; i < n; i++ {
+		fl := math.Floor(p[i])
+		cell[i] = int(fl)
+		f[i] = p[i] - fl
+	}
+	// the closest feature point is always within the 3^n neighbourhood, because the own cell has one
+	best := math.Inf(1)
+	neighbours := int(math.Pow(3, float64(n)))
+	for c := 0; c < neighbours; c++ {
+		var cc [4]int
+		var d [4]float64
+		for i, r := 0, c; i < n; i, r = i+1, r/3 {
+			cc[i] = cell[i] + r%3 - 1
+		}
+		fp := wn.featurePoint(cc, n)
+		dist2 := 0.0
+		for i := 0; i < n; i++ {
+			d[i] = f[i] - float64(cc[i]-cell[i]) - fp[i]
+			dist2 += d[i] * d[i]
+		}
+		if dist2 < best {
+			best, delta = dist2, d
+		}
+	}
+	return math.Sqrt(best), delta
+}
+
+// eval returns the noise at p, only the first n coordinates are used
+func (wn *WorleyNoise) eval(p [4]float64, n int) float64 {
+	dist, _ := wn.nearest(p, n)
+	return 2*math.Min(dist, 1) - 1
+}
+
+// Eval2 returns the noise at the given position
+func (wn *WorleyNoise) Eval2(x, y float64) float64 {
+	return wn.eval([4]float64{x, y}, 2)
+}
+
+// Eval3 returns the noise at the given position
+func (wn *WorleyNoise) Eval3(x, y, z float64) float64 {
+	return wn.eval([4]float64{x, y, z}, 3)
+}
+
+// Eval4 returns the noise at the given position
+func (wn *WorleyNoise) Eval4(x, y, z, w float64) float64 {
+	return wn.eval([4]float64{x, y, z, w}, 4)
+}
+
+// WhiteNoise is uncorrelated hash noise, every cell of the integer lattice gets its own uniformly distributed value
+type WhiteNoise struct {
+	seed int64
+}
+
+// NewWhiteNoise returns white noise with the given seed
+func NewWhiteNoise(seed int64) *WhiteNoise {
+	return &WhiteNoise{seed}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (wn *WhiteNoise) GetParamSignature() []byte {
+	return noiseSignature("white", wn.seed)
+}
+
+// Eval2 returns the noise at the given position
+func (wn *WhiteNoise) Eval2(x, y float64) float64 {
+	return 2*HashToUnit(HashInts(uint64(wn.seed), int(math.Floor(x)), int(math.Floor(y)))) - 1
+}
+
+// Eval3 returns the noise at the given position
+func (wn *WhiteNoise) Eval3(x, y, z float64) float64 {
+	return 2*HashToUnit(HashInts(uint64(wn.seed), int(math.Floor(x)), int(math.Floor(y)), int(math.Floor(z)))) - 1
+}
+
+// Eval4 returns the noise at the given position
+func (wn *WhiteNoise) Eval4(x, y, z, w float64) float64 {
+	return 2*HashToUnit(HashInts(uint64(wn.seed), int(math.Floor(x)), int(math.Floor(y)), int(math.Floor(z)), int(math.Floor(w)))) - 1
+}
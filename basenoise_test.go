@@ -0,0 +1,181 @@
+package gah
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	opensimplex "github.com/ojrac/opensimplex-go"
+)
+
+// baseNoises lists a constructor for every built-in base noise, for the table driven tests
+var baseNoises = []struct {
+	name       string
+	new        func(seed int64) Noise
+	continuous bool
+}{
+	{"opensimplex", func(seed int64) Noise { return NewOpenSimplexNoise(seed) }, true},
+	{"perlin", func(seed int64) Noise { return NewPerlinNoise(seed) }, true},
+	{"value", func(seed int64) Noise { return NewValueNoise(seed) }, true},
+	{"worley", func(seed int64) Noise { return NewWorleyNoise(seed) }, true},
+	{"white", func(seed int64) Noise { return NewWhiteNoise(seed) }, false},
+}
+
+// noiseSamples evaluates the noise in 2, 3 and 4 dimensions at a spread of positions, including negative ones
+func noiseSamples(n Noise) []float64 {
+	samples := []float64{}
+	for x := -20.0; x < 20; x += 0.37 {
+		for y := -20.0; y < 20; y += 1.91 {
+			samples = append(samples, n.Eval2(x, y), n.Eval3(x, y, x-y), n.Eval4(x, y, y-x, 0.5*x))
+		}
+	}
+	return samples
+}
+
+func TestBaseNoiseDeterministicRange(t *testing.T) {
+	for _, bn := range baseNoises {
+		a, b, other := noiseSamples(bn.new(5)), noiseSamples(bn.new(5)), noiseSamples(bn.new(6))
+		differs := false
+		minV, maxV := math.Inf(1), math.Inf(-1)
+		for i := range a {
+			if a[i] != b[i] {
+				t.Fatalf("%s: sample %d differs between two noises with the same seed", bn.name, i)
+			}
+			if a[i] < -1 || a[i] > 1 || math.IsNaN(a[i]) {
+				t.Fatalf("%s: sample %d is %v, outside of [-1, 1]", bn.name, i, a[i])
+			}
+			differs = differs || a[i] != other[i]
+			minV, maxV = math.Min(minV, a[i]), math.Max(maxV, a[i])
+		}
+		if !differs {
+			t.Errorf("%s: different seeds give the same noise", bn.name)
+		}
+		if minV > -0.4 || maxV < 0.4 {
+			t.Errorf("%s: samples only span [%v, %v]", bn.name, minV, maxV)
+		}
+	}
+}
+
+func TestBaseNoiseContinuous(t *testing.T) {
+	const eps = 1e-4
+	for _, bn := range baseNoises {
+		if !bn.continuous {
+			continue
+		}
+		n := bn.new(11)
+		for x := -5.0; x < 5; x += 0.173 {
+			for y := -5.0; y < 5; y += 0.219 {
+				if d := math.Abs(n.Eval2(x, y) - n.Eval2(x+eps, y-eps)); d > 0.01 {
+					t.Fatalf("%s: 2D noise jumps by %v near (%v, %v)", bn.name, d, x, y)
+				}
+				if d := math.Abs(n.Eval3(x, y, 0.3) - n.Eval3(x+eps, y, 0.3-eps)); d > 0.01 {
+					t.Fatalf("%s: 3D noise jumps by %v near (%v, %v, 0.3)", bn.name, d, x, y)
+				}
+				if d := math.Abs(n.Eval4(x, y, 0.3, -0.7) - n.Eval4(x, y+eps, 0.3, -0.7+eps)); d > 0.01 {
+					t.Fatalf("%s: 4D noise jumps by %v near (%v, %v, 0.3, -0.7)", bn.name, d, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestBaseNoiseLattice(t *testing.T) {
+	pn, wn, white := NewPerlinNoise(3), NewWorleyNoise(3), NewWhiteNoise(3)
+	for x := -3; x <= 3; x++ {
+		for y := -3; y <= 3; y++ {
+			xf, yf := float64(x), float64(y)
+			// gradient noise is zero on the lattice
+			if v := pn.Eval2(xf, yf); math.Abs(v) > 1e-12 {
+				t.Errorf("perlin noise at lattice point (%d, %d) is %v, want 0", x, y, v)
+			}
+			if v := pn.Eval3(xf, yf, 2); math.Abs(v) > 1e-12 {
+				t.Errorf("perlin noise at lattice point (%d, %d, 2) is %v, want 0", x, y, v)
+			}
+			// worley noise is -1 right on the feature point of a cell
+			fp := wn.featurePoint([4]int{x, y}, 2)
+			if v := wn.Eval2(xf+fp[0], yf+fp[1]); v > -1+1e-9 {
+				t.Errorf("worley noise at the feature point of cell (%d, %d) is %v, want -1", x, y, v)
+			}
+			// white noise is constant inside of a cell
+			if a, b := white.Eval2(xf+0.01, yf+0.01), white.Eval2(xf+0.99, yf+0.5); a != b {
+				t.Errorf("white noise varies inside of cell (%d, %d): %v and %v", x, y, a, b)
+			}
+		}
+	}
+}
+
+func TestBaseNoiseSignatures(t *testing.T) {
+	signatures := [][]byte{}
+	for _, bn := range baseNoises {
+		for _, seed := range []int64{1, 2} {
+			signature := bn.new(seed).(paramSigner).GetParamSignature()
+			if !bytes.Equal(signature, bn.new(seed).(paramSigner).GetParamSignature()) {
+				t.Errorf("%s: signature differs between noises with the same seed", bn.name)
+			}
+			for _, other := range signatures {
+				if bytes.Equal(signature, other) {
+					t.Errorf("%s seed %d: signature equals the one of another noise", bn.name, seed)
+				}
+			}
+			signatures = append(signatures, signature)
+		}
+	}
+}
+
+func TestCoherentNoiseWithBase(t *testing.T) {
+	// NewCoherentNoise layers the same opensimplex noise as before base noises were pluggable
+	cnoise := NewCoherentNoise(9, 0.02, 4, 2, 0.5)
+	legacy := &CoherentNoise{Noise: opensimplex.New(9), Scale: 0.02, Octaves: 4, Lacunarity: 2, Persistence: 0.5}
+	for x := 0.0; x < 100; x += 6.7 {
+		for y := 0.0; y < 100; y += 5.3 {
+			if got, want := cnoise.Eval2(x, y), legacy.Eval2(x, y); got != want {
+				t.Fatalf("Eval2(%v, %v) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+	if bytes.Equal(cnoise.GetParamSignature(), NewCoherentNoise(10, 0.02, 4, 2, 0.5).GetParamSignature()) {
+		t.Errorf("different seeds give the same signature")
+	}
+	if !bytes.Equal(cnoise.GetParamSignature(), NewCoherentNoiseWithBase(NewOpenSimplexNoise(9), 0.02, 4, 2, 0.5).GetParamSignature()) {
+		t.Errorf("NewCoherentNoise differs from NewCoherentNoiseWithBase with opensimplex noise")
+	}
+	for _, bn := range baseNoises {
+		layered := NewCoherentNoiseWithBase(bn.new(9), 0.1, 4, 2, 0.5)
+		for i, v := range noiseSamples(layered) {
+			dims := 2 + i%3 // noiseSamples cycles through Eval2, Eval3 and Eval4
+			if emin, emax := layered.GetEvalRangeN(dims); v < emin || v > emax {
+				t.Fatalf("%s: layered %dD sample %v is outside of [%v, %v]", bn.name, dims, v, emin, emax)
+			}
+		}
+		if bytes.Equal(layered.GetParamSignature(), NewCoherentNoiseWithBase(bn.new(10), 0.1, 4, 2, 0.5).GetParamSignature()) {
+			t.Errorf("%s: layering a different seed gives the same signature", bn.name)
+		}
+	}
+}
+
+func TestHashInts(t *testing.T) {
+	seen := map[uint64]bool{}
+	for seed := uint64(0); seed < 4; seed++ {
+		for x := -10; x < 10; x++ {
+			for y := -10; y < 10; y++ {
+				h := HashInts(seed, x, y)
+				if h != HashInts(seed, x, y) {
+					t.Fatalf("HashInts(%d, %d, %d) is not deterministic", seed, x, y)
+				}
+				if seen[h] {
+					t.Fatalf("HashInts(%d, %d, %d) collides", seed, x, y)
+				}
+				seen[h] = true
+				if u := HashToUnit(h); u < 0 || u >= 1 {
+					t.Fatalf("HashToUnit(%d) = %v, outside of [0, 1)", h, u)
+				}
+			}
+		}
+	}
+	if HashInts(1, 2, 3) == HashInts(1, 3, 2) {
+		t.Errorf("HashInts does not depend on the order of the values")
+	}
+	if HashToUnit(math.MaxUint64) >= 1 || HashToUnit(0) != 0 {
+		t.Errorf("HashToUnit is not within [0, 1)")
+	}
+}
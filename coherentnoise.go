@@ -1,21 +1,143 @@
 package gah
 
 import (
-	opensimplex "github.com/ojrac/opensimplex-go"
+	"math"
 )
 
-// CoherentNoise provides automatic layering of opensimplex noise using parameters
+// FractalMode selects how the octaves of a CoherentNoise are combined
+type FractalMode int
+
+const (
+	FractalFBM           FractalMode = iota // plain fractal brownian motion, the weighted average of all octaves
+	FractalBillow                           // billowy clouds, the absolute value of every octave remapped to [-1, 1]
+	FractalTurbulence                       // the weighted average of the absolute value of every octave
+	FractalRidged                           // ridged multifractal, sharp ridges where the octaves cross zero, detail is damped in the valleys
+	FractalHybridMulti                      // hybrid multifractal, smooth valleys and rough peaks, every octave is weighted by the ones before it
+	FractalHeteroTerrain                    // heterogeneous terrain, every octave is scaled by the height accumulated so far
+)
+
+// FractalGain determines how quickly the valleys of ridged and hybrid multifractals lose their detail, higher gives more detail
+const FractalGain = 2.0
+
+// fractalAccumulator combines the octaves of a CoherentNoise according to its FractalMode
+// every mode is normalized by the total amplitude, so the result stays within the range given by CoherentNoise.GetEvalRange
+// alongside the value it accumulates the partial derivatives using the chain rule, if the octaves provide theirs
+type fractalAccumulator struct {
+	mode    FractalMode
+	sum     float64
+	maxAmp  float64
+	weight  float64 // weight of the next octave for the multifractal modes
+	deriv   [3]float64
+	dweight [3]float64
+}
+
+// newFractalAccumulator returns an empty accumulator for the given mode
+func newFractalAccumulator(mode FractalMode) fractalAccumulator {
+	return fractalAccumulator{mode: mode, weight: 1}
+}
+
+// add adds the next octave with the noise sample n in [-1, 1] and its amplitude amp
+func (acc *fractalAccumulator) add(n float64, amp float64) {
+	acc.addDeriv(n, [3]float64{}, amp)
+}
+
+// addDeriv works as add does, but also accumulates the partial derivatives dn of the noise sample
+func (acc *fractalAccumulator) addDeriv(n float64, dn [3]float64, amp float64) {
+	sign := 1.0
+	if n < 0 {
+		sign = -1
+	}
+	var signal float64
+	var dsignal [3]float64
+	switch acc.mode {
+	case FractalBillow:
+		signal = 2*math.Abs(n) - 1
+		for i := range dn {
+			dsignal[i] = 2 * sign * dn[i]
+		}
+	case FractalTurbulence:
+		signal = math.Abs(n)
+		for i := range dn {
+			dsignal[i] = sign * dn[i]
+		}
+	case FractalRidged:
+		ridge := 1 - math.Abs(n)
+		signal = ridge * ridge * acc.weight
+		for i := range dn {
+			dsignal[i] = 2*ridge*-sign*dn[i]*acc.weight + ridge*ridge*acc.dweight[i]
+		}
+		acc.updateWeight(signal, dsignal)
+	case FractalHybridMulti:
+		signal = (n + 1) / 2 * acc.weight
+		for i := range dn {
+			dsignal[i] = dn[i]/2*acc.weight + (n+1)/2*acc.dweight[i]
+		}
+		acc.updateWeight(signal, dsignal)
+	case FractalHeteroTerrain:
+		signal = (n + 1) / 2
+		for i := range dn {
+			dsignal[i] = dn[i] / 2
+		}
+		if acc.maxAmp > 0 {
+			height := acc.sum / acc.maxAmp // height so far, in [0, 1]
+			for i := range dn {
+				dsignal[i] = dsignal[i]*height + signal*acc.deriv[i]/acc.maxAmp
+			}
+			signal *= height
+		}
+	default:
+		signal, dsignal = n, dn
+	}
+	acc.sum += signal * amp
+	for i := range dsignal {
+		acc.deriv[i] += dsignal[i] * amp
+	}
+	acc.maxAmp += amp
+}
+
+// updateWeight sets the weight of the next octave for the multifractal modes from the signal of the current one
+func (acc *fractalAccumulator) updateWeight(signal float64, dsignal [3]float64) {
+	acc.weight = signal * FractalGain
+	if acc.weight <= 0 || acc.weight >= 1 {
+		acc.weight = Clamp(acc.weight, 0, 1)
+		acc.dweight = [3]float64{}
+		return
+	}
+	for i := range dsignal {
+		acc.dweight[i] = dsignal[i] * FractalGain
+	}
+}
+
+// result returns the combined value of all octaves added so far
+func (acc *fractalAccumulator) result() float64 {
+	return acc.sum / acc.maxAmp
+}
+
+// resultDeriv returns the partial derivatives of the combined value
+func (acc *fractalAccumulator) resultDeriv() [3]float64 {
+	return [3]float64{acc.deriv[0] / acc.maxAmp, acc.deriv[1] / acc.maxAmp, acc.deriv[2] / acc.maxAmp}
+}
+
+// CoherentNoise provides automatic layering of a base noise using parameters
 type CoherentNoise struct {
-	Noise       opensimplex.Noise // open simplex noise generator
-	Scale       float64           // number that determines at what distance to view the noisemap, smaller is closer
-	Octaves     int               // the number of levels of detail you want you perlin noise to have, higher gives more possible detail
-	Lacunarity  float64           // number that determines how much detail is added or removed at each octave (adjusts frequency), higher gives less blending of octaves
-	Persistence float64           // number that determines how much each octave contributes to the overall shape (adjusts amplitude), higher makes rougher
+	Noise       Noise       // base noise generator, e.g. OpenSimplexNoise or PerlinNoise
+	Scale       float64     // number that determines at what distance to view the noisemap, smaller is closer
+	Octaves     int         // the number of levels of detail you want you perlin noise to have, higher gives more possible detail
+	Lacunarity  float64     // number that determines how much detail is added or removed at each octave (adjusts frequency), higher gives less blending of octaves
+	Persistence float64     // number that determines how much each octave contributes to the overall shape (adjusts amplitude), higher makes rougher
+	Mode        FractalMode // how the octaves are combined, FractalFBM by default
+	Output      NoiseOutput // how the layered noise is mapped to the output range, NoiseOutputRaw by default
+	calibration *noiseCalibrationCache
 }
 
-// NewCoherentNoise returns a CoherentNoise structure with the given parameters
+// NewCoherentNoise returns a CoherentNoise structure with the given parameters, layering opensimplex noise with the given seed
 func NewCoherentNoise(seed int64, scale float64, octaves int, lacunarity float64, persistence float64) *CoherentNoise {
-	return &CoherentNoise{opensimplex.New(seed), scale, octaves, lacunarity, persistence}
+	return NewCoherentNoiseWithBase(NewOpenSimplexNoise(seed), scale, octaves, lacunarity, persistence)
+}
+
+// NewCoherentNoiseWithBase returns a CoherentNoise structure with the given parameters, layering the given base noise
+func NewCoherentNoiseWithBase(noise Noise, scale float64, octaves int, lacunarity float64, persistence float64) *CoherentNoise {
+	return &CoherentNoise{noise, scale, octaves, lacunarity, persistence, FractalFBM, NoiseOutputRaw, &noiseCalibrationCache{}}
 }
 
 // GetParamSignature returns a byte slice containing all relevant unique parameters
@@ -24,80 +146,109 @@ func (cnoise *CoherentNoise) GetParamSignature() (signature []byte) {
 	signature = append(signature, IntToBytes(cnoise.Octaves)...)
 	signature = append(signature, Float64ToBytes(cnoise.Lacunarity)...)
 	signature = append(signature, Float64ToBytes(cnoise.Persistence)...)
+	signature = append(signature, IntToBytes(int(cnoise.Mode))...)
+	signature = append(signature, IntToBytes(int(cnoise.Output))...)
+	if noise, ok := cnoise.Noise.(paramSigner); ok {
+		signature = append(signature, noise.GetParamSignature()...)
+	}
 	return signature
 }
 
-// GetEvalRange returns the min and max values that can be expected from the Eval2
+// GetEvalRange returns the min and max values that can be expected from the Eval2, see GetEvalRangeN
 func (cnoise *CoherentNoise) GetEvalRange() (outMin float64, outMax float64) {
-	return -1, 1
+	return cnoise.GetEvalRangeN(2)
 }
 
-//TODO any way to reduce redundancy here?
+// nominalRange returns the range that the fractal mode can reach in theory, the layered noise rarely gets close to it
+func (cnoise *CoherentNoise) nominalRange() (outMin float64, outMax float64) {
+	switch cnoise.Mode {
+	case FractalTurbulence, FractalRidged, FractalHybridMulti, FractalHeteroTerrain:
+		return 0, 1
+	}
+	return -1, 1
+}
 
-// eval1 works as Eval1 does on opensimplex.noise but applies layering through the CoherentNoise parameters
-func (cnoise *CoherentNoise) Eval1(x float64) float64 {
-	var maxAmp float64 = 0
+// layer combines successively smaller, higher-frequency octaves of the base noise
+// sample returns the base noise for the octave with the given frequency
+func (cnoise *CoherentNoise) layer(sample func(freq float64) float64) float64 {
+	var acc fractalAccumulator = newFractalAccumulator(cnoise.Mode)
 	var amp float64 = 1
 	var freq float64 = cnoise.Scale
-	var noiseSample float64 = 0
-	// add successively smaller, higher-frequency terms
 	for i := 0; i < cnoise.Octaves; i++ {
-		noiseSample += cnoise.Noise.Eval2(x*freq, 0) * amp
-		maxAmp += amp
+		acc.add(sample(freq), amp)
 		amp *= cnoise.Persistence
 		freq *= cnoise.Lacunarity
 	}
-	noiseSample /= maxAmp // take the average value of the iterations
-	return noiseSample
+	return acc.result()
+}
+
+// evalRaw returns the layered base noise without applying the output mode, using the first dims coordinates of p
+func (cnoise *CoherentNoise) evalRaw(p [4]float64, dims int) float64 {
+	return cnoise.layer(func(freq float64) float64 {
+		switch dims {
+		case 1:
+			return cnoise.Noise.Eval2(p[0]*freq, 0)
+		case 2:
+			return cnoise.Noise.Eval2(p[0]*freq, p[1]*freq)
+		case 3:
+			return cnoise.Noise.Eval3(p[0]*freq, p[1]*freq, p[2]*freq)
+		}
+		return cnoise.Noise.Eval4(p[0]*freq, p[1]*freq, p[2]*freq, p[3]*freq)
+	})
+}
+
+// Eval1 works as Eval2 does on the base noise with y = 0 but applies layering through the CoherentNoise parameters
+func (cnoise *CoherentNoise) Eval1(x float64) float64 {
+	return cnoise.output(cnoise.evalRaw([4]float64{x}, 1), 1)
 }
 
-// eval2 works as Eval2 does on opensimplex.noise but applies layering through the CoherentNoise parameters
+// Eval2 works as Eval2 does on the base noise but applies layering through the CoherentNoise parameters
 func (cnoise *CoherentNoise) Eval2(x, y float64) float64 {
-	var maxAmp float64 = 0
-	var amp float64 = 1
-	var freq float64 = cnoise.Scale
-	var noiseSample float64 = 0
-	// add successively smaller, higher-frequency terms
-	for i := 0; i < cnoise.Octaves; i++ {
-		noiseSample += cnoise.Noise.Eval2(x*freq, y*freq) * amp
-		maxAmp += amp
-		amp *= cnoise.Persistence
-		freq *= cnoise.Lacunarity
-	}
-	noiseSample /= maxAmp // take the average value of the iterations
-	return noiseSample
+	return cnoise.output(cnoise.evalRaw([4]float64{x, y}, 2), 2)
 }
 
-// eval3 works as Eval3 does on opensimplex.noise but applies layering through the CoherentNoise parameters
+// Eval3 works as Eval3 does on the base noise but applies layering through the CoherentNoise parameters
 func (cnoise *CoherentNoise) Eval3(x, y, z float64) float64 {
-	var maxAmp float64 = 0
-	var amp float64 = 1
-	var freq float64 = cnoise.Scale
-	var noiseSample float64 = 0
-	// add successively smaller, higher-frequency terms
-	for i := 0; i < cnoise.Octaves; i++ {
-		noiseSample += cnoise.Noise.Eval3(x*freq, y*freq, z*freq) * amp
-		maxAmp += amp
-		amp *= cnoise.Persistence
-		freq *= cnoise.Lacunarity
-	}
-	noiseSample /= maxAmp // take the average value of the iterations
-	return noiseSample
+	return cnoise.output(cnoise.evalRaw([4]float64{x, y, z}, 3), 3)
 }
 
-// eval4 works as Eval4 does on opensimplex.noise but applies layering through the CoherentNoise parameters
+// Eval4 works as Eval4 does on the base noise but applies layering through the CoherentNoise parameters
 func (cnoise *CoherentNoise) Eval4(x, y, z, w float64) float64 {
-	var maxAmp float64 = 0
+	return cnoise.output(cnoise.evalRaw([4]float64{x, y, z, w}, 4), 4)
+}
+
+// evalRow2 fills the row with Eval2 at y, pixel ix of the row lies at x0 + ix*dx
+// instead of layering every pixel on its own, it evaluates each octave across the whole row before moving on to the next one
+func (cnoise *CoherentNoise) evalRow2(row []float64, x0 float64, y float64, dx float64) {
+	accs := make([]fractalAccumulator, len(row))
+	for ix := range accs {
+		accs[ix] = newFractalAccumulator(cnoise.Mode)
+	}
 	var amp float64 = 1
 	var freq float64 = cnoise.Scale
-	var noiseSample float64 = 0
-	// add successively smaller, higher-frequency terms
+	noise := cnoise.Noise
 	for i := 0; i < cnoise.Octaves; i++ {
-		noiseSample += cnoise.Noise.Eval4(x*freq, y*freq, z*freq, w*freq) * amp
-		maxAmp += amp
+		fy := y * freq
+		for ix := range accs {
+			accs[ix].add(noise.Eval2((x0+float64(ix)*dx)*freq, fy), amp)
+		}
 		amp *= cnoise.Persistence
 		freq *= cnoise.Lacunarity
 	}
-	noiseSample /= maxAmp // take the average value of the iterations
-	return noiseSample
+	q := cnoise.quantiles(2) // looked up once for the whole row
+	for ix := range row {
+		row[ix], _ = cnoise.mapOutput(accs[ix].result(), q)
+	}
+}
+
+// EvalGrid2 fills dst with Eval2 sampled on a w x h grid starting at (x0, y0) with a spacing of dx and dy, in row major order
+// dst has to hold at least w*h values, the grid is evaluated one octave per row at a time, which gives the same values as calling Eval2 for every pixel
+func (cnoise *CoherentNoise) EvalGrid2(dst []float64, x0 float64, y0 float64, dx float64, dy float64, w int, h int) {
+	evalGrid2(cnoise.evalRow2, dst, x0, y0, dx, dy, w, h, 1)
+}
+
+// EvalGrid2Parallel works as EvalGrid2 does, but spreads the rows over the given number of goroutines, <= 0 for one per CPU
+// the base noise has to be safe for concurrent use, which all built-in base noises are
+func (cnoise *CoherentNoise) EvalGrid2Parallel(dst []float64, x0 float64, y0 float64, dx float64, dy float64, w int, h int, workers int) {
+	evalGrid2(cnoise.evalRow2, dst, x0, y0, dx, dy, w, h, workers)
 }
@@ -0,0 +1,103 @@
+package gah
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// fractalModes lists all modes with their name, for the table driven tests
+var fractalModes = []struct {
+	name string
+	mode FractalMode
+}{
+	{"fbm", FractalFBM},
+	{"billow", FractalBillow},
+	{"turbulence", FractalTurbulence},
+	{"ridged", FractalRidged},
+	{"hybrid", FractalHybridMulti},
+	{"hetero", FractalHeteroTerrain},
+}
+
+func TestFractalAccumulator(t *testing.T) {
+	// two octaves with the samples 0.5 and -0.5 and the amplitudes 1 and 0.5
+	want := map[FractalMode]float64{
+		FractalFBM:           (0.5 - 0.25) / 1.5,
+		FractalBillow:        0,
+		FractalTurbulence:    (0.5 + 0.25) / 1.5,
+		FractalRidged:        (0.25 + 0.125*0.5) / 1.5,
+		FractalHybridMulti:   (0.75 + 0.25*0.5) / 1.5,
+		FractalHeteroTerrain: (0.75 + 0.25*0.75*0.5) / 1.5,
+	}
+	for _, m := range fractalModes {
+		acc := newFractalAccumulator(m.mode)
+		acc.add(0.5, 1)
+		acc.add(-0.5, 0.5)
+		if got := acc.result(); math.Abs(got-want[m.mode]) > 1e-12 {
+			t.Errorf("%s: result() = %v, want %v", m.name, got, want[m.mode])
+		}
+	}
+}
+
+func TestFractalAccumulatorRange(t *testing.T) {
+	// every combination of extreme and middle samples over 4 octaves stays within the eval range of the mode
+	samples := []float64{-1, -0.5, 0, 0.5, 1}
+	for _, m := range fractalModes {
+		emin, emax := (&CoherentNoise{Mode: m.mode}).GetEvalRange()
+		for c := 0; c < 625; c++ {
+			acc := newFractalAccumulator(m.mode)
+			amp := 1.0
+			for i, ci := 0, c; i < 4; i, ci = i+1, ci/5 {
+				acc.add(samples[ci%5], amp)
+				amp *= 0.5
+			}
+			if v := acc.result(); v < emin || v > emax {
+				t.Fatalf("%s: octave combination %d gives %v, outside of [%v, %v]", m.name, c, v, emin, emax)
+			}
+		}
+	}
+}
+
+func TestCoherentNoiseModes(t *testing.T) {
+	signatures := [][]byte{}
+	for _, m := range fractalModes {
+		cnoise := NewCoherentNoise(7, 0.03, 5, 2, 0.5)
+		cnoise.Mode = m.mode
+		emin, emax := cnoise.GetEvalRange()
+		for x := 0.0; x < 200; x += 3.3 {
+			for y := 0.0; y < 200; y += 2.9 {
+				for _, v := range []float64{cnoise.Eval2(x, y), cnoise.Eval3(x, y, 1.5), cnoise.Eval4(x, y, 1.5, -4)} {
+					if v < emin || v > emax {
+						t.Fatalf("%s: sample at (%v, %v) is %v, outside of [%v, %v]", m.name, x, y, v, emin, emax)
+					}
+				}
+			}
+			if got, want := cnoise.Eval1(x), cnoise.Eval2(x, 0); got != want {
+				t.Fatalf("%s: Eval1(%v) = %v, want Eval2(%v, 0) = %v", m.name, x, got, x, want)
+			}
+		}
+		signature := cnoise.GetParamSignature()
+		for _, other := range signatures {
+			if bytes.Equal(signature, other) {
+				t.Errorf("%s: signature equals the one of another mode", m.name)
+			}
+		}
+		signatures = append(signatures, signature)
+	}
+}
+
+func TestCoherentNoiseFBM(t *testing.T) {
+	// the default mode is the plain weighted average of all octaves
+	cnoise := NewCoherentNoise(3, 0.01, 3, 2, 0.5)
+	if cnoise.Mode != FractalFBM {
+		t.Fatalf("NewCoherentNoise() mode = %v, want FractalFBM", cnoise.Mode)
+	}
+	for x := 0.0; x < 100; x += 9.1 {
+		for y := 0.0; y < 100; y += 7.7 {
+			want := (cnoise.Noise.Eval2(x*0.01, y*0.01) + 0.5*cnoise.Noise.Eval2(x*0.02, y*0.02) + 0.25*cnoise.Noise.Eval2(x*0.04, y*0.04)) / 1.75
+			if got := cnoise.Eval2(x, y); math.Abs(got-want) > 1e-12 {
+				t.Fatalf("Eval2(%v, %v) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
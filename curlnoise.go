@@ -0,0 +1,61 @@
+package gah
+
+// PotentialField2D is a scalar field that provides its partial derivatives, like CoherentNoise
+type PotentialField2D interface {
+	Eval2Deriv(x, y float64) (v float64, dx float64, dy float64)
+}
+
+// PotentialField3D is a scalar field that provides its partial derivatives, like CoherentNoise
+type PotentialField3D interface {
+	Eval3Deriv(x, y, z float64) (v float64, dx float64, dy float64, dz float64)
+}
+
+var (
+	_ PotentialField2D = (*CoherentNoise)(nil)
+	_ PotentialField3D = (*CoherentNoise)(nil)
+	_ VectorField2D    = (*CurlNoise2D)(nil)
+)
+
+// CurlNoise2D is the divergence free velocity field given by the curl of a scalar potential, (dp/dy, -dp/dx)
+// the velocities run along the contour lines of the potential, so particles never bunch up or spread out
+type CurlNoise2D struct {
+	Potential PotentialField2D
+	Strength  float64 // scales all velocities
+}
+
+// NewCurlNoise2D returns the curl of the given potential, scaled by strength
+func NewCurlNoise2D(potential PotentialField2D, strength float64) *CurlNoise2D {
+	return &CurlNoise2D{potential, strength}
+}
+
+// Velocity returns the velocity at the given position
+func (cn *CurlNoise2D) Velocity(x, y float64) Vec2f {
+	_, dx, dy := cn.Potential.Eval2Deriv(x, y)
+	return Vec2f{cn.Strength * dy, -cn.Strength * dx}
+}
+
+// CurlNoise3D is the divergence free velocity field given by the curl of a vector potential
+// the 3 components of the vector potential are the scalar potential sampled at the 3 axis offsets
+type CurlNoise3D struct {
+	Potential   PotentialField3D
+	Strength    float64    // scales all velocities
+	AxisOffsets [3]float64 // added to the coordinates when sampling the x, y and z component of the vector potential
+}
+
+// NewCurlNoise3D returns the curl of the vector potential built from the given scalar potential, using the DomainWarpDefaultOffsets
+func NewCurlNoise3D(potential PotentialField3D, strength float64) *CurlNoise3D {
+	return &CurlNoise3D{potential, strength, DomainWarpDefaultOffsets}
+}
+
+// Velocity returns the velocity at the given position
+func (cn *CurlNoise3D) Velocity(x, y, z float64) Vec3f {
+	var d [3][3]float64 // d[c][a] is the derivative of component c along axis a
+	for c, off := range cn.AxisOffsets {
+		_, d[c][0], d[c][1], d[c][2] = cn.Potential.Eval3Deriv(x+off, y+off, z+off)
+	}
+	return Vec3f{
+		cn.Strength * (d[2][1] - d[1][2]),
+		cn.Strength * (d[0][2] - d[2][0]),
+		cn.Strength * (d[1][0] - d[0][1]),
+	}
+}
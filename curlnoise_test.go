@@ -0,0 +1,61 @@
+package gah
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// testPotential is the potential p(x, y, z) = x^2 y + y z^3 with exact derivatives
+type testPotential struct{}
+
+func (testPotential) Eval2Deriv(x, y float64) (v float64, dx float64, dy float64) {
+	return x * x * y, 2 * x * y, x * x
+}
+
+func (testPotential) Eval3Deriv(x, y, z float64) (v float64, dx float64, dy float64, dz float64) {
+	return x*x*y + y*z*z*z, 2 * x * y, x*x + z*z*z, 3 * y * z * z
+}
+
+func TestCurlNoiseVelocity(t *testing.T) {
+	cn2 := NewCurlNoise2D(testPotential{}, 2)
+	if got, want := cn2.Velocity(3, 5), (Vec2f{2 * 9, -2 * 30}); got != want {
+		t.Errorf("2D Velocity(3, 5) = %v, want %v", got, want)
+	}
+	cn3 := &CurlNoise3D{testPotential{}, 2, [3]float64{0, 1, 2}}
+	// d[c] are the partial derivatives of the component c, sampled at the position shifted by its axis offset
+	d := [3][3]float64{}
+	for c, off := range cn3.AxisOffsets {
+		_, d[c][0], d[c][1], d[c][2] = testPotential{}.Eval3Deriv(1+off, 2+off, 3+off)
+	}
+	want := Vec3f{2 * (d[2][1] - d[1][2]), 2 * (d[0][2] - d[2][0]), 2 * (d[1][0] - d[0][1])}
+	if got := cn3.Velocity(1, 2, 3); got != want {
+		t.Errorf("3D Velocity(1, 2, 3) = %v, want %v", got, want)
+	}
+}
+
+func TestCurlNoiseDivergenceFree(t *testing.T) {
+	potential := NewCoherentNoiseWithBase(NewPerlinNoise(4), 0.05, 3, 2, 0.5)
+	cn2, cn3 := NewCurlNoise2D(potential, 3), NewCurlNoise3D(potential, 3)
+	const e = 1e-4
+	rng := rand.New(rand.NewSource(3))
+	for s := 0; s < 200; s++ {
+		x, y, z := rng.Float64()*100, rng.Float64()*100, rng.Float64()*100
+		v := cn2.Velocity(x, y)
+		div := (cn2.Velocity(x+e, y).X-cn2.Velocity(x-e, y).X)/(2*e) + (cn2.Velocity(x, y+e).Y-cn2.Velocity(x, y-e).Y)/(2*e)
+		if math.Abs(div) > 1e-5 {
+			t.Fatalf("2D divergence at (%v, %v) is %v", x, y, div)
+		}
+		// the flow runs along the contour lines of the potential
+		_, dx, dy := potential.Eval2Deriv(x, y)
+		if dot := v.X*dx + v.Y*dy; math.Abs(dot) > 1e-12 {
+			t.Fatalf("2D velocity at (%v, %v) is not perpendicular to the gradient, dot product %v", x, y, dot)
+		}
+		div = (cn3.Velocity(x+e, y, z).X-cn3.Velocity(x-e, y, z).X)/(2*e) +
+			(cn3.Velocity(x, y+e, z).Y-cn3.Velocity(x, y-e, z).Y)/(2*e) +
+			(cn3.Velocity(x, y, z+e).Z-cn3.Velocity(x, y, z-e).Z)/(2*e)
+		if math.Abs(div) > 1e-5 {
+			t.Fatalf("3D divergence at (%v, %v, %v) is %v", x, y, z, div)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package gah
+
+import (
+	"math"
+	"sort"
+)
+
+// DelaunayTriangle is a triangle of a Delaunay triangulation, given by the indices of its corners in the triangulated points
+// the corners are ordered so that cross2 of them is positive
+type DelaunayTriangle struct {
+	A, B, C int
+}
+
+// delaunayInf is the vertex at infinity, every edge of the convex hull forms a ghost triangle with it
+// treating it symbolically instead of placing a large super triangle around the points keeps thin triangles along the hull
+const delaunayInf = -1
+
+// delaunayTri is a triangle under construction, its corners are in counterclockwise order
+// nb[i] is the index of the triangle across the edge opposite of v[i]
+type delaunayTri struct {
+	v  [3]int
+	nb [3]int
+}
+
+// delaunayBuilder holds the triangulation during the Bowyer-Watson insertion
+type delaunayBuilder struct {
+	pts      []Vec2f
+	tris     []delaunayTri
+	mark     []int // insertion that last visited the triangle, to collect the cavity without clearing
+	last     int   // triangle to start the next point location from
+	stack    []int
+	cavity   []int
+	boundary []delaunayEdge
+	byStart  map[int]int // new triangle by the first vertex of its boundary edge, which is also the last one of its predecessor
+}
+
+// delaunayEdge is an edge on the boundary of the cavity of an insertion, with the triangle outside of it
+type delaunayEdge struct {
+	a, b, outside int
+}
+
+// orient returns the cross2 of the points a, b and p, where a and b are indices
+// the edge is always evaluated in the same direction, so that both triangles of an edge agree on the side of p exactly
+func (db *delaunayBuilder) orient(a int, b int, p Vec2f) float64 {
+	if a > b {
+		return -cross2(db.pts[b], db.pts[a], p)
+	}
+	return cross2(db.pts[a], db.pts[b], p)
+}
+
+// ghostEdge returns the hull edge of a ghost triangle, p is outside of the hull if it lies left of it
+func (db *delaunayBuilder) ghostEdge(t int) (a int, b int, ghost bool) {
+	v := db.tris[t].v
+	for i := range v {
+		if v[i] == delaunayInf {
+			return v[(i+1)%3], v[(i+2)%3], true
+		}
+	}
+	return 0, 0, false
+}
+
+// conflicts returns true if p lies inside of the circumcircle of the triangle
+// the circumcircle of a ghost triangle is the open half plane outside of its hull edge, plus the open edge itself
+func (db *delaunayBuilder) conflicts(t int, p Vec2f) bool {
+	if a, b, ghost := db.ghostEdge(t); ghost {
+		o := db.orient(a, b, p)
+		if o != 0 {
+			return o > 0
+		}
+		pa, pb := db.pts[a], db.pts[b]
+		return (p.X-pa.X)*(p.X-pb.X)+(p.Y-pa.Y)*(p.Y-pb.Y) < 0
+	}
+	v := db.tris[t].v
+	a, b, c := db.pts[v[0]], db.pts[v[1]], db.pts[v[2]]
+	adx, ady := a.X-p.X, a.Y-p.Y
+	bdx, bdy := b.X-p.X, b.Y-p.Y
+	cdx, cdy := c.X-p.X, c.Y-p.Y
+	det := (adx*adx+ady*ady)*(bdx*cdy-cdx*bdy) +
+		(bdx*bdx+bdy*bdy)*(cdx*ady-adx*cdy) +
+		(cdx*cdx+cdy*cdy)*(adx*bdy-bdx*ady)
+	return det > 0
+}
+
+// locate returns a triangle that contains p, or a ghost triangle in conflict with p if it lies outside of the hull
+// it walks from the last created triangle towards p, crossing every edge that p lies beyond
+func (db *delaunayBuilder) locate(p Vec2f) int {
+	t := db.last
+	for steps := 0; steps < len(db.tris); steps++ {
+		if _, _, ghost := db.ghostEdge(t); ghost {
+			if db.conflicts(t, p) {
+				return t
+			}
+			// p lies on the inner side of the hull edge, continue with the triangle inside of it
+			for i, v := range db.tris[t].v {
+				if v == delaunayInf {
+					t = db.tris[t].nb[i]
+					break
+				}
+			}
+			continue
+		}
+		next := -1
+		v := db.tris[t].v
+		for k := 0; k < 3; k++ {
+			i := (k + steps) % 3 // vary the first edge tested, so the walk can not circle around p
+			if db.orient(v[(i+1)%3], v[(i+2)%3], p) < 0 {
+				next = db.tris[t].nb[i]
+				break
+			}
+		}
+		if next < 0 {
+			return t
+		}
+		t = next
+	}
+	// the walk only gets lost on numerically inconsistent input, fall back to searching all triangles
+	for t := range db.tris {
+		if db.conflicts(t, p) {
+			return t
+		}
+	}
+	return db.last
+}
+
+// insert adds the point with index i, unless it is a duplicate of an already triangulated point
+func (db *delaunayBuilder) insert(i int) {
+	p := db.pts[i]
+	start := db.locate(p)
+	for _, v := range db.tris[start].v {
+		if v != delaunayInf && db.pts[v] == p {
+			return
+		}
+	}
+	// collect the cavity of all triangles whose circumcircle contains p, it is connected and contains the located triangle
+	db.cavity = append(db.cavity[:0], start)
+	db.stack = append(db.stack[:0], start)
+	db.mark[start] = i
+	for len(db.stack) > 0 {
+		t := db.stack[len(db.stack)-1]
+		db.stack = db.stack[:len(db.stack)-1]
+		for _, nb := range db.tris[t].nb {
+			if db.mark[nb] != i && db.conflicts(nb, p) {
+				db.mark[nb] = i
+				db.cavity = append(db.cavity, nb)
+				db.stack = append(db.stack, nb)
+			}
+		}
+	}
+	// connect every boundary edge of the cavity to p, reusing the slots of the cavity triangles
+	boundary := db.boundary[:0]
+	for _, t := range db.cavity {
+		tri := db.tris[t]
+		for k, nb := range tri.nb {
+			if db.mark[nb] != i {
+				boundary = append(boundary, delaunayEdge{tri.v[(k+1)%3], tri.v[(k+2)%3], nb})
+			}
+		}
+	}
+	db.boundary = boundary
+	slots := db.cavity
+	for len(slots) < len(boundary) {
+		db.tris = append(db.tris, delaunayTri{})
+		db.mark = append(db.mark, i)
+		slots = append(slots, len(db.tris)-1)
+	}
+	byStart := db.byStart
+	for k := range byStart {
+		delete(byStart, k)
+	}
+	for k, e := range boundary {
+		t := slots[k]
+		db.tris[t] = delaunayTri{[3]int{e.a, e.b, i}, [3]int{-1, -1, e.outside}}
+		byStart[e.a] = t
+		// point the outside neighbor back at the new triangle
+		outside := &db.tris[e.outside]
+		for j := range outside.nb {
+			if outside.v[(j+1)%3] == e.b && outside.v[(j+2)%3] == e.a {
+				outside.nb[j] = t
+			}
+		}
+	}
+	for k, e := range boundary {
+		t := slots[k]
+		db.tris[t].nb[0] = byStart[e.b] // across the edge from b to p
+	}
+	for k, e := range boundary {
+		db.tris[byStart[e.b]].nb[1] = slots[k] // across the edge from p to a of the successor
+	}
+	db.last = slots[0]
+}
+
+// Delaunay2D returns the Delaunay triangulation of the points, using the Bowyer-Watson algorithm
+// returns nil for less than 3 points, duplicate points are only triangulated once and collinear points have no triangles
+func Delaunay2D(points []Vec2f) []DelaunayTriangle {
+	n := len(points)
+	if n < 3 {
+		return nil
+	}
+	// start from the first three points that are not collinear
+	a, b, c := 0, -1, -1
+	for i := 1; i < n && b < 0; i++ {
+		if points[i] != points[a] {
+			b = i
+		}
+	}
+	for i := b + 1; b >= 0 && i < n && c < 0; i++ {
+		if cross2(points[a], points[b], points[i]) != 0 {
+			c = i
+		}
+	}
+	if c < 0 {
+		return []DelaunayTriangle{}
+	}
+	if cross2(points[a], points[b], points[c]) < 0 {
+		b, c = c, b
+	}
+	// the first triangle and one ghost triangle for each of its edges
+	db := &delaunayBuilder{pts: points, byStart: map[int]int{}, tris: []delaunayTri{
+		{[3]int{a, b, c}, [3]int{1, 2, 3}},
+		{[3]int{c, b, delaunayInf}, [3]int{3, 2, 0}},
+		{[3]int{a, c, delaunayInf}, [3]int{1, 3, 0}},
+		{[3]int{b, a, delaunayInf}, [3]int{2, 1, 0}},
+	}}
+	db.mark = []int{-1, -1, -1, -1}
+	// insert the points row by row in a snake order over a coarse grid, so that each point lies close to the one before and the walks stay short
+	// the sort is stable, so the first of several duplicates is the one that gets triangulated, the bounds have an area as the points are not collinear
+	bounds := Polygon2f{points}.Bounds()
+	cells := math.Ceil(math.Sqrt(float64(n) / 4))
+	cellKey := func(p Vec2f) float64 {
+		col := math.Min(math.Floor((p.X-bounds.X)/bounds.W*cells), cells-1)
+		row := math.Min(math.Floor((p.Y-bounds.Y)/bounds.H*cells), cells-1)
+		if int(row)%2 == 1 {
+			col = cells - 1 - col
+		}
+		return row*cells + col
+	}
+	order := make([]int, 0, n-3)
+	for i := 0; i < n; i++ {
+		if i != a && i != b && i != c {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return cellKey(points[order[i]]) < cellKey(points[order[j]]) })
+	for _, i := range order {
+		db.insert(i)
+	}
+	result := []DelaunayTriangle{}
+	for _, t := range db.tris {
+		if t.v[0] != delaunayInf && t.v[1] != delaunayInf && t.v[2] != delaunayInf {
+			result = append(result, DelaunayTriangle{t.v[0], t.v[1], t.v[2]})
+		}
+	}
+	return result
+}
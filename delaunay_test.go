@@ -0,0 +1,150 @@
+package gah
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// polygonArea returns the signed area of the polygon, positive if its points are in counterclockwise order
+func polygonArea(points []Vec2f) float64 {
+	area := 0.0
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		area += p.X*q.Y - q.X*p.Y
+	}
+	return area / 2
+}
+
+// convexHull returns the convex hull of the points in counterclockwise order, using the monotone chain algorithm
+func convexHull(points []Vec2f) []Vec2f {
+	sorted := append([]Vec2f{}, points...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].X < sorted[j].X || (sorted[i].X == sorted[j].X && sorted[i].Y < sorted[j].Y)
+	})
+	hull := []Vec2f{}
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+		for _, p := range sorted {
+			for len(hull) >= start+2 && cross2(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, p)
+		}
+		hull = hull[:len(hull)-1]
+		for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+			sorted[i], sorted[j] = sorted[j], sorted[i]
+		}
+	}
+	return hull
+}
+
+// checkDelaunay checks that the triangles are counterclockwise, have empty circumcircles and exactly cover the convex hull of the points
+func checkDelaunay(t *testing.T, name string, points []Vec2f, tris []DelaunayTriangle) {
+	t.Helper()
+	area := 0.0
+	edges := map[[2]int]int{}
+	for _, tri := range tris {
+		a, b, c := points[tri.A], points[tri.B], points[tri.C]
+		if cross2(a, b, c) <= 0 {
+			t.Fatalf("%s: triangle %v is not counterclockwise", name, tri)
+		}
+		area += cross2(a, b, c) / 2
+		for _, e := range [][2]int{{tri.A, tri.B}, {tri.B, tri.C}, {tri.C, tri.A}} {
+			edges[e]++
+			if edges[e] > 1 {
+				t.Fatalf("%s: directed edge %v belongs to two triangles", name, e)
+			}
+		}
+		// no point lies inside of the circumcircle, up to the rounding of the determinant
+		scale := 0.0
+		for _, q := range []Vec2f{a, b, c} {
+			scale = math.Max(scale, math.Abs(q.X)+math.Abs(q.Y))
+		}
+		for i, p := range points {
+			if i == tri.A || i == tri.B || i == tri.C {
+				continue
+			}
+			adx, ady := a.X-p.X, a.Y-p.Y
+			bdx, bdy := b.X-p.X, b.Y-p.Y
+			cdx, cdy := c.X-p.X, c.Y-p.Y
+			det := (adx*adx+ady*ady)*(bdx*cdy-cdx*bdy) + (bdx*bdx+bdy*bdy)*(cdx*ady-adx*cdy) + (cdx*cdx+cdy*cdy)*(adx*bdy-bdx*ady)
+			if det > 1e-9*scale*scale*scale*scale {
+				t.Fatalf("%s: point %d %v lies inside of the circumcircle of %v", name, i, p, tri)
+			}
+		}
+	}
+	if hullArea := polygonArea(convexHull(points)); math.Abs(area-hullArea) > 1e-9*hullArea {
+		t.Fatalf("%s: triangles cover an area of %v, want the hull area %v", name, area, hullArea)
+	}
+}
+
+func TestDelaunay2D(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]Vec2f, 300)
+	for i := range random {
+		random[i] = Vec2f{rng.Float64() * 1000, rng.Float64() * 1000}
+	}
+	grid := []Vec2f{}
+	for x := 0; x < 12; x++ {
+		for y := 0; y < 9; y++ {
+			grid = append(grid, Vec2f{float64(x) * 10, float64(y) * 10})
+		}
+	}
+	circle := []Vec2f{{0, 0}}
+	for i := 0; i < 24; i++ {
+		a := 2 * math.Pi * float64(i) / 24
+		circle = append(circle, Vec2f{100 * math.Cos(a), 100 * math.Sin(a)})
+	}
+	thin := []Vec2f{}
+	for i := 0; i < 50; i++ {
+		thin = append(thin, Vec2f{float64(i), 1e-3 * float64(i*i%7)})
+	}
+	sets := []struct {
+		name   string
+		points []Vec2f
+	}{
+		{"triangle", []Vec2f{{0, 0}, {1, 0}, {0, 1}}},
+		{"random", random},
+		{"grid", grid},
+		{"cocircular", circle},
+		{"thin", thin},
+	}
+	for _, set := range sets {
+		tris := Delaunay2D(set.points)
+		checkDelaunay(t, set.name, set.points, tris)
+		// every planar triangulation of points without duplicates has 2n - 2 - h triangles, with h points on the hull boundary
+		if set.name == "random" {
+			if want := 2*len(set.points) - 2 - len(convexHull(set.points)); len(tris) != want {
+				t.Errorf("%s: got %d triangles, want %d", set.name, len(tris), want)
+			}
+		}
+	}
+}
+
+func TestDelaunay2DDegenerate(t *testing.T) {
+	if tris := Delaunay2D([]Vec2f{{0, 0}, {1, 1}}); tris != nil {
+		t.Errorf("two points give triangles %v", tris)
+	}
+	if tris := Delaunay2D([]Vec2f{{0, 0}, {1, 1}, {2, 2}, {5, 5}}); tris == nil || len(tris) != 0 {
+		t.Errorf("collinear points give triangles %v, want none", tris)
+	}
+	if tris := Delaunay2D([]Vec2f{{3, 3}, {3, 3}, {3, 3}}); tris == nil || len(tris) != 0 {
+		t.Errorf("coincident points give triangles %v, want none", tris)
+	}
+	// duplicates are triangulated only once, as their first occurrence
+	points := []Vec2f{{0, 0}, {10, 0}, {0, 10}, {10, 0}, {10, 10}, {0, 0}}
+	tris := Delaunay2D(points)
+	checkDelaunay(t, "duplicates", points, tris)
+	for _, tri := range tris {
+		for _, v := range []int{tri.A, tri.B, tri.C} {
+			if v == 3 || v == 5 {
+				t.Errorf("triangle %v uses a later duplicate", tri)
+			}
+		}
+	}
+	if len(tris) != 2 {
+		t.Errorf("got %d triangles, want 2", len(tris))
+	}
+}
@@ -0,0 +1,91 @@
+package gah
+
+// DomainWarpDefaultOffsets are the per axis offsets used by NewDomainWarp
+// they are far apart so that the displacement of each axis is uncorrelated for any sensible noise scale
+var DomainWarpDefaultOffsets = [3]float64{0, 5273.1, 9187.7}
+
+// evaler3 is implemented by sources that can also be sampled in 3 dimensions, like CoherentNoise
+type evaler3 interface {
+	Eval3(x, y, z float64) float64
+}
+
+// eval3 samples src in 3 dimensions if possible, otherwise falls back to its Eval2 ignoring z
+func eval3(src TextureCachable, x, y, z float64) float64 {
+	if src3, ok := src.(evaler3); ok {
+		return src3.Eval3(x, y, z)
+	}
+	return src.Eval2(x, y)
+}
+
+// DomainWarp offsets the input coordinates of a source by one or more other noise fields before sampling it
+// every iteration displaces the coordinates by the warp field sampled at the previously warped coordinates,
+// so 2 iterations using the source itself as warp field give the classic f(p + s*f(p + s*f(p)))
+type DomainWarp struct {
+	Source      TextureCachable
+	Warps       []TextureCachable // iteration i uses Warps[i % len(Warps)], the source itself is used if empty
+	Strength    float64           // scales the displacement of every iteration
+	Iterations  int
+	AxisOffsets [3]float64 // added to the coordinates when sampling the warp field for the x, y and z displacement
+}
+
+// NewDomainWarp returns a DomainWarp of source by the given warp fields, using the DomainWarpDefaultOffsets
+func NewDomainWarp(source TextureCachable, strength float64, iterations int, warps ...TextureCachable) *DomainWarp {
+	return &DomainWarp{source, warps, strength, iterations, DomainWarpDefaultOffsets}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (dw *DomainWarp) GetParamSignature() []byte {
+	params := []float64{dw.Strength, float64(dw.Iterations), dw.AxisOffsets[0], dw.AxisOffsets[1], dw.AxisOffsets[2]}
+	return graphSignature("domainwarp", params, append([]TextureCachable{dw.Source}, dw.Warps...)...)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2, warping does not change the range of the source
+func (dw *DomainWarp) GetEvalRange() (outMin float64, outMax float64) {
+	return dw.Source.GetEvalRange()
+}
+
+// warpField returns the field used to displace the coordinates in the given iteration
+func (dw *DomainWarp) warpField(iteration int) TextureCachable {
+	if len(dw.Warps) == 0 {
+		return dw.Source
+	}
+	return dw.Warps[iteration%len(dw.Warps)]
+}
+
+// Warp2 returns the warped coordinates at which the source is sampled by Eval2
+func (dw *DomainWarp) Warp2(x, y float64) (wx float64, wy float64) {
+	var qx, qy float64
+	for i := 0; i < dw.Iterations; i++ {
+		field := dw.warpField(i)
+		px, py := x+dw.Strength*qx, y+dw.Strength*qy
+		qx = field.Eval2(px+dw.AxisOffsets[0], py+dw.AxisOffsets[0])
+		qy = field.Eval2(px+dw.AxisOffsets[1], py+dw.AxisOffsets[1])
+	}
+	return x + dw.Strength*qx, y + dw.Strength*qy
+}
+
+// Warp3 returns the warped coordinates at which the source is sampled by Eval3
+func (dw *DomainWarp) Warp3(x, y, z float64) (wx float64, wy float64, wz float64) {
+	var qx, qy, qz float64
+	for i := 0; i < dw.Iterations; i++ {
+		field := dw.warpField(i)
+		px, py, pz := x+dw.Strength*qx, y+dw.Strength*qy, z+dw.Strength*qz
+		qx = eval3(field, px+dw.AxisOffsets[0], py+dw.AxisOffsets[0], pz+dw.AxisOffsets[0])
+		qy = eval3(field, px+dw.AxisOffsets[1], py+dw.AxisOffsets[1], pz+dw.AxisOffsets[1])
+		qz = eval3(field, px+dw.AxisOffsets[2], py+dw.AxisOffsets[2], pz+dw.AxisOffsets[2])
+	}
+	return x + dw.Strength*qx, y + dw.Strength*qy, z + dw.Strength*qz
+}
+
+// Eval2 returns the source sampled at the warped coordinates
+func (dw *DomainWarp) Eval2(x, y float64) float64 {
+	wx, wy := dw.Warp2(x, y)
+	return dw.Source.Eval2(wx, wy)
+}
+
+// Eval3 returns the source sampled at the warped coordinates
+// sources and warp fields without an Eval3 method are sampled through their Eval2, ignoring z
+func (dw *DomainWarp) Eval3(x, y, z float64) float64 {
+	wx, wy, wz := dw.Warp3(x, y, z)
+	return eval3(dw.Source, wx, wy, wz)
+}
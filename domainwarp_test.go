@@ -0,0 +1,104 @@
+package gah
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestDomainWarpIdentity(t *testing.T) {
+	src := NewCoherentNoise(1, 0.05, 3, 2, 0.5)
+	for _, dw := range []*DomainWarp{NewDomainWarp(src, 0, 3), NewDomainWarp(src, 20, 0)} {
+		for x := 0.0; x < 50; x += 3.7 {
+			for y := 0.0; y < 50; y += 4.1 {
+				if got, want := dw.Eval2(x, y), src.Eval2(x, y); got != want {
+					t.Fatalf("strength %v, iterations %d: Eval2(%v, %v) = %v, want the unwarped %v", dw.Strength, dw.Iterations, x, y, got, want)
+				}
+				if got, want := dw.Eval3(x, y, 2), src.Eval3(x, y, 2); got != want {
+					t.Fatalf("strength %v, iterations %d: Eval3(%v, %v, 2) = %v, want the unwarped %v", dw.Strength, dw.Iterations, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestDomainWarpIterated(t *testing.T) {
+	src := NewCoherentNoise(2, 0.02, 3, 2, 0.5)
+	const s = 8
+	// f(p) samples the source with the default per axis offsets, as a 2D displacement
+	f := func(x, y float64) (float64, float64) {
+		o := DomainWarpDefaultOffsets
+		return src.Eval2(x+o[0], y+o[0]), src.Eval2(x+o[1], y+o[1])
+	}
+	dw := NewDomainWarp(src, s, 2)
+	for x := 0.0; x < 100; x += 7.3 {
+		for y := 0.0; y < 100; y += 6.1 {
+			// f(p + s*f(p + s*f(p)))
+			qx, qy := f(x, y)
+			qx, qy = f(x+s*qx, y+s*qy)
+			want := src.Eval2(x+s*qx, y+s*qy)
+			if got := dw.Eval2(x, y); math.Abs(got-want) > 1e-12 {
+				t.Fatalf("Eval2(%v, %v) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestDomainWarpFields(t *testing.T) {
+	src := NewNoiseGradient(0, 0, 100, 100)
+	one, two := NewNoiseConst(1), NewNoiseConst(2)
+	tests := []struct {
+		name       string
+		iterations int
+		warps      []TextureCachable
+		want       float64 // displacement along each axis
+	}{
+		{"single field", 3, []TextureCachable{two}, 6},
+		{"cycled fields, last is the second", 2, []TextureCachable{one, two}, 6},
+		{"cycled fields, last is the first", 3, []TextureCachable{one, two}, 3},
+	}
+	for _, tt := range tests {
+		dw := NewDomainWarp(src, 3, tt.iterations, tt.warps...)
+		if wx, wy := dw.Warp2(10, 20); wx != 10+tt.want || wy != 20+tt.want {
+			t.Errorf("%s: Warp2(10, 20) = (%v, %v), want a displacement of %v", tt.name, wx, wy, tt.want)
+		}
+		if wx, wy, wz := dw.Warp3(10, 20, 30); wx != 10+tt.want || wy != 20+tt.want || wz != 30+tt.want {
+			t.Errorf("%s: Warp3(10, 20, 30) = (%v, %v, %v), want a displacement of %v", tt.name, wx, wy, wz, tt.want)
+		}
+		// the gradient has no Eval3, so it is sampled through its Eval2 ignoring z
+		want := src.Eval2(10+tt.want, 20+tt.want)
+		if got := dw.Eval2(10, 20); got != want {
+			t.Errorf("%s: Eval2(10, 20) = %v, want %v", tt.name, got, want)
+		}
+		if got := dw.Eval3(10, 20, 30); got != want {
+			t.Errorf("%s: Eval3(10, 20, 30) = %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestDomainWarpSignature(t *testing.T) {
+	src := NewCoherentNoise(3, 0.01, 4, 2, 0.5)
+	warp := NewCoherentNoise(4, 0.02, 4, 2, 0.5)
+	base := NewDomainWarp(src, 10, 2, warp)
+	smin, smax := src.GetEvalRange()
+	if emin, emax := base.GetEvalRange(); emin != smin || emax != smax {
+		t.Errorf("GetEvalRange() = [%v, %v], want the range [%v, %v] of the source", emin, emax, smin, smax)
+	}
+	if !bytes.Equal(base.GetParamSignature(), NewDomainWarp(src, 10, 2, warp).GetParamSignature()) {
+		t.Errorf("equal warps have different signatures")
+	}
+	offsets := NewDomainWarp(src, 10, 2, warp)
+	offsets.AxisOffsets[1] = 17
+	for _, other := range []*DomainWarp{
+		NewDomainWarp(src, 11, 2, warp),
+		NewDomainWarp(src, 10, 3, warp),
+		NewDomainWarp(src, 10, 2),
+		NewDomainWarp(src, 10, 2, warp, warp),
+		NewDomainWarp(warp, 10, 2, src),
+		offsets,
+	} {
+		if bytes.Equal(base.GetParamSignature(), other.GetParamSignature()) {
+			t.Errorf("warp %+v has the same signature as %+v", other, base)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package gah
+
+import (
+	"runtime"
+	"sync"
+)
+
+// evalRow2 returns a row function for evalGrid2 that samples eval once per pixel
+func evalRow2(eval func(x, y float64) float64) func(row []float64, x0 float64, y float64, dx float64) {
+	return func(row []float64, x0 float64, y float64, dx float64) {
+		for ix := range row {
+			row[ix] = eval(x0+float64(ix)*dx, y)
+		}
+	}
+}
+
+// evalGrid2 fills dst with a w x h grid starting at (x0, y0) with a spacing of dx and dy, in row major order
+// evalRow fills one row of the grid at the given y, pixel ix of the row lies at x0 + ix*dx
+// rows are handed out to the given number of goroutines, <= 0 for one per CPU
+func evalGrid2(evalRow func(row []float64, x0 float64, y float64, dx float64), dst []float64, x0 float64, y0 float64, dx float64, dy float64, w int, h int, workers int) {
+	dst = dst[:w*h]
+	fillRow := func(iy int) {
+		evalRow(dst[iy*w:(iy+1)*w], x0, y0+float64(iy)*dy, dx)
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > h {
+		workers = h
+	}
+	if workers <= 1 {
+		for iy := 0; iy < h; iy++ {
+			fillRow(iy)
+		}
+		return
+	}
+	rows := make(chan int, h)
+	for iy := 0; iy < h; iy++ {
+		rows <- iy
+	}
+	close(rows)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for iy := range rows {
+				fillRow(iy)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// EvalGrid2 fills dst with the Eval2 of any texture provider sampled on a w x h grid, see CoherentNoise.EvalGrid2
+func EvalGrid2(provider TextureCachable, dst []float64, x0 float64, y0 float64, dx float64, dy float64, w int, h int) {
+	evalGrid2(evalRow2(provider.Eval2), dst, x0, y0, dx, dy, w, h, 1)
+}
+
+// EvalGrid2Parallel works as EvalGrid2 does, but spreads the rows over the given number of goroutines, <= 0 for one per CPU
+// the provider has to be safe for concurrent use
+func EvalGrid2Parallel(provider TextureCachable, dst []float64, x0 float64, y0 float64, dx float64, dy float64, w int, h int, workers int) {
+	evalGrid2(evalRow2(provider.Eval2), dst, x0, y0, dx, dy, w, h, workers)
+}
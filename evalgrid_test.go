@@ -0,0 +1,69 @@
+package gah
+
+import "testing"
+
+// checkGrid compares the grid against eval at every pixel, and checks that values after the grid are left alone
+func checkGrid(t *testing.T, name string, dst []float64, eval func(x, y float64) float64, x0, y0, dx, dy float64, w, h int) {
+	t.Helper()
+	for iy := 0; iy < h; iy++ {
+		for ix := 0; ix < w; ix++ {
+			x, y := x0+float64(ix)*dx, y0+float64(iy)*dy
+			if got, want := dst[iy*w+ix], eval(x, y); got != want {
+				t.Fatalf("%s: pixel (%d, %d) is %v, want Eval2(%v, %v) = %v", name, ix, iy, got, x, y, want)
+			}
+		}
+	}
+	for i := w * h; i < len(dst); i++ {
+		if dst[i] != -7 {
+			t.Fatalf("%s: value %d after the grid was overwritten", name, i)
+		}
+	}
+}
+
+// gridBuffer returns a buffer for a w x h grid with some spare room, filled with a marker value
+func gridBuffer(w, h int) []float64 {
+	dst := make([]float64, w*h+5)
+	for i := range dst {
+		dst[i] = -7
+	}
+	return dst
+}
+
+func TestCoherentNoiseEvalGrid2(t *testing.T) {
+	grids := []struct {
+		x0, y0, dx, dy float64
+		w, h           int
+	}{
+		{0, 0, 1, 1, 37, 23},
+		{-12.5, 40, 0.3, -1.7, 16, 9},
+		{3, 3, 2, 2, 1, 1},
+		{3, 3, 2, 2, 5, 0},
+	}
+	for _, m := range fractalModes {
+		cnoise := NewCoherentNoise(12, 0.04, 4, 2, 0.5)
+		cnoise.Mode = m.mode
+		for _, g := range grids {
+			dst := gridBuffer(g.w, g.h)
+			cnoise.EvalGrid2(dst, g.x0, g.y0, g.dx, g.dy, g.w, g.h)
+			checkGrid(t, m.name, dst, cnoise.Eval2, g.x0, g.y0, g.dx, g.dy, g.w, g.h)
+			for _, workers := range []int{0, 1, 3, 100} {
+				dst := gridBuffer(g.w, g.h)
+				cnoise.EvalGrid2Parallel(dst, g.x0, g.y0, g.dx, g.dy, g.w, g.h, workers)
+				checkGrid(t, m.name+" parallel", dst, cnoise.Eval2, g.x0, g.y0, g.dx, g.dy, g.w, g.h)
+			}
+		}
+	}
+}
+
+func TestEvalGrid2(t *testing.T) {
+	graph := NewNoiseMultiply(NewCoherentNoiseWithBase(NewPerlinNoise(2), 0.05, 3, 2, 0.5), NewNoiseGradient(0, 0, 30, 10))
+	const w, h = 31, 17
+	dst := gridBuffer(w, h)
+	EvalGrid2(graph, dst, -5, 2, 0.9, 1.1, w, h)
+	checkGrid(t, "serial", dst, graph.Eval2, -5, 2, 0.9, 1.1, w, h)
+	for _, workers := range []int{0, 2, 50} {
+		dst := gridBuffer(w, h)
+		EvalGrid2Parallel(graph, dst, -5, 2, 0.9, 1.1, w, h, workers)
+		checkGrid(t, "parallel", dst, graph.Eval2, -5, 2, 0.9, 1.1, w, h)
+	}
+}
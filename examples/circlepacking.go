@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math/rand"
+
+	"github.com/RememberOfLife/gah"
+	"github.com/fogleman/gg"
+)
+
+func main() {
+	const width, height int = 1000, 1000
+	dc := gg.NewContext(width, height)
+	dc.SetRGB(1, 1, 1)
+	dc.Clear()
+
+	sqt := gah.NewShapeQuadTree(0, 0, float64(width), float64(height))
+
+	for i := 0; i < 20000; i++ {
+		c := gah.Circle2f{
+			Center: gah.Vec2f{X: rand.Float64() * float64(width), Y: rand.Float64() * float64(height)},
+			R:      rand.Float64()*30 + 2,
+		}
+		if sqt.AnyIntersecting(c) {
+			continue
+		}
+		if sqt.InsertShape(c) {
+			dc.DrawCircle(c.Center.X, c.Center.Y, c.R)
+		}
+	}
+	dc.SetRGB(0, 0, 0)
+	dc.Fill()
+
+	dc.SavePNG("./out.png")
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/RememberOfLife/gah"
+	"github.com/fogleman/gg"
+)
+
+func main() {
+	const width, height int = 1000, 1000
+	dc := gg.NewContext(width, height)
+
+	noise := gah.NewCoherentNoise(0, 0.003, 5, 2, 0.5)
+	// f(p + s*f(p + s*f(p)))
+	marble := gah.NewDomainWarp(noise, 300, 2)
+
+	for ix := 0; ix < width; ix++ {
+		for iy := 0; iy < height; iy++ {
+			cv := gah.ScaleF2I(marble.Eval2(float64(ix), float64(iy)), -1, 1, 0, 255)
+			dc.SetRGB255(cv, cv, cv)
+			dc.SetPixel(ix, iy)
+		}
+	}
+
+	dc.SavePNG("./out.png")
+}
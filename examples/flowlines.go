@@ -0,0 +1,42 @@
+package main
+
+import (
+	"math/rand"
+
+	"github.com/RememberOfLife/gah"
+	"github.com/fogleman/gg"
+)
+
+func main() {
+	const width, height int = 1000, 1000
+	dc := gg.NewContext(width, height)
+	dc.SetRGB(1, 1, 1)
+	dc.Clear()
+
+	potential := gah.NewCoherentNoiseWithBase(gah.NewPerlinNoise(0), 0.002, 3, 2, 0.5)
+	field := gah.NewCurlNoise2D(potential, 1)
+
+	seeds := make([]gah.Vec2f, 5000)
+	for i := range seeds {
+		seeds[i] = gah.Vec2f{X: rand.Float64() * float64(width), Y: rand.Float64() * float64(height)}
+	}
+	lines := gah.TraceFlowLines(field, seeds, gah.FlowLineOptions{
+		Bounds:     gah.Rect2f{X: 0, Y: 0, W: float64(width), H: float64(height)},
+		StepSize:   2,
+		MaxLength:  600,
+		MinLength:  20,
+		Separation: 6,
+	})
+
+	dc.SetRGB(0, 0, 0)
+	dc.SetLineWidth(1.5)
+	for _, line := range lines {
+		dc.MoveTo(line[0].X, line[0].Y)
+		for _, p := range line[1:] {
+			dc.LineTo(p.X, p.Y)
+		}
+		dc.Stroke()
+	}
+
+	dc.SavePNG("./out.png")
+}
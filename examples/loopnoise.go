@@ -0,0 +1,34 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"os"
+
+	"github.com/RememberOfLife/gah"
+)
+
+func main() {
+	const width, height, frames int = 300, 300, 60
+
+	noise := gah.NewCoherentNoise(0, 0.01, 4, 2, 0.5)
+	loop := gah.NewLoopingNoise2D(noise, 60)
+
+	palette := make(color.Palette, 256)
+	for i := range palette {
+		palette[i] = color.Gray{uint8(i)}
+	}
+	anim := &gif.GIF{}
+	for _, frame := range gah.RenderLoopFrames(loop, frames, 0, 0, width, height) {
+		img := image.NewPaletted(frame.Bounds(), palette)
+		draw.Draw(img, img.Bounds(), frame, image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, 4)
+	}
+
+	f, _ := os.Create("./out.gif")
+	defer f.Close()
+	gif.EncodeAll(f, anim)
+}
@@ -6,7 +6,7 @@ import (
 )
 
 func main() {
-	noise := gah.NewCoherentNoise(0, 0.005, 5, 2, 0.5, nil)
+	noise := gah.NewCoherentNoise(0, 0.005, 5, 2, 0.5)
 	const width, height int = 1000, 1000
 	dc := gg.NewContext(width, height)
 
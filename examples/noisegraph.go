@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/RememberOfLife/gah"
+	"github.com/fogleman/gg"
+)
+
+func main() {
+	const width, height int = 1000, 1000
+	dc := gg.NewContext(width, height)
+
+	// blend a terraced noise into voronoi crackle, using a second noise as mask
+	terrain := gah.NewNoiseTerrace(gah.NewCoherentNoise(0, 0.004, 5, 2, 0.5), []float64{-1, -0.4, 0, 0.3, 1}, false)
+	voronoi := gah.NewNoiseScaleBias(gah.NewVoronoiDiagram2D(0, 0, 0, float64(width), float64(height), 80, -1, 30), 2, -1)
+	mask := gah.NewCoherentNoise(1, 0.002, 2, 2, 0.5)
+	graph := gah.NewNoiseSelect(terrain, voronoi, mask, 0, 0.1)
+
+	emin, emax := graph.GetEvalRange()
+	for ix := 0; ix < width; ix++ {
+		for iy := 0; iy < height; iy++ {
+			cv := gah.ScaleF2I(graph.Eval2(float64(ix), float64(iy)), emin, emax, 0, 255)
+			dc.SetRGB255(cv, cv, cv)
+			dc.SetPixel(ix, iy)
+		}
+	}
+
+	dc.SavePNG("./out.png")
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"image/color"
 	"math/rand"
+	"os"
 
 	"github.com/RememberOfLife/gah"
 	"github.com/fogleman/gg"
@@ -23,7 +25,14 @@ func main() {
 		qt.InsertPoint(p)
 	}
 
-	gah.DrawQuadTree(dc, qt)
+	style := gah.DefaultQuadTreeStyle()
+	style.LineColors = []color.Color{color.Black, color.RGBA{0x40, 0x40, 0x40, 0xFF}, color.RGBA{0x80, 0x80, 0x80, 0xFF}}
+	style.LineWidths = []float64{3, 2, 1}
+	gah.DrawQuadTreeStyled(dc, qt, style)
 
 	dc.SavePNG("./out.png")
+
+	f, _ := os.Create("./out.svg")
+	defer f.Close()
+	gah.WriteQuadTreeSVG(f, qt, style)
 }
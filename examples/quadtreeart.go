@@ -0,0 +1,35 @@
+package main
+
+import (
+	"math/rand"
+
+	"github.com/RememberOfLife/gah"
+	"github.com/fogleman/gg"
+)
+
+func main() {
+	const width, height int = 1024, 1024
+
+	// paint a simple source image to subdivide
+	src := gg.NewContext(width, height)
+	src.SetRGB(0.1, 0.1, 0.2)
+	src.Clear()
+	for i := 0; i < 30; i++ {
+		src.SetRGB(rand.Float64(), rand.Float64(), rand.Float64())
+		src.DrawCircle(rand.Float64()*float64(width), rand.Float64()*float64(height), rand.Float64()*200+20)
+		src.Fill()
+	}
+
+	_, leafs := gah.BuildImageQuadTree(src.Image(), gah.QuadTreeArtOptions{MaxLeafs: 2000, MinSize: 4})
+
+	dc := gg.NewContext(width, height)
+	dc.SetRGB(0, 0, 0)
+	dc.Clear()
+	for _, leaf := range leafs {
+		dc.SetColor(leaf.Color)
+		dc.DrawRectangle(leaf.Bounds.X+1, leaf.Bounds.Y+1, leaf.Bounds.W-2, leaf.Bounds.H-2)
+		dc.Fill()
+	}
+
+	dc.SavePNG("./out.png")
+}
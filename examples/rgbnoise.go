@@ -6,7 +6,7 @@ import (
 )
 
 func main() {
-	noise := gah.NewCoherentNoise(0, 0.01, 5, 2, 0.4, nil)
+	noise := gah.NewCoherentNoise(0, 0.01, 5, 2, 0.4)
 	const width, height int = 256, 256
 	dc := gg.NewContext(width, height)
 
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"math"
+
+	"github.com/RememberOfLife/gah"
+	"github.com/fogleman/gg"
+)
+
+func main() {
+	const width, height int = 1000, 1000
+	dc := gg.NewContext(width, height)
+	dc.SetRGB(1, 1, 1)
+	dc.Clear()
+
+	density := gah.NewCoherentNoise(0, 0.003, 4, 2, 0.5)
+	density.Mode = gah.FractalRidged
+	density.Output = gah.NoiseOutputEqualized
+	stipples := gah.StippleTexture(density, 0, 0, width, height, gah.StippleOptions{
+		Points:     12000,
+		Iterations: 60,
+		Tolerance:  0.05,
+	})
+
+	dc.SetRGB(0.1, 0.1, 0.1)
+	for _, s := range stipples {
+		dc.DrawCircle(s.Pos.X, s.Pos.Y, 0.8+1.6*math.Sqrt(s.Density))
+		dc.Fill()
+	}
+
+	dc.SavePNG("./out.png")
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/RememberOfLife/gah"
+	"github.com/fogleman/gg"
+)
+
+func main() {
+	const width, height int = 1000, 1000
+	dc := gg.NewContext(width, height)
+
+	voronoi := gah.NewVoronoiDiagram2D(0, 0, 0, float64(width), float64(height), 40, 0, 30)
+	tint := gah.NewCoherentNoise(0, 0.002, 3, 2, 0.5)
+	tint.Output = gah.NoiseOutputEqualized
+	ramp := gah.ColorRamp{GradientStops: []gah.ColorStop{
+		{Position: 0, Color: color.RGBA{0x1b, 0x3a, 0x6b, 0xff}},
+		{Position: 0.5, Color: color.RGBA{0xc4, 0x3d, 0x3d, 0xff}},
+		{Position: 1, Color: color.RGBA{0xf2, 0xc1, 0x4e, 0xff}},
+	}}
+
+	for _, cell := range voronoi.Cells() {
+		if len(cell.Polygon.Points) == 0 {
+			continue
+		}
+		for _, p := range cell.Polygon.Points {
+			dc.LineTo(p.X, p.Y)
+		}
+		dc.ClosePath()
+		site := voronoi.Points[cell.Site]
+		dc.SetColor(ramp.Sample(gah.ScaleF2F(tint.Eval2(site.X, site.Y), -1, 1, 0, 1)))
+		dc.FillPreserve()
+		dc.SetRGB(0.1, 0.1, 0.1)
+		dc.SetLineWidth(4)
+		dc.Stroke()
+	}
+
+	dc.SavePNG("./out.png")
+}
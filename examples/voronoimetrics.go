@@ -0,0 +1,39 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/RememberOfLife/gah"
+	"github.com/fogleman/gg"
+)
+
+func main() {
+	var wPx int = 1000
+	var hPx int = 1000
+	dc := gg.NewContext(wPx, hPx)
+
+	metrics := []gah.DistanceMetric{&gah.EuclideanMetric{}, &gah.ManhattanMetric{}, &gah.ChebyshevMetric{}, &gah.MinkowskiMetric{P: 0.6}}
+	ramp := gah.ColorRamp{GradientStops: []gah.ColorStop{
+		{Position: 0, Color: color.RGBA{0x26, 0x46, 0x53, 0xff}},
+		{Position: 0.5, Color: color.RGBA{0x2a, 0x9d, 0x8f, 0xff}},
+		{Position: 1, Color: color.RGBA{0xe9, 0xc4, 0x6a, 0xff}},
+	}}
+
+	// one quadrant per metric, colored by the cell value of the closest site and darkened towards the cell borders by F2 - F1
+	for q, metric := range metrics {
+		qx, qy := q%2*wPx/2, q/2*hPx/2
+		voronoi := gah.NewVoronoiDiagram2D(uint64(q), float64(qx), float64(qy), float64(wPx/2), float64(hPx/2), 60, 0, 30)
+		voronoi.Metric = metric
+		voronoi.Mode = gah.VoronoiF2MinusF1
+		for iy := qy; iy < qy+hPx/2; iy++ {
+			for ix := qx; ix < qx+wPx/2; ix++ {
+				edge, site := voronoi.Eval2Site(float64(ix), float64(iy))
+				c := ramp.Sample(voronoi.CellValue(site))
+				dc.SetColor(gah.RGBMix(c, color.RGBA{0, 0, 0, 0xff}, gah.Clamp((edge-0.9)*10, 0, 1), true))
+				dc.SetPixel(ix, iy)
+			}
+		}
+	}
+
+	dc.SavePNG("./out.png")
+}
@@ -0,0 +1,97 @@
+package gah
+
+import "math"
+
+// VectorField2D is a 2D velocity field that particles can be moved through, like CurlNoise2D
+type VectorField2D interface {
+	Velocity(x, y float64) Vec2f
+}
+
+// FlowLineOptions control how TraceFlowLines integrates particles through a vector field
+type FlowLineOptions struct {
+	Bounds     Rect2f  // lines stop when they leave this area
+	StepSize   float64 // arc length travelled per integration step
+	MaxLength  float64 // lines stop once they reach this arc length
+	MinLength  float64 // shorter lines are dropped, they do not block other lines either
+	Separation float64 // lines stop before coming closer than this to any line traced before or to their own earlier part, <= 0 to allow crossing
+}
+
+// flowDirection returns the normalized velocity of the field at p, or false if the field vanishes there
+func flowDirection(field VectorField2D, p Vec2f) (Vec2f, bool) {
+	v := field.Velocity(p.X, p.Y)
+	l := math.Hypot(v.X, v.Y)
+	if l < 1e-12 {
+		return Vec2f{}, false
+	}
+	return Vec2f{v.X / l, v.Y / l}, true
+}
+
+// flowStep advances p by one step of length h along the field, using the runge kutta method of 4th order on the normalized velocity
+func flowStep(field VectorField2D, p Vec2f, h float64) (Vec2f, bool) {
+	k1, ok1 := flowDirection(field, p)
+	k2, ok2 := flowDirection(field, Vec2f{p.X + k1.X*h/2, p.Y + k1.Y*h/2})
+	k3, ok3 := flowDirection(field, Vec2f{p.X + k2.X*h/2, p.Y + k2.Y*h/2})
+	k4, ok4 := flowDirection(field, Vec2f{p.X + k3.X*h, p.Y + k3.Y*h})
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return p, false
+	}
+	return Vec2f{p.X + h/6*(k1.X+2*k2.X+2*k3.X+k4.X), p.Y + h/6*(k1.Y+2*k2.Y+2*k3.Y+k4.Y)}, true
+}
+
+// TraceFlowLines integrates a particle from every seed through the field and returns the resulting polylines, in seed order
+// lines advance with a constant step size regardless of the speed of the field, and stop where the field vanishes
+// seeds that lie outside of the bounds or too close to an existing line do not produce a line
+func TraceFlowLines(field VectorField2D, seeds []Vec2f, opts FlowLineOptions) [][]Vec2f {
+	var grid *UniformGrid2D
+	if opts.Separation > 0 {
+		grid = NewUniformGrid2D(opts.Bounds.X, opts.Bounds.Y, opts.Bounds.W, opts.Bounds.H, opts.Separation)
+	}
+	blocked := func(p Vec2f) bool {
+		return grid != nil && len(grid.QueryRadius(p, opts.Separation)) > 0
+	}
+	// points of the own line only block once the line has moved on far enough, otherwise every step would collide with the previous one
+	lag := 0
+	if grid != nil && opts.StepSize > 0 {
+		lag = int(math.Ceil(2*opts.Separation/opts.StepSize)) + 1
+	}
+	maxSteps := 0
+	if opts.StepSize > 0 {
+		maxSteps = int(opts.MaxLength / opts.StepSize)
+	}
+	lines := [][]Vec2f{}
+	for _, seed := range seeds {
+		if !opts.Bounds.ContainsPoint(seed) || blocked(seed) {
+			continue
+		}
+		line := []Vec2f{seed}
+		p := seed
+		for i := 0; i < maxSteps; i++ {
+			next, ok := flowStep(field, p, opts.StepSize)
+			if !ok || !opts.Bounds.ContainsPoint(next) || blocked(next) {
+				break
+			}
+			line = append(line, next)
+			p = next
+			if grid != nil && len(line) > lag {
+				grid.InsertPoint(line[len(line)-1-lag])
+			}
+		}
+		inserted := len(line) - lag
+		if inserted < 0 {
+			inserted = 0
+		}
+		if float64(len(line)-1)*opts.StepSize < opts.MinLength {
+			if grid != nil {
+				for _, lp := range line[:inserted] {
+					grid.RemovePoint(lp)
+				}
+			}
+			continue
+		}
+		if grid != nil {
+			grid.InsertPoints(line[inserted:])
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,108 @@
+package gah
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// testVectorField is a VectorField2D given by a function
+type testVectorField func(x, y float64) Vec2f
+
+func (f testVectorField) Velocity(x, y float64) Vec2f {
+	return f(x, y)
+}
+
+// rotationField circles counter clockwise around (50, 50), with a speed growing with the radius
+var rotationField = testVectorField(func(x, y float64) Vec2f {
+	return Vec2f{-(y - 50), x - 50}
+})
+
+// uniformField flows to the right everywhere
+var uniformField = testVectorField(func(x, y float64) Vec2f {
+	return Vec2f{3, 0}
+})
+
+func TestTraceFlowLinesIntegration(t *testing.T) {
+	bounds := Rect2f{0, 0, 100, 100}
+	lines := TraceFlowLines(rotationField, []Vec2f{{80, 50}}, FlowLineOptions{Bounds: bounds, StepSize: 0.5, MaxLength: 150})
+	if len(lines) != 1 || len(lines[0]) != 301 {
+		t.Fatalf("got %d lines, want a single line of 301 points", len(lines))
+	}
+	for i, p := range lines[0] {
+		// particles stay on their circle and advance by the step size regardless of the speed of the field
+		if r := math.Hypot(p.X-50, p.Y-50); math.Abs(r-30) > 1e-6 {
+			t.Fatalf("point %d %v has drifted to radius %v", i, p, r)
+		}
+		if i > 0 {
+			if d := math.Hypot(p.X-lines[0][i-1].X, p.Y-lines[0][i-1].Y); math.Abs(d-0.5) > 1e-3 {
+				t.Fatalf("step %d has length %v, want 0.5", i, d)
+			}
+		}
+	}
+}
+
+func TestTraceFlowLinesStops(t *testing.T) {
+	bounds := Rect2f{0, 0, 100, 100}
+	tests := []struct {
+		name    string
+		field   VectorField2D
+		seeds   []Vec2f
+		opts    FlowLineOptions
+		lengths []int // number of points of every line
+	}{
+		{"max length", uniformField, []Vec2f{{10, 10}}, FlowLineOptions{Bounds: bounds, StepSize: 2, MaxLength: 20}, []int{11}},
+		{"bounds", uniformField, []Vec2f{{10, 10}}, FlowLineOptions{Bounds: bounds, StepSize: 2, MaxLength: 1000}, []int{46}},
+		{"seed outside", uniformField, []Vec2f{{-1, 10}, {10, 10}}, FlowLineOptions{Bounds: bounds, StepSize: 2, MaxLength: 20}, []int{11}},
+		{"vanishing field", rotationField, []Vec2f{{50, 50}}, FlowLineOptions{Bounds: bounds, StepSize: 2, MaxLength: 20}, []int{1}},
+		{"too short", rotationField, []Vec2f{{50, 50}, {70, 50}}, FlowLineOptions{Bounds: bounds, StepSize: 2, MaxLength: 20, MinLength: 1}, []int{11}},
+		{"separation", uniformField, []Vec2f{{10, 10}, {20, 12}, {10, 30}}, FlowLineOptions{Bounds: bounds, StepSize: 1, MaxLength: 20, Separation: 5}, []int{21, 21}},
+		{"blocked ahead", uniformField, []Vec2f{{40, 10}, {10, 10}}, FlowLineOptions{Bounds: bounds, StepSize: 1, MaxLength: 100, Separation: 5}, []int{61, 25}},
+		// the dropped short line at the center does not block the line running through it
+		{"dropped line", rotationField, []Vec2f{{50, 50}, {50, 51}}, FlowLineOptions{Bounds: bounds, StepSize: 0.5, MaxLength: 3, MinLength: 1, Separation: 0.5}, []int{7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := TraceFlowLines(tt.field, tt.seeds, tt.opts)
+			if len(lines) != len(tt.lengths) {
+				t.Fatalf("got %d lines, want %d", len(lines), len(tt.lengths))
+			}
+			for i, line := range lines {
+				if len(line) != tt.lengths[i] {
+					t.Errorf("line %d has %d points, want %d", i, len(line), tt.lengths[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTraceFlowLinesSeparation(t *testing.T) {
+	field := NewCurlNoise2D(NewCoherentNoiseWithBase(NewPerlinNoise(1), 0.01, 3, 2, 0.5), 1)
+	rng := rand.New(rand.NewSource(5))
+	seeds := make([]Vec2f, 300)
+	for i := range seeds {
+		seeds[i] = Vec2f{rng.Float64() * 200, rng.Float64() * 200}
+	}
+	opts := FlowLineOptions{Bounds: Rect2f{0, 0, 200, 200}, StepSize: 1, MaxLength: 200, MinLength: 10, Separation: 4}
+	lines := TraceFlowLines(field, seeds, opts)
+	if len(lines) < 10 {
+		t.Fatalf("got only %d lines", len(lines))
+	}
+	for i, line := range lines {
+		if float64(len(line)-1)*opts.StepSize < opts.MinLength {
+			t.Errorf("line %d is shorter than the minimum length", i)
+		}
+		for _, p := range line {
+			if !opts.Bounds.ContainsPoint(p) {
+				t.Fatalf("line %d leaves the bounds at %v", i, p)
+			}
+			for j, other := range lines[:i] {
+				for _, q := range other {
+					if d := math.Hypot(p.X-q.X, p.Y-q.Y); d < opts.Separation {
+						t.Fatalf("lines %d and %d come as close as %v", j, i, d)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package gah
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// KDTree2D is a static 2D k-d tree, built once from a set of points
+// the tree is stored implicitly: every subslice has its median as the node, split alternately on X and Y
+// insertions and removals only mark the tree as dirty, it is rebuilt lazily on the next query
+// queries are safe for concurrent use, insertions and removals are not safe to run concurrently with anything else
+type KDTree2D struct {
+	points []Vec2f
+	dirty  bool
+	mu     sync.Mutex // guards the lazy rebuild, so that concurrent queries do not all sort the points at once
+}
+
+// NewKDTree2D builds a new KDTree2D from a copy of the given points
+func NewKDTree2D(points []Vec2f) *KDTree2D {
+	kdt := &KDTree2D{points: append([]Vec2f{}, points...)}
+	kdt.build(kdt.points, 0)
+	return kdt
+}
+
+// build recursively sorts the points in place so that every median splits its subslice on the axis of its depth
+func (kdt *KDTree2D) build(points []Vec2f, depth int) {
+	if len(points) <= 1 {
+		return
+	}
+	if depth%2 == 0 {
+		sort.Slice(points, func(i, j int) bool { return points[i].X < points[j].X })
+	} else {
+		sort.Slice(points, func(i, j int) bool { return points[i].Y < points[j].Y })
+	}
+	mid := len(points) / 2
+	kdt.build(points[:mid], depth+1)
+	kdt.build(points[mid+1:], depth+1)
+}
+
+// rebuild restores the tree ordering if it was invalidated by insertions or removals
+func (kdt *KDTree2D) rebuild() {
+	kdt.mu.Lock()
+	defer kdt.mu.Unlock()
+	if kdt.dirty {
+		kdt.build(kdt.points, 0)
+		kdt.dirty = false
+	}
+}
+
+// Len returns the number of points stored in the tree
+func (kdt *KDTree2D) Len() int {
+	return len(kdt.points)
+}
+
+// InsertPoint adds the given point, the tree is rebuilt on the next query
+func (kdt *KDTree2D) InsertPoint(p Vec2f) {
+	kdt.points = append(kdt.points, p)
+	kdt.dirty = true
+}
+
+// InsertPoints adds the given points, the tree is rebuilt on the next query
+func (kdt *KDTree2D) InsertPoints(p []Vec2f) {
+	kdt.points = append(kdt.points, p...)
+	kdt.dirty = true
+}
+
+// RemovePoint removes one occurrence of the given point, returns false if it was not found
+// the tree is rebuilt on the next query
+func (kdt *KDTree2D) RemovePoint(p Vec2f) bool {
+	for i, kp := range kdt.points {
+		if kp != p {
+			continue
+		}
+		kdt.points[i] = kdt.points[len(kdt.points)-1]
+		kdt.points = kdt.points[:len(kdt.points)-1]
+		kdt.dirty = true
+		return true
+	}
+	return false
+}
+
+// kdAxisValue returns the coordinate of p on the splitting axis of the given depth
+func kdAxisValue(p Vec2f, depth int) float64 {
+	if depth%2 == 0 {
+		return p.X
+	}
+	return p.Y
+}
+
+// QueryRange returns all points of the tree inside the given region
+func (kdt *KDTree2D) QueryRange(x float64, y float64, w float64, h float64) (results []Vec2f) {
+	kdt.rebuild()
+	results = []Vec2f{}
+	var query func(points []Vec2f, depth int)
+	query = func(points []Vec2f, depth int) {
+		if len(points) == 0 {
+			return
+		}
+		mid := len(points) / 2
+		p := points[mid]
+		if p.X >= x && p.X <= x+w && p.Y >= y && p.Y <= y+h {
+			results = append(results, p)
+		}
+		lo, hi := x, x+w
+		if depth%2 == 1 {
+			lo, hi = y, y+h
+		}
+		v := kdAxisValue(p, depth)
+		if lo <= v {
+			query(points[:mid], depth+1)
+		}
+		if hi >= v {
+			query(points[mid+1:], depth+1)
+		}
+	}
+	query(kdt.points, 0)
+	return
+}
+
+// QueryRadius returns all points of the tree within distance r of the point p
+func (kdt *KDTree2D) QueryRadius(p Vec2f, r float64) (results []Vec2f) {
+	kdt.rebuild()
+	results = []Vec2f{}
+	var query func(points []Vec2f, depth int)
+	query = func(points []Vec2f, depth int) {
+		if len(points) == 0 {
+			return
+		}
+		mid := len(points) / 2
+		np := points[mid]
+		if math.Hypot(p.X-np.X, p.Y-np.Y) <= r {
+			results = append(results, np)
+		}
+		diff := kdAxisValue(p, depth) - kdAxisValue(np, depth)
+		if diff-r <= 0 {
+			query(points[:mid], depth+1)
+		}
+		if diff+r >= 0 {
+			query(points[mid+1:], depth+1)
+		}
+	}
+	query(kdt.points, 0)
+	return
+}
+
+// QueryKNN returns the k nearest neighbors to the given point p, sorted by ascending distance
+func (kdt *KDTree2D) QueryKNN(p Vec2f, k int) (results []Vec2f) {
+	kdt.rebuild()
+	if k <= 0 || len(kdt.points) == 0 {
+		return nil
+	}
+	type distPoint struct {
+		dist float64
+		p    Vec2f
+	}
+	best := make([]distPoint, 0, k+1) // sorted ascending, at most k entries
+	var query func(points []Vec2f, depth int)
+	query = func(points []Vec2f, depth int) {
+		if len(points) == 0 {
+			return
+		}
+		mid := len(points) / 2
+		np := points[mid]
+		d := math.Hypot(p.X-np.X, p.Y-np.Y)
+		if len(best) < k || d < best[len(best)-1].dist {
+			i := sort.Search(len(best), func(i int) bool { return best[i].dist > d })
+			best = append(best, distPoint{})
+			copy(best[i+1:], best[i:])
+			best[i] = distPoint{d, np}
+			if len(best) > k {
+				best = best[:k]
+			}
+		}
+		// descend into the side containing p first, the other side only if it can still hold closer points
+		diff := kdAxisValue(p, depth) - kdAxisValue(np, depth)
+		near, far := points[:mid], points[mid+1:]
+		if diff > 0 {
+			near, far = far, near
+		}
+		query(near, depth+1)
+		if len(best) < k || math.Abs(diff) < best[len(best)-1].dist {
+			query(far, depth+1)
+		}
+	}
+	query(kdt.points, 0)
+	results = make([]Vec2f, len(best))
+	for i, b := range best {
+		results[i] = b.p
+	}
+	return results
+}
+
+// Nearest returns the point in the tree closest to p, ok is false if the tree is empty
+func (kdt *KDTree2D) Nearest(p Vec2f) (nearest Vec2f, ok bool) {
+	knn := kdt.QueryKNN(p, 1)
+	if len(knn) == 0 {
+		return Vec2f{}, false
+	}
+	return knn[0], true
+}
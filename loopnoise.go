@@ -0,0 +1,75 @@
+package gah
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Loopable2D is a 2D texture that changes periodically over time, the frames at t and t+1 are equal
+type Loopable2D interface {
+	Frame(t float64) TextureCachable
+}
+
+// LoopingNoise2D animates a 4D source by walking a circle through its z and w dimensions, so one period of t returns to the start
+type LoopingNoise2D struct {
+	Source TextureCachable4D
+	Radius float64 // radius of the circle in z and w, larger values give more change over one loop
+}
+
+// NewLoopingNoise2D returns a looping animation of the source
+func NewLoopingNoise2D(source TextureCachable4D, radius float64) *LoopingNoise2D {
+	return &LoopingNoise2D{source, radius}
+}
+
+// EvalLoop returns the source at the given position at time t, with a period of 1
+func (ln *LoopingNoise2D) EvalLoop(x, y, t float64) float64 {
+	a := 2 * math.Pi * (t - math.Floor(t)) // wrap first, so that whole periods give exactly the same result
+	return ln.Source.Eval4(x, y, ln.Radius*math.Cos(a), ln.Radius*math.Sin(a))
+}
+
+// Frame returns the animation frozen at time t
+func (ln *LoopingNoise2D) Frame(t float64) TextureCachable {
+	return &LoopingNoiseFrame{ln, t}
+}
+
+// LoopingNoiseFrame is a single frame of a LoopingNoise2D, e.g. to cache it as a texture
+type LoopingNoiseFrame struct {
+	Loop *LoopingNoise2D
+	T    float64
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (lf *LoopingNoiseFrame) GetParamSignature() []byte {
+	t := lf.T - math.Floor(lf.T) // frames one period apart are equal
+	return graphSignature("loopframe", []float64{lf.Loop.Radius, t}, lf.Loop.Source)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2, which is the 4D range of the source
+func (lf *LoopingNoiseFrame) GetEvalRange() (outMin float64, outMax float64) {
+	return evalRangeN(lf.Loop.Source, 4)
+}
+
+// Eval2 returns the animation at the given position at the time of the frame
+func (lf *LoopingNoiseFrame) Eval2(x, y float64) float64 {
+	return lf.Loop.EvalLoop(x, y, lf.T)
+}
+
+// RenderLoopFrames renders one period of the animation as the given number of grayscale frames of the area (x, y) to (x+w, y+h)
+// frame i shows time i/frames, so playing the frames on repeat loops seamlessly
+func RenderLoopFrames(src Loopable2D, frames int, x int, y int, w int, h int) []*image.RGBA {
+	imgs := make([]*image.RGBA, frames)
+	for i := range imgs {
+		frame := src.Frame(float64(i) / float64(frames))
+		emin, emax := frame.GetEvalRange()
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		for iy := 0; iy < h; iy++ {
+			for ix := 0; ix < w; ix++ {
+				c := evalRangeToGray(frame.Eval2(float64(x+ix), float64(y+iy)), emin, emax)
+				img.SetRGBA(ix, iy, color.RGBA{c, c, c, 0xFF})
+			}
+		}
+		imgs[i] = img
+	}
+	return imgs
+}
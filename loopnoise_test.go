@@ -0,0 +1,73 @@
+package gah
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestLoopingNoise2D(t *testing.T) {
+	ln := NewLoopingNoise2D(NewCoherentNoise(2, 0.03, 3, 2, 0.5), 1.5)
+	for x := 0.0; x < 60; x += 7.1 {
+		for y := 0.0; y < 60; y += 6.7 {
+			for _, tt := range []float64{0, 0.25, 0.6, 0.99} {
+				v := ln.EvalLoop(x, y, tt)
+				for _, period := range []float64{1, 3, -2} {
+					if w := ln.EvalLoop(x, y, tt+period); math.Abs(w-v) > 1e-12 {
+						t.Fatalf("EvalLoop(%v, %v, %v) = %v, want the value %v at t = %v", x, y, tt+period, w, v, tt)
+					}
+				}
+				if w := ln.Frame(tt).Eval2(x, y); w != v {
+					t.Fatalf("Frame(%v).Eval2(%v, %v) = %v, want %v", tt, x, y, w, v)
+				}
+			}
+			// no jump when the loop wraps around
+			if d := math.Abs(ln.EvalLoop(x, y, 0.9999) - ln.EvalLoop(x, y, 0)); d > 0.01 {
+				t.Fatalf("loop jumps by %v at (%v, %v) when wrapping around", d, x, y)
+			}
+		}
+	}
+	frame := ln.Frame(0.25)
+	smin, smax := ln.Source.(*CoherentNoise).GetEvalRangeN(4)
+	if emin, emax := frame.GetEvalRange(); emin != smin || emax != smax {
+		t.Errorf("GetEvalRange() = [%v, %v], want the 4D range [%v, %v] of the source", emin, emax, smin, smax)
+	}
+	if !bytes.Equal(frame.GetParamSignature(), ln.Frame(2.25).GetParamSignature()) {
+		t.Errorf("frames one period apart have different signatures")
+	}
+	if bytes.Equal(frame.GetParamSignature(), ln.Frame(0.5).GetParamSignature()) {
+		t.Errorf("different frames have the same signature")
+	}
+}
+
+func TestRenderLoopFrames(t *testing.T) {
+	ln := NewLoopingNoise2D(NewCoherentNoise(6, 0.05, 2, 2, 0.5), 1)
+	const frames, w, h = 4, 12, 8
+	imgs := RenderLoopFrames(ln, frames, 20, -5, w, h)
+	if len(imgs) != frames {
+		t.Fatalf("got %d frames, want %d", len(imgs), frames)
+	}
+	// the frame after the last one is the first one again
+	wrapped := RenderLoopFrames(ln, 1, 20, -5, w, h)[0]
+	if !bytes.Equal(wrapped.Pix, imgs[0].Pix) {
+		t.Errorf("the first frame does not equal the frame at t = 0")
+	}
+	for i, img := range imgs {
+		if img.Bounds().Dx() != w || img.Bounds().Dy() != h {
+			t.Fatalf("frame %d has bounds %v", i, img.Bounds())
+		}
+		frame := ln.Frame(float64(i) / frames)
+		emin, emax := frame.GetEvalRange()
+		for iy := 0; iy < h; iy++ {
+			for ix := 0; ix < w; ix++ {
+				want := evalRangeToGray(frame.Eval2(float64(20+ix), float64(-5+iy)), emin, emax)
+				if c := img.RGBAAt(ix, iy); c.R != want || c.G != want || c.B != want || c.A != 0xFF {
+					t.Fatalf("frame %d pixel (%d, %d) = %v, want gray %d", i, ix, iy, c, want)
+				}
+			}
+		}
+		if i > 0 && bytes.Equal(img.Pix, imgs[i-1].Pix) {
+			t.Errorf("frame %d equals the frame before it", i)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package gah
+
+import "math"
+
+// DistanceMetric measures the length of an offset, e.g. for the distances of a VoronoiDiagram2D
+// the length must grow with the absolute value of both components, so that the closest of several wrapped copies is also the closest by the metric
+type DistanceMetric interface {
+	Distance(dx, dy float64) float64
+	GetParamSignature() []byte
+}
+
+var (
+	_ DistanceMetric = (*EuclideanMetric)(nil)
+	_ DistanceMetric = (*ManhattanMetric)(nil)
+	_ DistanceMetric = (*ChebyshevMetric)(nil)
+	_ DistanceMetric = (*MinkowskiMetric)(nil)
+	_ DistanceMetric = (*CustomMetric)(nil)
+)
+
+// euclideanBounder is implemented by metrics that can bound the euclidean length of all offsets within a given distance
+// lookups use this to find the closest sites through a spatial index, metrics without it are searched exhaustively
+type euclideanBounder interface {
+	euclideanBound(d float64) float64
+}
+
+// metricSignature returns the signature of a metric from its type tag and parameters
+func metricSignature(tag string, params ...float64) (signature []byte) {
+	signature = append(signature, IntToBytes(len(tag))...)
+	signature = append(signature, tag...)
+	for _, p := range params {
+		signature = append(signature, Float64ToBytes(p)...)
+	}
+	return signature
+}
+
+// EuclideanMetric is the straight line distance
+type EuclideanMetric struct{}
+
+// Distance returns the length of the offset
+func (m *EuclideanMetric) Distance(dx, dy float64) float64 {
+	return math.Hypot(dx, dy)
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (m *EuclideanMetric) GetParamSignature() []byte {
+	return metricSignature("euclidean")
+}
+
+func (m *EuclideanMetric) euclideanBound(d float64) float64 {
+	return d
+}
+
+// ManhattanMetric is the sum of the absolute components, giving diamond shaped distance fields
+type ManhattanMetric struct{}
+
+// Distance returns the length of the offset
+func (m *ManhattanMetric) Distance(dx, dy float64) float64 {
+	return math.Abs(dx) + math.Abs(dy)
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (m *ManhattanMetric) GetParamSignature() []byte {
+	return metricSignature("manhattan")
+}
+
+func (m *ManhattanMetric) euclideanBound(d float64) float64 {
+	return d
+}
+
+// ChebyshevMetric is the largest absolute component, giving square shaped distance fields
+type ChebyshevMetric struct{}
+
+// Distance returns the length of the offset
+func (m *ChebyshevMetric) Distance(dx, dy float64) float64 {
+	return math.Max(math.Abs(dx), math.Abs(dy))
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (m *ChebyshevMetric) GetParamSignature() []byte {
+	return metricSignature("chebyshev")
+}
+
+func (m *ChebyshevMetric) euclideanBound(d float64) float64 {
+	return d * math.Sqrt2
+}
+
+// MinkowskiMetric is the p-norm, p = 1 is manhattan, p = 2 euclidean and growing p approach chebyshev
+// p below 1 is not a metric anymore, but still gives usable star shaped distance fields
+type MinkowskiMetric struct {
+	P float64
+}
+
+// Distance returns the length of the offset
+func (m *MinkowskiMetric) Distance(dx, dy float64) float64 {
+	return math.Pow(math.Pow(math.Abs(dx), m.P)+math.Pow(math.Abs(dy), m.P), 1/m.P)
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (m *MinkowskiMetric) GetParamSignature() []byte {
+	return metricSignature("minkowski", m.P)
+}
+
+func (m *MinkowskiMetric) euclideanBound(d float64) float64 {
+	if m.P <= 2 {
+		return d
+	}
+	return d * math.Pow(2, 0.5-1/m.P)
+}
+
+// CustomMetric measures distances with the given function, the name identifies it in the signature and has to be unique for every function
+type CustomMetric struct {
+	Name string
+	Func func(dx, dy float64) float64
+}
+
+// Distance returns the length of the offset
+func (m *CustomMetric) Distance(dx, dy float64) float64 {
+	return m.Func(dx, dy)
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (m *CustomMetric) GetParamSignature() []byte {
+	return metricSignature("custom:" + m.Name)
+}
@@ -0,0 +1,66 @@
+package gah
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDistanceMetrics(t *testing.T) {
+	tests := []struct {
+		metric DistanceMetric
+		dx, dy float64
+		want   float64
+	}{
+		{&EuclideanMetric{}, 3, -4, 5},
+		{&ManhattanMetric{}, 3, -4, 7},
+		{&ChebyshevMetric{}, 3, -4, 4},
+		{&MinkowskiMetric{1}, 3, -4, 7},
+		{&MinkowskiMetric{2}, 3, -4, 5},
+		{&MinkowskiMetric{3}, 1, 1, math.Cbrt(2)},
+		{&MinkowskiMetric{0.5}, 1, 4, 9},
+		{&MinkowskiMetric{1}, 0, 0, 0},
+		{&CustomMetric{"double", func(dx, dy float64) float64 { return 2 * math.Hypot(dx, dy) }}, 3, 4, 10},
+	}
+	for _, tt := range tests {
+		if got := tt.metric.Distance(tt.dx, tt.dy); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%T%v.Distance(%v, %v) = %v, want %v", tt.metric, tt.metric, tt.dx, tt.dy, got, tt.want)
+		}
+	}
+}
+
+func TestDistanceMetricEuclideanBound(t *testing.T) {
+	// every offset with a distance of at most d by the metric has a euclidean length of at most the bound of d
+	metrics := []DistanceMetric{&EuclideanMetric{}, &ManhattanMetric{}, &ChebyshevMetric{}, &MinkowskiMetric{0.5}, &MinkowskiMetric{1.5}, &MinkowskiMetric{3}, &MinkowskiMetric{20}}
+	rng := rand.New(rand.NewSource(1))
+	for _, metric := range metrics {
+		bounder := metric.(euclideanBounder)
+		for i := 0; i < 1000; i++ {
+			a := rng.Float64() * 2 * math.Pi
+			dx, dy := math.Cos(a), math.Sin(a)
+			d := metric.Distance(dx, dy)
+			if bound := bounder.euclideanBound(d); 1 > bound*(1+1e-12) {
+				t.Fatalf("%T%v: offset (%v, %v) has distance %v, but a euclidean bound of only %v", metric, metric, dx, dy, d, bound)
+			}
+		}
+	}
+	if _, ok := DistanceMetric(&CustomMetric{}).(euclideanBounder); ok {
+		t.Errorf("custom metrics can not bound the euclidean distance")
+	}
+}
+
+func TestDistanceMetricSignatures(t *testing.T) {
+	metrics := []DistanceMetric{
+		&EuclideanMetric{}, &ManhattanMetric{}, &ChebyshevMetric{},
+		&MinkowskiMetric{1}, &MinkowskiMetric{2}, &MinkowskiMetric{3},
+		&CustomMetric{"a", math.Hypot}, &CustomMetric{"b", math.Hypot},
+	}
+	seen := map[string]int{}
+	for i, metric := range metrics {
+		sig := string(metric.GetParamSignature())
+		if j, ok := seen[sig]; ok {
+			t.Errorf("metrics %d and %d share a signature", j, i)
+		}
+		seen[sig] = i
+	}
+}
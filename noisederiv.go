@@ -0,0 +1,361 @@
+package gah
+
+import "math"
+
+// NoiseDeriv is a Noise that can also provide its analytic partial derivatives
+// CoherentNoise uses them if its base noise implements this, and falls back to central differences otherwise
+type NoiseDeriv interface {
+	Noise
+	Eval2Deriv(x, y float64) (v float64, dx float64, dy float64)
+	Eval3Deriv(x, y, z float64) (v float64, dx float64, dy float64, dz float64)
+}
+
+var (
+	_ NoiseDeriv = (*OpenSimplexNoise)(nil)
+	_ NoiseDeriv = (*PerlinNoise)(nil)
+	_ NoiseDeriv = (*ValueNoise)(nil)
+	_ NoiseDeriv = (*WorleyNoise)(nil)
+	_ NoiseDeriv = (*WhiteNoise)(nil)
+)
+
+// NoiseDerivEpsilon is the step used for the central differences of base noises without analytic derivatives
+const NoiseDerivEpsilon = 1e-4
+
+// fadeDeriv is the derivative of fade
+func fadeDeriv(t float64) float64 {
+	return 30 * t * t * (t*(t-2) + 1)
+}
+
+// latticeEvalDeriv works as latticeEval does, but also returns the partial derivatives
+// corner additionally returns the partial derivatives of its value with respect to p
+func latticeEvalDeriv(perm *latticePerm, p [4]float64, n int, corner func(h int, d [4]float64) (float64, [4]float64)) (float64, [4]float64) {
+	var cell [4]int
+	var f, u, du [4]float64
+	for i := 0; i < n; i++ {
+		fl := math.Floor(p[i])
+		cell[i] = int(fl)
+		f[i] = p[i] - fl
+		u[i] = fade(f[i])
+		du[i] = fadeDeriv(f[i])
+	}
+	sum := 0.0
+	var deriv [4]float64
+	for c := 0; c < 1<<n; c++ {
+		var cc [4]int
+		var d, factor, dfactor [4]float64
+		for i := 0; i < n; i++ {
+			if c&(1<<i) == 0 {
+				cc[i], d[i] = cell[i], f[i]
+				factor[i], dfactor[i] = 1-u[i], -du[i]
+			} else {
+				cc[i], d[i] = cell[i]+1, f[i]-1
+				factor[i], dfactor[i] = u[i], du[i]
+			}
+		}
+		v, dv := corner(perm.hash(cc, n), d)
+		weight := 1.0
+		for i := 0; i < n; i++ {
+			weight *= factor[i]
+		}
+		sum += weight * v
+		for i := 0; i < n; i++ {
+			// product rule over the interpolation weight, with the factor of axis i replaced by its derivative
+			dweight := dfactor[i]
+			for j := 0; j < n; j++ {
+				if j != i {
+					dweight *= factor[j]
+				}
+			}
+			deriv[i] += dweight*v + weight*dv[i]
+		}
+	}
+	return sum, deriv
+}
+
+// evalDeriv returns the noise and its partial derivatives at p, only the first n coordinates are used
+func (pn *PerlinNoise) evalDeriv(p [4]float64, n int) (float64, [4]float64) {
+	v, deriv := latticeEvalDeriv(pn.perm, p, n, func(h int, d [4]float64) (float64, [4]float64) {
+		g := perlinGradient(h, n)
+		return g[0]*d[0] + g[1]*d[1] + g[2]*d[2] + g[3]*d[3], g
+	})
+	for i := range deriv {
+		deriv[i] *= perlinNormalization[n]
+	}
+	return v * perlinNormalization[n], deriv
+}
+
+// Eval2Deriv returns the noise and its partial derivatives at the given position
+func (pn *PerlinNoise) Eval2Deriv(x, y float64) (v float64, dx float64, dy float64) {
+	v, deriv := pn.evalDeriv([4]float64{x, y}, 2)
+	return v, deriv[0], deriv[1]
+}
+
+// Eval3Deriv returns the noise and its partial derivatives at the given position
+func (pn *PerlinNoise) Eval3Deriv(x, y, z float64) (v float64, dx float64, dy float64, dz float64) {
+	v, deriv := pn.evalDeriv([4]float64{x, y, z}, 3)
+	return v, deriv[0], deriv[1], deriv[2]
+}
+
+// evalDeriv returns the noise and its partial derivatives at p, only the first n coordinates are used
+func (vn *ValueNoise) evalDeriv(p [4]float64, n int) (float64, [4]float64) {
+	return latticeEvalDeriv(vn.perm, p, n, func(h int, d [4]float64) (float64, [4]float64) {
+		return float64(h)/127.5 - 1, [4]float64{}
+	})
+}
+
+// Eval2Deriv returns the noise and its partial derivatives at the given position
+func (vn *ValueNoise) Eval2Deriv(x, y float64) (v float64, dx float64, dy float64) {
+	v, deriv := vn.evalDeriv([4]float64{x, y}, 2)
+	return v, deriv[0], deriv[1]
+}
+
+// Eval3Deriv returns the noise and its partial derivatives at the given position
+func (vn *ValueNoise) Eval3Deriv(x, y, z float64) (v float64, dx float64, dy float64, dz float64) {
+	v, deriv := vn.evalDeriv([4]float64{x, y, z}, 3)
+	return v, deriv[0], deriv[1], deriv[2]
+}
+
+// evalDeriv returns the noise and its partial derivatives at p, only the first n coordinates are used
+// the distance grows away from the feature point, where the distance is clamped the derivatives are 0
+func (wn *WorleyNoise) evalDeriv(p [4]float64, n int) (float64, [4]float64) {
+	dist, delta := wn.nearest(p, n)
+	if dist >= 1 {
+		return 1, [4]float64{}
+	}
+	var deriv [4]float64
+	if dist > 0 {
+		for i := 0; i < n; i++ {
+			deriv[i] = 2 * delta[i] / dist
+		}
+	}
+	return 2*dist - 1, deriv
+}
+
+// Eval2Deriv returns the noise and its partial derivatives at the given position
+func (wn *WorleyNoise) Eval2Deriv(x, y float64) (v float64, dx float64, dy float64) {
+	v, deriv := wn.evalDeriv([4]float64{x, y}, 2)
+	return v, deriv[0], deriv[1]
+}
+
+// Eval3Deriv returns the noise and its partial derivatives at the given position
+func (wn *WorleyNoise) Eval3Deriv(x, y, z float64) (v float64, dx float64, dy float64, dz float64) {
+	v, deriv := wn.evalDeriv([4]float64{x, y, z}, 3)
+	return v, deriv[0], deriv[1], deriv[2]
+}
+
+// Eval2Deriv returns the noise and its partial derivatives at the given position, which are 0 as the noise is constant inside of every cell
+func (wn *WhiteNoise) Eval2Deriv(x, y float64) (v float64, dx float64, dy float64) {
+	return wn.Eval2(x, y), 0, 0
+}
+
+// Eval3Deriv returns the noise and its partial derivatives at the given position, which are 0 as the noise is constant inside of every cell
+func (wn *WhiteNoise) Eval3Deriv(x, y, z float64) (v float64, dx float64, dy float64, dz float64) {
+	return wn.Eval3(x, y, z), 0, 0, 0
+}
+
+// baseEval2Deriv returns the base noise and its partial derivatives, using central differences if it has no analytic ones
+func (cnoise *CoherentNoise) baseEval2Deriv(x, y float64) (float64, [3]float64) {
+	if nd, ok := cnoise.Noise.(NoiseDeriv); ok {
+		v, dx, dy := nd.Eval2Deriv(x, y)
+		return v, [3]float64{dx, dy}
+	}
+	const e = NoiseDerivEpsilon
+	dx := (cnoise.Noise.Eval2(x+e, y) - cnoise.Noise.Eval2(x-e, y)) / (2 * e)
+	dy := (cnoise.Noise.Eval2(x, y+e) - cnoise.Noise.Eval2(x, y-e)) / (2 * e)
+	return cnoise.Noise.Eval2(x, y), [3]float64{dx, dy}
+}
+
+// baseEval3Deriv returns the base noise and its partial derivatives, using central differences if it has no analytic ones
+func (cnoise *CoherentNoise) baseEval3Deriv(x, y, z float64) (float64, [3]float64) {
+	if nd, ok := cnoise.Noise.(NoiseDeriv); ok {
+		v, dx, dy, dz := nd.Eval3Deriv(x, y, z)
+		return v, [3]float64{dx, dy, dz}
+	}
+	const e = NoiseDerivEpsilon
+	dx := (cnoise.Noise.Eval3(x+e, y, z) - cnoise.Noise.Eval3(x-e, y, z)) / (2 * e)
+	dy := (cnoise.Noise.Eval3(x, y+e, z) - cnoise.Noise.Eval3(x, y-e, z)) / (2 * e)
+	dz := (cnoise.Noise.Eval3(x, y, z+e) - cnoise.Noise.Eval3(x, y, z-e)) / (2 * e)
+	return cnoise.Noise.Eval3(x, y, z), [3]float64{dx, dy, dz}
+}
+
+// layerDeriv works as layer does, but also accumulates the partial derivatives returned by sample
+// the chain rule scales the derivatives of every octave by its frequency
+func (cnoise *CoherentNoise) layerDeriv(sample func(freq float64) (float64, [3]float64)) (float64, [3]float64) {
+	var acc fractalAccumulator = newFractalAccumulator(cnoise.Mode)
+	var amp float64 = 1
+	var freq float64 = cnoise.Scale
+	for i := 0; i < cnoise.Octaves; i++ {
+		n, dn := sample(freq)
+		acc.addDeriv(n, [3]float64{dn[0] * freq, dn[1] * freq, dn[2] * freq}, amp)
+		amp *= cnoise.Persistence
+		freq *= cnoise.Lacunarity
+	}
+	return acc.result(), acc.resultDeriv()
+}
+
+// Eval2Deriv works as Eval2 does, but also returns the partial derivatives of the layered noise
+func (cnoise *CoherentNoise) Eval2Deriv(x, y float64) (v float64, dx float64, dy float64) {
+	v, deriv := cnoise.layerDeriv(func(freq float64) (float64, [3]float64) {
+		return cnoise.baseEval2Deriv(x*freq, y*freq)
+	})
+	v, slope := cnoise.outputDeriv(v, 2)
+	return v, deriv[0] * slope, deriv[1] * slope
+}
+
+// Eval3Deriv works as Eval3 does, but also returns the partial derivatives of the layered noise
+func (cnoise *CoherentNoise) Eval3Deriv(x, y, z float64) (v float64, dx float64, dy float64, dz float64) {
+	v, deriv := cnoise.layerDeriv(func(freq float64) (float64, [3]float64) {
+		return cnoise.baseEval3Deriv(x*freq, y*freq, z*freq)
+	})
+	v, slope := cnoise.outputDeriv(v, 3)
+	return v, deriv[0] * slope, deriv[1] * slope, deriv[2] * slope
+}
+
+// openSimplexPerm holds the same permutation tables that the opensimplex package derives from a seed
+// they are needed to find the gradients of the lattice vertices, which the package does not expose
+type openSimplexPerm struct {
+	perm            [256]int
+	permGradIndex3D [256]int
+}
+
+// newOpenSimplexPerm returns the permutation tables of opensimplex.New for the given seed
+func newOpenSimplexPerm(seed int64) *openSimplexPerm {
+	var p openSimplexPerm
+	var source [256]int
+	for i := range source {
+		source[i] = i
+	}
+	seed = seed*6364136223846793005 + 1442695040888963407
+	seed = seed*6364136223846793005 + 1442695040888963407
+	seed = seed*6364136223846793005 + 1442695040888963407
+	for i := int64(255); i >= 0; i-- {
+		seed = seed*6364136223846793005 + 1442695040888963407
+		r := (seed + 31) % (i + 1)
+		if r < 0 {
+			r += i + 1
+		}
+		p.perm[i] = source[r]
+		p.permGradIndex3D[i] = p.perm[i] % (len(openSimplexGradients3D) / 3) * 3
+		source[r] = source[i]
+	}
+	return &p
+}
+
+// openSimplexGradients2D and openSimplexGradients3D are the gradient tables of the opensimplex package
+var openSimplexGradients2D = [16]float64{
+	5, 2, 2, 5,
+	-5, 2, -2, 5,
+	5, -2, 2, -5,
+	-5, -2, -2, -5,
+}
+
+var openSimplexGradients3D = [72]float64{
+	-11, 4, 4, -4, 11, 4, -4, 4, 11,
+	11, 4, 4, 4, 11, 4, 4, 4, 11,
+	-11, -4, 4, -4, -11, 4, -4, -4, 11,
+	11, -4, 4, 4, -11, 4, 4, -4, 11,
+	-11, 4, -4, -4, 11, -4, -4, 4, -11,
+	11, 4, -4, 4, 11, -4, 4, 4, -11,
+	-11, -4, -4, -4, -11, -4, -4, -4, -11,
+	11, -4, -4, 4, -11, -4, 4, -4, -11,
+}
+
+// opensimplex constants, see the opensimplex package
+const (
+	openSimplexStretch2D = -0.211324865405187
+	openSimplexSquish2D  = 0.366025403784439
+	openSimplexNorm2D    = 47
+	openSimplexStretch3D = -1.0 / 6
+	openSimplexSquish3D  = 1.0 / 3
+	openSimplexNorm3D    = 103
+)
+
+// openSimplexOffsets2D and openSimplexOffsets3D are the offsets from the stretched base cell of all lattice vertices that can have a positive kernel
+// these are the offsets with components in [-1, 2] that sum up to [0, n] and differ from each other by at most 2
+var (
+	openSimplexOffsets2D = openSimplexCandidateOffsets(2)
+	openSimplexOffsets3D = openSimplexCandidateOffsets(3)
+)
+
+// openSimplexCandidateOffsets returns the offsets of openSimplexOffsets2D or openSimplexOffsets3D for n dimensions, unused components are 0
+func openSimplexCandidateOffsets(n int) (offsets [][3]int) {
+	for c := 0; c < 1<<(2*n); c++ {
+		var offset [3]int
+		sum, min, max := 0, 2, -1
+		for i, r := 0, c; i < n; i, r = i+1, r>>2 {
+			offset[i] = r&3 - 1
+			sum += offset[i]
+			if offset[i] < min {
+				min = offset[i]
+			}
+			if offset[i] > max {
+				max = offset[i]
+			}
+		}
+		if sum >= 0 && sum <= n && max-min <= 2 {
+			offsets = append(offsets, offset)
+		}
+	}
+	return offsets
+}
+
+// opensimplex noise is the sum of the kernels (2 - |d|^2)^4 * (g . d) of all lattice vertices within the kernel radius,
+// so instead of choosing the contributing vertices by region as the opensimplex package does, the sums below go over all candidate vertices around p
+// in 2D this is exactly the value of the opensimplex package, in 3D the package misses a few vertices right at the edge of their kernel,
+// which makes its value jump by up to about 1e-4 there, so Eval3 is replaced by the full sum to match its derivatives
+
+// Eval2Deriv returns the noise and its partial derivatives at the given position
+func (osn *OpenSimplexNoise) Eval2Deriv(x, y float64) (v float64, dx float64, dy float64) {
+	stretchOffset := (x + y) * openSimplexStretch2D
+	xsb, ysb := int(math.Floor(x+stretchOffset)), int(math.Floor(y+stretchOffset))
+	for _, offset := range openSimplexOffsets2D {
+		vx, vy := xsb+offset[0], ysb+offset[1]
+		squishOffset := float64(vx+vy) * openSimplexSquish2D
+		ddx, ddy := x-float64(vx)-squishOffset, y-float64(vy)-squishOffset
+		attn := 2 - ddx*ddx - ddy*ddy
+		if attn <= 0 {
+			continue
+		}
+		index := osn.perm.perm[(osn.perm.perm[vx&0xFF]+vy)&0xFF] & 0x0E
+		gx, gy := openSimplexGradients2D[index], openSimplexGradients2D[index+1]
+		extrapolation := gx*ddx + gy*ddy
+		attn2 := attn * attn
+		v += attn2 * attn2 * extrapolation
+		// product rule over the falloff (2 - |d|^2)^4 and the extrapolation g . d
+		falloffDeriv := -8 * attn2 * attn * extrapolation
+		dx += falloffDeriv*ddx + attn2*attn2*gx
+		dy += falloffDeriv*ddy + attn2*attn2*gy
+	}
+	return v / openSimplexNorm2D, dx / openSimplexNorm2D, dy / openSimplexNorm2D
+}
+
+// Eval3Deriv returns the noise and its partial derivatives at the given position
+func (osn *OpenSimplexNoise) Eval3Deriv(x, y, z float64) (v float64, dx float64, dy float64, dz float64) {
+	stretchOffset := (x + y + z) * openSimplexStretch3D
+	xsb, ysb, zsb := int(math.Floor(x+stretchOffset)), int(math.Floor(y+stretchOffset)), int(math.Floor(z+stretchOffset))
+	for _, offset := range openSimplexOffsets3D {
+		vx, vy, vz := xsb+offset[0], ysb+offset[1], zsb+offset[2]
+		squishOffset := float64(vx+vy+vz) * openSimplexSquish3D
+		ddx, ddy, ddz := x-float64(vx)-squishOffset, y-float64(vy)-squishOffset, z-float64(vz)-squishOffset
+		attn := 2 - ddx*ddx - ddy*ddy - ddz*ddz
+		if attn <= 0 {
+			continue
+		}
+		index := osn.perm.permGradIndex3D[(osn.perm.perm[(osn.perm.perm[vx&0xFF]+vy)&0xFF]+vz)&0xFF]
+		gx, gy, gz := openSimplexGradients3D[index], openSimplexGradients3D[index+1], openSimplexGradients3D[index+2]
+		extrapolation := gx*ddx + gy*ddy + gz*ddz
+		attn2 := attn * attn
+		v += attn2 * attn2 * extrapolation
+		falloffDeriv := -8 * attn2 * attn * extrapolation
+		dx += falloffDeriv*ddx + attn2*attn2*gx
+		dy += falloffDeriv*ddy + attn2*attn2*gy
+		dz += falloffDeriv*ddz + attn2*attn2*gz
+	}
+	return v / openSimplexNorm3D, dx / openSimplexNorm3D, dy / openSimplexNorm3D, dz / openSimplexNorm3D
+}
+
+// Eval3 returns the noise at the given position, as the full kernel sum of Eval3Deriv
+func (osn *OpenSimplexNoise) Eval3(x, y, z float64) float64 {
+	v, _, _, _ := osn.Eval3Deriv(x, y, z)
+	return v
+}
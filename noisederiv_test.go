@@ -0,0 +1,173 @@
+package gah
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// derivEpsilon is the step of the central differences the analytic derivatives are compared against
+const derivEpsilon = 1e-6
+
+// checkDeriv compares the analytic partial derivatives of f at p against central differences over the first n axes
+// positions at which f has a kink, e.g. at the cell edges of worley noise or where abs flips its sign, are skipped
+// returns whether p was checked
+func checkDeriv(t *testing.T, name string, f func(p [3]float64) float64, p [3]float64, n int, v float64, deriv [3]float64) bool {
+	t.Helper()
+	if want := f(p); math.Abs(v-want) > 1e-12 {
+		t.Fatalf("%s: value at %v is %v, want %v", name, p, v, want)
+	}
+	for i := 0; i < n; i++ {
+		lo, hi := p, p
+		lo[i] -= derivEpsilon
+		hi[i] += derivEpsilon
+		left, right := (v-f(lo))/derivEpsilon, (f(hi)-v)/derivEpsilon
+		if math.Abs(left-right) > 1e-3*(1+math.Abs(left)) {
+			return false
+		}
+	}
+	for i := 0; i < n; i++ {
+		lo, hi := p, p
+		lo[i] -= derivEpsilon
+		hi[i] += derivEpsilon
+		want := (f(hi) - f(lo)) / (2 * derivEpsilon)
+		if math.Abs(deriv[i]-want) > 1e-4*(1+math.Abs(want)) {
+			t.Fatalf("%s: derivative along axis %d at %v is %v, want %v", name, i, p, deriv[i], want)
+		}
+	}
+	return true
+}
+
+// checkDerivs runs checkDeriv at many random positions and fails if too many of them are skipped as kinks
+func checkDerivs(t *testing.T, name string, n int, scale float64, f func(p [3]float64) float64, deriv func(p [3]float64) (float64, [3]float64)) {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	checked := 0
+	const samples = 400
+	for s := 0; s < samples; s++ {
+		var p [3]float64
+		for i := 0; i < n; i++ {
+			p[i] = (rng.Float64()*20 - 10) * scale
+		}
+		v, d := deriv(p)
+		if checkDeriv(t, name, f, p, n, v, d) {
+			checked++
+		}
+	}
+	if checked < samples*9/10 {
+		t.Errorf("%s: only %d of %d positions were checked, the rest lie on kinks", name, checked, samples)
+	}
+}
+
+func TestBaseNoiseDerivatives(t *testing.T) {
+	for _, bn := range baseNoises {
+		nd := bn.new(3).(NoiseDeriv)
+		checkDerivs(t, bn.name+" 2D", 2, 1, func(p [3]float64) float64 {
+			return nd.Eval2(p[0], p[1])
+		}, func(p [3]float64) (float64, [3]float64) {
+			v, dx, dy := nd.Eval2Deriv(p[0], p[1])
+			return v, [3]float64{dx, dy}
+		})
+		checkDerivs(t, bn.name+" 3D", 3, 1, func(p [3]float64) float64 {
+			return nd.Eval3(p[0], p[1], p[2])
+		}, func(p [3]float64) (float64, [3]float64) {
+			v, dx, dy, dz := nd.Eval3Deriv(p[0], p[1], p[2])
+			return v, [3]float64{dx, dy, dz}
+		})
+	}
+}
+
+func TestOpenSimplexPerm(t *testing.T) {
+	// the gradients are looked up through permutation tables rebuilt from the seed, any difference to the package shows up in the value
+	for _, seed := range []int64{0, 1, -7, 123456789} {
+		osn := NewOpenSimplexNoise(seed)
+		rng := rand.New(rand.NewSource(seed))
+		exact3 := 0
+		const samples = 2000
+		for s := 0; s < samples; s++ {
+			x, y, z := rng.Float64()*600-300, rng.Float64()*600-300, rng.Float64()*600-300
+			if v, _, _ := osn.Eval2Deriv(x, y); math.Abs(v-osn.Noise.Eval2(x, y)) > 1e-12 {
+				t.Fatalf("seed %d: Eval2Deriv(%v, %v) = %v, want the package value %v", seed, x, y, v, osn.Noise.Eval2(x, y))
+			}
+			// the package drops a few vertices at the edge of their kernel in 3D, which changes the value only slightly
+			d := math.Abs(osn.Eval3(x, y, z) - osn.Noise.Eval3(x, y, z))
+			if d > 2e-4 {
+				t.Fatalf("seed %d: Eval3(%v, %v, %v) = %v, want about the package value %v", seed, x, y, z, osn.Eval3(x, y, z), osn.Noise.Eval3(x, y, z))
+			}
+			if d < 1e-12 {
+				exact3++
+			}
+		}
+		if exact3 < samples*9/10 {
+			t.Errorf("seed %d: only %d of %d values match the package exactly in 3D", seed, exact3, samples)
+		}
+	}
+}
+
+// noDerivNoise hides the analytic derivatives of its base noise, so CoherentNoise falls back to central differences
+type noDerivNoise struct {
+	Noise
+}
+
+func TestCoherentNoiseDerivatives(t *testing.T) {
+	bases := []struct {
+		name  string
+		noise Noise
+	}{
+		{"perlin", NewPerlinNoise(5)},
+		{"value", NewValueNoise(5)},
+		{"central differences", noDerivNoise{NewPerlinNoise(5)}},
+	}
+	for _, base := range bases {
+		for _, m := range fractalModes {
+			cnoise := NewCoherentNoiseWithBase(base.noise, 0.05, 4, 2, 0.5)
+			cnoise.Mode = m.mode
+			name := base.name + " " + m.name
+			// central differences of the base noise are less precise, so they are compared with a wider step
+			tolerance := 1.0
+			if base.name == "central differences" {
+				tolerance = 1e3
+			}
+			rng := rand.New(rand.NewSource(2))
+			for s := 0; s < 200; s++ {
+				x, y, z := rng.Float64()*200-100, rng.Float64()*200-100, rng.Float64()*200-100
+				v2, dx2, dy2 := cnoise.Eval2Deriv(x, y)
+				v3, dx3, dy3, dz3 := cnoise.Eval3Deriv(x, y, z)
+				if v2 != cnoise.Eval2(x, y) || v3 != cnoise.Eval3(x, y, z) {
+					t.Fatalf("%s: value at (%v, %v, %v) differs from Eval2 or Eval3", name, x, y, z)
+				}
+				const e = 1e-5
+				fd := [5]float64{
+					(cnoise.Eval2(x+e, y) - cnoise.Eval2(x-e, y)) / (2 * e),
+					(cnoise.Eval2(x, y+e) - cnoise.Eval2(x, y-e)) / (2 * e),
+					(cnoise.Eval3(x+e, y, z) - cnoise.Eval3(x-e, y, z)) / (2 * e),
+					(cnoise.Eval3(x, y+e, z) - cnoise.Eval3(x, y-e, z)) / (2 * e),
+					(cnoise.Eval3(x, y, z+e) - cnoise.Eval3(x, y, z-e)) / (2 * e),
+				}
+				got := [5]float64{dx2, dy2, dx3, dy3, dz3}
+				for i := range got {
+					if math.Abs(got[i]-fd[i]) > tolerance*1e-6+1e-3*math.Abs(fd[i]) && !coherentNoiseKink(cnoise, x, y, z) {
+						t.Fatalf("%s: derivative %d at (%v, %v, %v) is %v, want %v", name, i, x, y, z, got[i], fd[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+// coherentNoiseKink reports whether the value of the noise has a kink near the position, where finite differences are meaningless
+func coherentNoiseKink(cnoise *CoherentNoise, x, y, z float64) bool {
+	const e = 1e-5
+	for _, d := range [][3]float64{{e, 0, 0}, {0, e, 0}, {0, 0, e}} {
+		c := cnoise.Eval3(x, y, z)
+		left := (c - cnoise.Eval3(x-d[0], y-d[1], z-d[2])) / e
+		right := (cnoise.Eval3(x+d[0], y+d[1], z+d[2]) - c) / e
+		c2 := cnoise.Eval2(x, y)
+		left2 := (c2 - cnoise.Eval2(x-d[0], y-d[1])) / e
+		right2 := (cnoise.Eval2(x+d[0], y+d[1]) - c2) / e
+		if math.Abs(left-right) > 1e-3*(1+math.Abs(left)) || math.Abs(left2-right2) > 1e-3*(1+math.Abs(left2)) {
+			return true
+		}
+	}
+	return false
+}
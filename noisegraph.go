@@ -0,0 +1,547 @@
+package gah
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"sync/atomic"
+)
+
+// the noise graph is built from generator, combiner and modifier nodes which all are TextureCachable themselves,
+// CoherentNoise and VoronoiDiagram2D can be used as generators directly
+
+var (
+	_ TextureCachable = (*NoiseConst)(nil)
+	_ TextureCachable = (*NoiseGradient)(nil)
+	_ TextureCachable = (*NoiseAdd)(nil)
+	_ TextureCachable = (*NoiseMultiply)(nil)
+	_ TextureCachable = (*NoiseMin)(nil)
+	_ TextureCachable = (*NoiseMax)(nil)
+	_ TextureCachable = (*NoiseBlend)(nil)
+	_ TextureCachable = (*NoiseSelect)(nil)
+	_ TextureCachable = (*NoiseAbs)(nil)
+	_ TextureCachable = (*NoiseClamp)(nil)
+	_ TextureCachable = (*NoiseCurve)(nil)
+	_ TextureCachable = (*NoiseTerrace)(nil)
+	_ TextureCachable = (*NoiseInvert)(nil)
+	_ TextureCachable = (*NoiseScaleBias)(nil)
+)
+
+// graphSignature builds the signature of a graph node from its type tag, its own parameters and the signatures of its sources
+// all parts are length prefixed, so that different graphs can not produce the same signature
+func graphSignature(tag string, params []float64, sources ...TextureCachable) (signature []byte) {
+	signature = append(signature, IntToBytes(len(tag))...)
+	signature = append(signature, tag...)
+	signature = append(signature, IntToBytes(len(params))...)
+	for _, param := range params {
+		signature = append(signature, Float64ToBytes(param)...)
+	}
+	for _, src := range sources {
+		srcSignature := src.GetParamSignature()
+		signature = append(signature, IntToBytes(len(srcSignature))...)
+		signature = append(signature, srcSignature...)
+	}
+	return signature
+}
+
+// evalRangeCache remembers the eval range of a source, so that nodes scaling by it do not look it up again for every pixel
+// the range is looked up again when the node is pointed at another source, but not when the parameters of the same source change
+type evalRangeCache struct {
+	last atomic.Value // *evalRangeEntry
+}
+
+// evalRangeEntry is the eval range of the source
+type evalRangeEntry struct {
+	source         TextureCachable
+	outMin, outMax float64
+}
+
+// get returns the eval range of the source, nodes created without a constructor have no cache and look it up every time
+func (c *evalRangeCache) get(source TextureCachable) (outMin float64, outMax float64) {
+	if c == nil {
+		return source.GetEvalRange()
+	}
+	// only comparable sources are cached, and those compare without panicking against any other source
+	if last, ok := c.last.Load().(*evalRangeEntry); ok && last.source == source {
+		return last.outMin, last.outMax
+	}
+	outMin, outMax = source.GetEvalRange()
+	if reflect.TypeOf(source).Comparable() {
+		c.last.Store(&evalRangeEntry{source, outMin, outMax})
+	}
+	return outMin, outMax
+}
+
+// NoiseConst is a generator that returns the same value everywhere
+type NoiseConst struct {
+	Value float64
+}
+
+// NewNoiseConst returns a generator with the constant value v
+func NewNoiseConst(v float64) *NoiseConst {
+	return &NoiseConst{v}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseConst) GetParamSignature() []byte {
+	return graphSignature("const", []float64{n.Value})
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseConst) GetEvalRange() (outMin float64, outMax float64) {
+	return n.Value, n.Value
+}
+
+// Eval2 returns the constant value
+func (n *NoiseConst) Eval2(x, y float64) float64 {
+	return n.Value
+}
+
+// NoiseGradient is a generator for a linear gradient, 0 at (X0, Y0) rising to 1 at (X1, Y1), clamped beyond
+type NoiseGradient struct {
+	X0, Y0, X1, Y1 float64
+}
+
+// NewNoiseGradient returns a linear gradient generator from (x0, y0) to (x1, y1)
+func NewNoiseGradient(x0 float64, y0 float64, x1 float64, y1 float64) *NoiseGradient {
+	return &NoiseGradient{x0, y0, x1, y1}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseGradient) GetParamSignature() []byte {
+	return graphSignature("gradient", []float64{n.X0, n.Y0, n.X1, n.Y1})
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseGradient) GetEvalRange() (outMin float64, outMax float64) {
+	return 0, 1
+}
+
+// Eval2 returns the projection of (x, y) onto the gradient direction, within range [0, 1]
+func (n *NoiseGradient) Eval2(x, y float64) float64 {
+	dx, dy := n.X1-n.X0, n.Y1-n.Y0
+	l2 := dx*dx + dy*dy
+	if l2 == 0 {
+		return 0
+	}
+	return Clamp(((x-n.X0)*dx+(y-n.Y0)*dy)/l2, 0, 1)
+}
+
+// NoiseAdd is a combiner returning the sum of both sources
+type NoiseAdd struct {
+	A, B TextureCachable
+}
+
+// NewNoiseAdd returns a combiner adding a and b
+func NewNoiseAdd(a TextureCachable, b TextureCachable) *NoiseAdd {
+	return &NoiseAdd{a, b}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseAdd) GetParamSignature() []byte {
+	return graphSignature("add", nil, n.A, n.B)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseAdd) GetEvalRange() (outMin float64, outMax float64) {
+	amin, amax := n.A.GetEvalRange()
+	bmin, bmax := n.B.GetEvalRange()
+	return amin + bmin, amax + bmax
+}
+
+// Eval2 returns A + B
+func (n *NoiseAdd) Eval2(x, y float64) float64 {
+	return n.A.Eval2(x, y) + n.B.Eval2(x, y)
+}
+
+// NoiseMultiply is a combiner returning the product of both sources
+type NoiseMultiply struct {
+	A, B TextureCachable
+}
+
+// NewNoiseMultiply returns a combiner multiplying a and b
+func NewNoiseMultiply(a TextureCachable, b TextureCachable) *NoiseMultiply {
+	return &NoiseMultiply{a, b}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseMultiply) GetParamSignature() []byte {
+	return graphSignature("multiply", nil, n.A, n.B)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseMultiply) GetEvalRange() (outMin float64, outMax float64) {
+	amin, amax := n.A.GetEvalRange()
+	bmin, bmax := n.B.GetEvalRange()
+	// the extremes of a product of intervals are always among the products of their bounds
+	p1, p2, p3, p4 := amin*bmin, amin*bmax, amax*bmin, amax*bmax
+	return math.Min(math.Min(p1, p2), math.Min(p3, p4)), math.Max(math.Max(p1, p2), math.Max(p3, p4))
+}
+
+// Eval2 returns A * B
+func (n *NoiseMultiply) Eval2(x, y float64) float64 {
+	return n.A.Eval2(x, y) * n.B.Eval2(x, y)
+}
+
+// NoiseMin is a combiner returning the smaller value of both sources
+type NoiseMin struct {
+	A, B TextureCachable
+}
+
+// NewNoiseMin returns a combiner choosing the minimum of a and b
+func NewNoiseMin(a TextureCachable, b TextureCachable) *NoiseMin {
+	return &NoiseMin{a, b}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseMin) GetParamSignature() []byte {
+	return graphSignature("min", nil, n.A, n.B)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseMin) GetEvalRange() (outMin float64, outMax float64) {
+	amin, amax := n.A.GetEvalRange()
+	bmin, bmax := n.B.GetEvalRange()
+	return math.Min(amin, bmin), math.Min(amax, bmax)
+}
+
+// Eval2 returns min(A, B)
+func (n *NoiseMin) Eval2(x, y float64) float64 {
+	return math.Min(n.A.Eval2(x, y), n.B.Eval2(x, y))
+}
+
+// NoiseMax is a combiner returning the larger value of both sources
+type NoiseMax struct {
+	A, B TextureCachable
+}
+
+// NewNoiseMax returns a combiner choosing the maximum of a and b
+func NewNoiseMax(a TextureCachable, b TextureCachable) *NoiseMax {
+	return &NoiseMax{a, b}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseMax) GetParamSignature() []byte {
+	return graphSignature("max", nil, n.A, n.B)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseMax) GetEvalRange() (outMin float64, outMax float64) {
+	amin, amax := n.A.GetEvalRange()
+	bmin, bmax := n.B.GetEvalRange()
+	return math.Max(amin, bmin), math.Max(amax, bmax)
+}
+
+// Eval2 returns max(A, B)
+func (n *NoiseMax) Eval2(x, y float64) float64 {
+	return math.Max(n.A.Eval2(x, y), n.B.Eval2(x, y))
+}
+
+// NoiseBlend is a combiner that linearly interpolates between both sources, using a third source as ratio
+// the output of Control is scaled from its eval range to [0, 1], 0 gives A and 1 gives B
+// the eval range of Control is looked up once, create a new node after changing the parameters of the control
+type NoiseBlend struct {
+	A, B, Control TextureCachable
+	controlRange  *evalRangeCache
+}
+
+// NewNoiseBlend returns a combiner blending from a to b by control
+func NewNoiseBlend(a TextureCachable, b TextureCachable, control TextureCachable) *NoiseBlend {
+	return &NoiseBlend{a, b, control, &evalRangeCache{}}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseBlend) GetParamSignature() []byte {
+	return graphSignature("blend", nil, n.A, n.B, n.Control)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseBlend) GetEvalRange() (outMin float64, outMax float64) {
+	amin, amax := n.A.GetEvalRange()
+	bmin, bmax := n.B.GetEvalRange()
+	return math.Min(amin, bmin), math.Max(amax, bmax)
+}
+
+// Eval2 returns the mix of A and B by Control
+func (n *NoiseBlend) Eval2(x, y float64) float64 {
+	cmin, cmax := n.controlRange.get(n.Control)
+	ratio := scaleEvalRange(n.Control.Eval2(x, y), cmin, cmax)
+	return MixF(n.A.Eval2(x, y), n.B.Eval2(x, y), ratio)
+}
+
+// NoiseSelect is a combiner that chooses A where Control is below Threshold and B everywhere else
+// within Falloff around the threshold both are smoothly blended
+type NoiseSelect struct {
+	A, B, Control TextureCachable
+	Threshold     float64
+	Falloff       float64
+}
+
+// NewNoiseSelect returns a combiner selecting a or b by comparing control to the threshold
+func NewNoiseSelect(a TextureCachable, b TextureCachable, control TextureCachable, threshold float64, falloff float64) *NoiseSelect {
+	return &NoiseSelect{a, b, control, threshold, falloff}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseSelect) GetParamSignature() []byte {
+	return graphSignature("select", []float64{n.Threshold, n.Falloff}, n.A, n.B, n.Control)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseSelect) GetEvalRange() (outMin float64, outMax float64) {
+	amin, amax := n.A.GetEvalRange()
+	bmin, bmax := n.B.GetEvalRange()
+	return math.Min(amin, bmin), math.Max(amax, bmax)
+}
+
+// Eval2 returns A or B depending on Control
+func (n *NoiseSelect) Eval2(x, y float64) float64 {
+	c := n.Control.Eval2(x, y)
+	if n.Falloff > 0 {
+		if c <= n.Threshold-n.Falloff {
+			return n.A.Eval2(x, y)
+		}
+		if c >= n.Threshold+n.Falloff {
+			return n.B.Eval2(x, y)
+		}
+		t := ScaleF2F(c, n.Threshold-n.Falloff, n.Threshold+n.Falloff, 0, 1)
+		t = t * t * (3 - 2*t) // smoothstep
+		return MixF(n.A.Eval2(x, y), n.B.Eval2(x, y), t)
+	}
+	if c < n.Threshold {
+		return n.A.Eval2(x, y)
+	}
+	return n.B.Eval2(x, y)
+}
+
+// NoiseAbs is a modifier returning the absolute value of its source
+type NoiseAbs struct {
+	Source TextureCachable
+}
+
+// NewNoiseAbs returns a modifier taking the absolute value of source
+func NewNoiseAbs(source TextureCachable) *NoiseAbs {
+	return &NoiseAbs{source}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseAbs) GetParamSignature() []byte {
+	return graphSignature("abs", nil, n.Source)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseAbs) GetEvalRange() (outMin float64, outMax float64) {
+	smin, smax := n.Source.GetEvalRange()
+	if smin >= 0 {
+		return smin, smax
+	}
+	if smax <= 0 {
+		return -smax, -smin
+	}
+	return 0, math.Max(-smin, smax)
+}
+
+// Eval2 returns |Source|
+func (n *NoiseAbs) Eval2(x, y float64) float64 {
+	return math.Abs(n.Source.Eval2(x, y))
+}
+
+// NoiseClamp is a modifier limiting its source to [Min, Max]
+type NoiseClamp struct {
+	Source   TextureCachable
+	Min, Max float64
+}
+
+// NewNoiseClamp returns a modifier clamping source to [min, max]
+func NewNoiseClamp(source TextureCachable, min float64, max float64) *NoiseClamp {
+	return &NoiseClamp{source, min, max}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseClamp) GetParamSignature() []byte {
+	return graphSignature("clamp", []float64{n.Min, n.Max}, n.Source)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseClamp) GetEvalRange() (outMin float64, outMax float64) {
+	smin, smax := n.Source.GetEvalRange()
+	return Clamp(smin, n.Min, n.Max), Clamp(smax, n.Min, n.Max)
+}
+
+// Eval2 returns Source clamped to [Min, Max]
+func (n *NoiseClamp) Eval2(x, y float64) float64 {
+	return Clamp(n.Source.Eval2(x, y), n.Min, n.Max)
+}
+
+// NoiseCurve is a modifier mapping its source through a piecewise linear curve
+// Points map source values (X) to output values (Y), source values outside of the curve are mapped to the nearest end
+type NoiseCurve struct {
+	Source TextureCachable
+	Points []Vec2f // must be sorted by X
+}
+
+// NewNoiseCurve returns a modifier mapping source through the curve given by the control points, which are sorted by X
+func NewNoiseCurve(source TextureCachable, points []Vec2f) *NoiseCurve {
+	sorted := append([]Vec2f{}, points...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].X < sorted[j].X
+	})
+	return &NoiseCurve{source, sorted}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseCurve) GetParamSignature() []byte {
+	params := []float64{}
+	for _, p := range n.Points {
+		params = append(params, p.X, p.Y)
+	}
+	return graphSignature("curve", params, n.Source)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseCurve) GetEvalRange() (outMin float64, outMax float64) {
+	if len(n.Points) == 0 {
+		return n.Source.GetEvalRange()
+	}
+	outMin, outMax = math.Inf(1), math.Inf(-1)
+	for _, p := range n.Points {
+		outMin = math.Min(outMin, p.Y)
+		outMax = math.Max(outMax, p.Y)
+	}
+	return outMin, outMax
+}
+
+// Eval2 returns the curve value at Source, the source is passed through as is if the curve has no points
+func (n *NoiseCurve) Eval2(x, y float64) float64 {
+	v := n.Source.Eval2(x, y)
+	if len(n.Points) == 0 {
+		return v
+	}
+	if v <= n.Points[0].X {
+		return n.Points[0].Y
+	}
+	i := sort.Search(len(n.Points), func(i int) bool {
+		return n.Points[i].X >= v
+	})
+	if i == len(n.Points) {
+		return n.Points[len(n.Points)-1].Y
+	}
+	p0, p1 := n.Points[i-1], n.Points[i]
+	return MixF(p0.Y, p1.Y, ScaleF2F(v, p0.X, p1.X, 0, 1))
+}
+
+// NoiseTerrace is a modifier creating terrace like plateaus at the given control values
+// between two control values the output eases from the lower one to the upper one, Invert flips the easing
+type NoiseTerrace struct {
+	Source TextureCachable
+	Points []float64 // must be sorted
+	Invert bool
+}
+
+// NewNoiseTerrace returns a terrace modifier with plateaus at the given control values, which are sorted
+func NewNoiseTerrace(source TextureCachable, points []float64, invert bool) *NoiseTerrace {
+	sorted := append([]float64{}, points...)
+	sort.Float64s(sorted)
+	return &NoiseTerrace{source, sorted, invert}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseTerrace) GetParamSignature() []byte {
+	params := append([]float64{}, n.Points...)
+	invert := 0.0
+	if n.Invert {
+		invert = 1
+	}
+	params = append(params, invert)
+	return graphSignature("terrace", params, n.Source)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseTerrace) GetEvalRange() (outMin float64, outMax float64) {
+	if len(n.Points) < 2 {
+		return n.Source.GetEvalRange()
+	}
+	return n.Points[0], n.Points[len(n.Points)-1]
+}
+
+// Eval2 returns the terraced Source, the source is passed through as is if there are less than 2 control values
+func (n *NoiseTerrace) Eval2(x, y float64) float64 {
+	v := n.Source.Eval2(x, y)
+	if len(n.Points) < 2 {
+		return v
+	}
+	if v <= n.Points[0] {
+		return n.Points[0]
+	}
+	i := sort.SearchFloat64s(n.Points, v)
+	if i == len(n.Points) {
+		return n.Points[len(n.Points)-1]
+	}
+	p0, p1 := n.Points[i-1], n.Points[i]
+	alpha := ScaleF2F(v, p0, p1, 0, 1)
+	if n.Invert {
+		alpha = 1 - alpha
+		p0, p1 = p1, p0
+	}
+	alpha *= alpha
+	return MixF(p0, p1, alpha)
+}
+
+// NoiseInvert is a modifier that mirrors its source within its eval range, so that the minimum becomes the maximum
+// the eval range of Source is looked up once, create a new node after changing the parameters of the source
+type NoiseInvert struct {
+	Source      TextureCachable
+	sourceRange *evalRangeCache
+}
+
+// NewNoiseInvert returns a modifier inverting source within its eval range
+func NewNoiseInvert(source TextureCachable) *NoiseInvert {
+	return &NoiseInvert{source, &evalRangeCache{}}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseInvert) GetParamSignature() []byte {
+	return graphSignature("invert", nil, n.Source)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseInvert) GetEvalRange() (outMin float64, outMax float64) {
+	return n.Source.GetEvalRange()
+}
+
+// Eval2 returns the inverted Source
+func (n *NoiseInvert) Eval2(x, y float64) float64 {
+	smin, smax := n.sourceRange.get(n.Source)
+	return smin + smax - n.Source.Eval2(x, y)
+}
+
+// NoiseScaleBias is a modifier returning Source * Scale + Bias
+type NoiseScaleBias struct {
+	Source      TextureCachable
+	Scale, Bias float64
+}
+
+// NewNoiseScaleBias returns a modifier scaling source and adding the bias
+func NewNoiseScaleBias(source TextureCachable, scale float64, bias float64) *NoiseScaleBias {
+	return &NoiseScaleBias{source, scale, bias}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (n *NoiseScaleBias) GetParamSignature() []byte {
+	return graphSignature("scalebias", []float64{n.Scale, n.Bias}, n.Source)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2
+func (n *NoiseScaleBias) GetEvalRange() (outMin float64, outMax float64) {
+	smin, smax := n.Source.GetEvalRange()
+	outMin, outMax = smin*n.Scale+n.Bias, smax*n.Scale+n.Bias
+	if outMin > outMax {
+		outMin, outMax = outMax, outMin
+	}
+	return outMin, outMax
+}
+
+// Eval2 returns Source * Scale + Bias
+func (n *NoiseScaleBias) Eval2(x, y float64) float64 {
+	return n.Source.Eval2(x, y)*n.Scale + n.Bias
+}
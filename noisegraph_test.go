@@ -0,0 +1,173 @@
+package gah
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// noiseGraphNode is a named node for the table driven graph tests, with its expected value at (x, y) and its expected eval range
+type noiseGraphNode struct {
+	name    string
+	node    TextureCachable
+	want    float64
+	wantMin float64
+	wantMax float64
+	x, y    float64
+}
+
+// noiseGraphNodes returns one or more instances of every node type, built on gradients in x and y that span [0, 10]
+func noiseGraphNodes() []noiseGraphNode {
+	gx := NewNoiseGradient(0, 0, 10, 0)
+	gy := NewNoiseGradient(0, 0, 0, 10)
+	one, three := NewNoiseConst(1), NewNoiseConst(3)
+	return []noiseGraphNode{
+		{"const", NewNoiseConst(2), 2, 2, 2, 3, 6},
+		{"gradient x", gx, 0.3, 0, 1, 3, 6},
+		{"gradient diagonal", NewNoiseGradient(0, 0, 10, 10), 0.45, 0, 1, 3, 6},
+		{"gradient degenerate", NewNoiseGradient(5, 5, 5, 5), 0, 0, 1, 3, 6},
+		{"add", NewNoiseAdd(gx, gy), 0.9, 0, 2, 3, 6},
+		{"multiply", NewNoiseMultiply(gx, gy), 0.18, 0, 1, 3, 6},
+		{"multiply signed", NewNoiseMultiply(NewNoiseScaleBias(gx, 2, -1), three), -1.2, -3, 3, 3, 6},
+		{"min", NewNoiseMin(gx, gy), 0.3, 0, 1, 3, 6},
+		{"max", NewNoiseMax(gx, gy), 0.6, 0, 1, 3, 6},
+		{"blend", NewNoiseBlend(one, three, gy), 2.2, 1, 3, 3, 6},
+		{"select a", NewNoiseSelect(one, three, gx, 0.5, 0), 1, 1, 3, 3, 6},
+		{"select b", NewNoiseSelect(one, three, gy, 0.5, 0), 3, 1, 3, 3, 6},
+		{"select falloff", NewNoiseSelect(one, three, gx, 0.5, 0.2), 2, 1, 3, 5, 6},
+		{"abs", NewNoiseAbs(NewNoiseScaleBias(gx, 2, -1)), 0.4, 0, 1, 3, 6},
+		{"clamp", NewNoiseClamp(gx, 0.4, 1), 0.4, 0.4, 1, 3, 6},
+		{"curve", NewNoiseCurve(gx, []Vec2f{{1, 0}, {0, 2}}), 1.4, 0, 2, 3, 6},
+		{"curve empty", NewNoiseCurve(gx, nil), 0.3, 0, 1, 3, 6},
+		{"terrace", NewNoiseTerrace(gx, []float64{0, 1, 0.5}, false), 0.18, 0, 1, 3, 6},
+		{"terrace inverted", NewNoiseTerrace(gx, []float64{0, 1, 0.5}, true), 0.42, 0, 1, 3, 6},
+		{"invert", NewNoiseInvert(gx), 0.7, 0, 1, 3, 6},
+		{"scalebias", NewNoiseScaleBias(gx, -2, 1), 0.4, -1, 1, 3, 6},
+	}
+}
+
+func TestNoiseGraphNodes(t *testing.T) {
+	for _, tt := range noiseGraphNodes() {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.node.Eval2(tt.x, tt.y); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Eval2(%v, %v) = %v, want %v", tt.x, tt.y, got, tt.want)
+			}
+			if gotMin, gotMax := tt.node.GetEvalRange(); math.Abs(gotMin-tt.wantMin) > 1e-12 || math.Abs(gotMax-tt.wantMax) > 1e-12 {
+				t.Errorf("GetEvalRange() = [%v, %v], want [%v, %v]", gotMin, gotMax, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestNoiseGraphWithinEvalRange(t *testing.T) {
+	for _, tt := range noiseGraphNodes() {
+		emin, emax := tt.node.GetEvalRange()
+		for x := -2.0; x <= 12; x += 0.25 {
+			for y := -2.0; y <= 12; y += 0.25 {
+				if v := tt.node.Eval2(x, y); v < emin-1e-12 || v > emax+1e-12 {
+					t.Fatalf("%s: Eval2(%v, %v) = %v, outside of its eval range [%v, %v]", tt.name, x, y, v, emin, emax)
+				}
+			}
+		}
+	}
+}
+
+func TestNoiseGraphSignatures(t *testing.T) {
+	nodes := noiseGraphNodes()
+	for i := range nodes {
+		if !bytes.Equal(nodes[i].node.GetParamSignature(), noiseGraphNodes()[i].node.GetParamSignature()) {
+			t.Errorf("%s: signature differs between two equal graphs", nodes[i].name)
+		}
+		for j := i + 1; j < len(nodes); j++ {
+			if bytes.Equal(nodes[i].node.GetParamSignature(), nodes[j].node.GetParamSignature()) {
+				t.Errorf("%s and %s have the same signature", nodes[i].name, nodes[j].name)
+			}
+		}
+	}
+	gx, gy := NewNoiseGradient(0, 0, 10, 0), NewNoiseGradient(0, 0, 0, 10)
+	if bytes.Equal(NewNoiseAdd(gx, gy).GetParamSignature(), NewNoiseAdd(gy, gx).GetParamSignature()) {
+		t.Errorf("swapped sources have the same signature")
+	}
+}
+
+// rangeCounter wraps a source and counts how often its eval range is looked up
+type rangeCounter struct {
+	TextureCachable
+	lookups *int
+}
+
+func (rc rangeCounter) GetEvalRange() (outMin float64, outMax float64) {
+	*rc.lookups++
+	return rc.TextureCachable.GetEvalRange()
+}
+
+func TestNoiseGraphEvalRangeLookups(t *testing.T) {
+	gx, gy := NewNoiseGradient(0, 0, 10, 0), NewNoiseGradient(0, 0, 0, 10)
+	one, three := NewNoiseConst(1), NewNoiseConst(3)
+	lookups := 0
+	blend := NewNoiseBlend(one, three, rangeCounter{gy, &lookups})
+	invert := NewNoiseInvert(rangeCounter{gx, &lookups})
+	literal := &NoiseBlend{A: one, B: three, Control: gy}
+	for x := 0.0; x < 10; x++ {
+		for y := 0.0; y < 10; y++ {
+			if got, want := blend.Eval2(x, y), 1+2*y/10; math.Abs(got-want) > 1e-12 {
+				t.Fatalf("blend Eval2(%v, %v) = %v, want %v", x, y, got, want)
+			}
+			if got, want := invert.Eval2(x, y), 1-x/10; math.Abs(got-want) > 1e-12 {
+				t.Fatalf("invert Eval2(%v, %v) = %v, want %v", x, y, got, want)
+			}
+			if got, want := literal.Eval2(x, y), blend.Eval2(x, y); got != want {
+				t.Fatalf("literal blend Eval2(%v, %v) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+	if lookups != 2 {
+		t.Errorf("the eval ranges were looked up %d times for 100 pixels, want once per node", lookups)
+	}
+	// pointing the node at another source looks up the range of the new one
+	blend.Control = NewNoiseScaleBias(gy, 2, 0)
+	if got, want := blend.Eval2(0, 5), 2.0; math.Abs(got-want) > 1e-12 {
+		t.Errorf("blend Eval2(0, 5) with a new control = %v, want %v", got, want)
+	}
+}
+
+func TestScaleEvalRange(t *testing.T) {
+	tests := []struct {
+		v, emin, emax float64
+		want          float64
+		wantGray      uint8
+	}{
+		{0, -1, 1, 0.5, 127},
+		{-1, -1, 1, 0, 0},
+		{1, -1, 1, 1, 255},
+		{2, -1, 1, 1, 255},
+		{-3, -1, 1, 0, 0},
+		{5, 5, 5, 0.5, 128},
+		{5, 6, 4, 0.5, 128},
+	}
+	for _, tt := range tests {
+		if got := scaleEvalRange(tt.v, tt.emin, tt.emax); got != tt.want {
+			t.Errorf("scaleEvalRange(%v, %v, %v) = %v, want %v", tt.v, tt.emin, tt.emax, got, tt.want)
+		}
+		if got := evalRangeToGray(tt.v, tt.emin, tt.emax); got != tt.wantGray {
+			t.Errorf("evalRangeToGray(%v, %v, %v) = %v, want %v", tt.v, tt.emin, tt.emax, got, tt.wantGray)
+		}
+	}
+}
+
+func TestNoiseGraphTextureCache(t *testing.T) {
+	graph := NewNoiseBlend(NewNoiseGradient(0, 0, 16, 0), NewNoiseConst(0), NewNoiseGradient(0, 0, 0, 16))
+	dir := t.TempDir() + "/"
+	emin, emax := graph.GetEvalRange()
+	// the second cache is loaded from the file written by the first one
+	for _, tc := range []*TextureCache{NewTextureCache(graph, 0, 0, 16, 16, dir), NewTextureCache(graph, 0, 0, 16, 16, dir)} {
+		for x := 0; x < 16; x++ {
+			for y := 0; y < 16; y++ {
+				want := float64(evalRangeToGray(graph.Eval2(float64(x), float64(y)), emin, emax)) / 255
+				if got := tc.Sample(x, y); got != want {
+					t.Fatalf("Sample(%d, %d) = %v, want %v", x, y, got, want)
+				}
+			}
+		}
+	}
+}
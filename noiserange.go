@@ -0,0 +1,212 @@
+package gah
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// NoiseOutput selects how CoherentNoise maps the layered noise to its output
+type NoiseOutput int
+
+const (
+	NoiseOutputRaw        NoiseOutput = iota // the layered noise as is, clamped to the calibrated range that GetEvalRange reports
+	NoiseOutputNormalized                    // linearly stretched from the calibrated range to the full nominal range of the fractal mode
+	NoiseOutputEqualized                     // histogram equalized, uniformly distributed over the full nominal range of the fractal mode
+)
+
+const (
+	NoiseCalibrationSamples   = 16384 // number of positions sampled to calibrate the output distribution of a CoherentNoise
+	NoiseCalibrationQuantiles = 256   // resolution of the histogram equalization
+)
+
+// noiseCalibrationSeed decorrelates the calibration sample positions from the noise itself
+const noiseCalibrationSeed = 0x67616863616c6962
+
+// noiseCalibrationExtent is the edge length of the area in base noise units over which the calibration samples are spread
+const noiseCalibrationExtent = 1024
+
+// noiseCalibration lazily measures the output distribution of a CoherentNoise, separately for every number of dimensions
+type noiseCalibration struct {
+	once      [4]sync.Once
+	quantiles [4][]float64 // NoiseCalibrationQuantiles+1 sorted values per dimension count, the first and last are the padded min and max
+}
+
+// noiseCalibrationLimit is the number of signatures kept in noiseCalibrations, the oldest ones are dropped beyond it
+// noises that already use a dropped calibration keep it, others with the same signature measure it again
+const noiseCalibrationLimit = 64
+
+// noiseCalibrations holds the calibrations of the most recently measured CoherentNoise signatures, keyed by the string of the signature
+// noises with equal parameters share a calibration, and changing any parameter of a noise switches it to the matching one
+var noiseCalibrations = struct {
+	sync.Mutex
+	m     map[string]*noiseCalibration
+	order []string // the signatures in m from oldest to newest
+}{m: map[string]*noiseCalibration{}}
+
+// noiseCalibrationParams are the parameters of a CoherentNoise that its calibration depends on, the base noise is compared by identity
+type noiseCalibrationParams struct {
+	noise       Noise
+	scale       float64
+	octaves     int
+	lacunarity  float64
+	persistence float64
+	mode        FractalMode
+}
+
+// noiseCalibrationCache remembers the calibration a CoherentNoise used last together with its parameters,
+// so that evaluations neither build the signature nor lock noiseCalibrations while the parameters stay the same
+type noiseCalibrationCache struct {
+	last atomic.Value // *noiseCalibrationEntry
+}
+
+// noiseCalibrationEntry is the calibration for the given parameters, nil if the base noise has no signature
+type noiseCalibrationEntry struct {
+	params noiseCalibrationParams
+	c      *noiseCalibration
+}
+
+// evalRangeN returns the range of the source for the given number of dimensions, if it reports them separately
+func evalRangeN(src TextureCachable, dims int) (outMin float64, outMax float64) {
+	if srcN, ok := src.(interface {
+		GetEvalRangeN(dims int) (float64, float64)
+	}); ok {
+		return srcN.GetEvalRangeN(dims)
+	}
+	return src.GetEvalRange()
+}
+
+// GetEvalRangeN returns the min and max values that can be expected from the EvalN with the given number of dimensions in [1, 4]
+// the raw output reports the calibrated range, which is measured once on first use,
+// it is estimated from samples and the rare raw values beyond it are clamped, so the range always holds but is only approximately tight
+// the normalized and equalized output always cover the full nominal range of the fractal mode
+// the calibration is identified by the param signature, so base noises that do not provide one are not calibrated and report the nominal range
+func (cnoise *CoherentNoise) GetEvalRangeN(dims int) (outMin float64, outMax float64) {
+	if cnoise.Output == NoiseOutputRaw {
+		if q := cnoise.quantiles(dims); q != nil {
+			return q[0], q[len(q)-1]
+		}
+	}
+	return cnoise.nominalRange()
+}
+
+// quantiles returns the calibrated quantiles for the given number of dimensions, or nil if the noise has no calibration
+func (cnoise *CoherentNoise) quantiles(dims int) []float64 {
+	c := cnoise.lookupCalibration()
+	if c == nil {
+		return nil
+	}
+	c.once[dims-1].Do(func() {
+		c.quantiles[dims-1] = cnoise.measure(dims)
+	})
+	return c.quantiles[dims-1]
+}
+
+// lookupCalibration returns the calibration matching the current parameters, or nil if the base noise has no signature
+// the calibration is taken from the cache of the noise if its parameters did not change, otherwise from noiseCalibrations
+func (cnoise *CoherentNoise) lookupCalibration() *noiseCalibration {
+	params := noiseCalibrationParams{cnoise.Noise, cnoise.Scale, cnoise.Octaves, cnoise.Lacunarity, cnoise.Persistence, cnoise.Mode}
+	if cnoise.calibration != nil {
+		// only comparable base noises are cached, and those compare without panicking against any other noise
+		if last, ok := cnoise.calibration.last.Load().(*noiseCalibrationEntry); ok && last.params == params {
+			return last.c
+		}
+	}
+	var c *noiseCalibration
+	if _, ok := cnoise.Noise.(paramSigner); ok {
+		// the output mode does not change the raw values that are measured, so it is left out of the signature
+		raw := *cnoise
+		raw.Output = NoiseOutputRaw
+		c = noiseCalibrationFor(string(raw.GetParamSignature()))
+	}
+	if cnoise.calibration != nil && reflect.TypeOf(cnoise.Noise).Comparable() {
+		cnoise.calibration.last.Store(&noiseCalibrationEntry{params, c})
+	}
+	return c
+}
+
+// noiseCalibrationFor returns the calibration for the signature from noiseCalibrations, adding it and dropping the oldest one if needed
+func noiseCalibrationFor(signature string) *noiseCalibration {
+	noiseCalibrations.Lock()
+	defer noiseCalibrations.Unlock()
+	if c := noiseCalibrations.m[signature]; c != nil {
+		return c
+	}
+	if len(noiseCalibrations.order) >= noiseCalibrationLimit {
+		delete(noiseCalibrations.m, noiseCalibrations.order[0])
+		noiseCalibrations.order = noiseCalibrations.order[1:]
+	}
+	c := &noiseCalibration{}
+	noiseCalibrations.m[signature] = c
+	noiseCalibrations.order = append(noiseCalibrations.order, signature)
+	return c
+}
+
+// measure samples the raw layered noise at deterministic pseudo random positions and returns the quantiles of the results
+func (cnoise *CoherentNoise) measure(dims int) []float64 {
+	samples := make([]float64, NoiseCalibrationSamples)
+	for i := range samples {
+		var p [4]float64
+		for a := 0; a < dims; a++ {
+			p[a] = HashToUnit(HashInts(noiseCalibrationSeed, i, a)) * noiseCalibrationExtent / cnoise.Scale
+		}
+		samples[i] = cnoise.evalRaw(p, dims)
+	}
+	sort.Float64s(samples)
+	q := make([]float64, NoiseCalibrationQuantiles+1)
+	for i := range q {
+		q[i] = samples[i*(len(samples)-1)/NoiseCalibrationQuantiles]
+	}
+	// the samples miss the most extreme values, so pad the range a little, but never beyond what the mode can reach
+	nomMin, nomMax := cnoise.nominalRange()
+	pad := (q[len(q)-1] - q[0]) * 0.02
+	q[0] = math.Max(q[0]-pad, nomMin)
+	q[len(q)-1] = math.Min(q[len(q)-1]+pad, nomMax)
+	return q
+}
+
+// output maps the raw layered noise v of the given number of dimensions according to the output mode
+func (cnoise *CoherentNoise) output(v float64, dims int) float64 {
+	v, _ = cnoise.mapOutput(v, cnoise.quantiles(dims))
+	return v
+}
+
+// outputDeriv works as output does, but also returns the slope of the mapping at v for the chain rule
+func (cnoise *CoherentNoise) outputDeriv(v float64, dims int) (float64, float64) {
+	return cnoise.mapOutput(v, cnoise.quantiles(dims))
+}
+
+// mapOutput maps v according to the output mode using the given calibrated quantiles, and returns the slope of the mapping at v
+// the raw output is only clamped to the calibrated range, and nothing is mapped without quantiles
+func (cnoise *CoherentNoise) mapOutput(v float64, q []float64) (float64, float64) {
+	if q == nil {
+		return v, 1
+	}
+	lo, hi := q[0], q[len(q)-1]
+	if cnoise.Output == NoiseOutputRaw {
+		if v < lo || v > hi {
+			return Clamp(v, lo, hi), 0
+		}
+		return v, 1
+	}
+	nomMin, nomMax := cnoise.nominalRange()
+	span := nomMax - nomMin
+	switch {
+	case hi <= lo:
+		return nomMin + span/2, 0
+	case v <= lo:
+		return nomMin, 0
+	case v >= hi:
+		return nomMax, 0
+	}
+	if cnoise.Output == NoiseOutputNormalized {
+		return nomMin + (v-lo)/(hi-lo)*span, span / (hi - lo)
+	}
+	// the equalized output is the cumulative distribution of the samples, interpolated linearly between the quantiles
+	i := sort.SearchFloat64s(q, v) // q[i-1] < v <= q[i]
+	a, b := q[i-1], q[i]
+	t := (float64(i-1) + (v-a)/(b-a)) / NoiseCalibrationQuantiles
+	return nomMin + t*span, span / (b - a) / NoiseCalibrationQuantiles
+}
@@ -0,0 +1,257 @@
+package gah
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// noiseRangeSamples evaluates the noise with the given number of dimensions at n random positions
+func noiseRangeSamples(cnoise *CoherentNoise, dims int, n int) []float64 {
+	rng := rand.New(rand.NewSource(int64(dims)))
+	samples := make([]float64, n)
+	for i := range samples {
+		x, y, z, w := rng.Float64()*5000, rng.Float64()*5000, rng.Float64()*5000, rng.Float64()*5000
+		switch dims {
+		case 1:
+			samples[i] = cnoise.Eval1(x)
+		case 2:
+			samples[i] = cnoise.Eval2(x, y)
+		case 3:
+			samples[i] = cnoise.Eval3(x, y, z)
+		default:
+			samples[i] = cnoise.Eval4(x, y, z, w)
+		}
+	}
+	return samples
+}
+
+func TestCoherentNoiseCalibratedRange(t *testing.T) {
+	for _, m := range fractalModes {
+		cnoise := NewCoherentNoise(21, 0.01, 5, 2, 0.5)
+		cnoise.Mode = m.mode
+		nomMin, nomMax := cnoise.nominalRange()
+		for dims := 1; dims <= 4; dims++ {
+			emin, emax := cnoise.GetEvalRangeN(dims)
+			if emin < nomMin || emax > nomMax || emin >= emax {
+				t.Fatalf("%s %dD: calibrated range [%v, %v] is not within the nominal range [%v, %v]", m.name, dims, emin, emax, nomMin, nomMax)
+			}
+			// raw values beyond the estimated range are clamped, so the range holds for every sample
+			for _, v := range noiseRangeSamples(cnoise, dims, 2000) {
+				if v < emin || v > emax {
+					t.Fatalf("%s %dD: sample %v lies outside of the calibrated range [%v, %v]", m.name, dims, v, emin, emax)
+				}
+			}
+		}
+		if emin, emax := cnoise.GetEvalRange(); m.mode == FractalFBM && emax-emin > 0.8*(nomMax-nomMin) {
+			t.Errorf("%s: calibrated range [%v, %v] is hardly narrower than the nominal range", m.name, emin, emax)
+		}
+	}
+}
+
+func TestCoherentNoiseNormalizedOutput(t *testing.T) {
+	for _, m := range fractalModes {
+		for _, output := range []NoiseOutput{NoiseOutputNormalized, NoiseOutputEqualized} {
+			cnoise := NewCoherentNoise(22, 0.01, 5, 2, 0.5)
+			cnoise.Mode, cnoise.Output = m.mode, output
+			nomMin, nomMax := cnoise.nominalRange()
+			if emin, emax := cnoise.GetEvalRange(); emin != nomMin || emax != nomMax {
+				t.Errorf("%s output %d: GetEvalRange() = [%v, %v], want the nominal range [%v, %v]", m.name, output, emin, emax, nomMin, nomMax)
+			}
+			samples := noiseRangeSamples(cnoise, 2, 4000)
+			var bins [10]int
+			minV, maxV := math.Inf(1), math.Inf(-1)
+			for _, v := range samples {
+				if v < nomMin || v > nomMax {
+					t.Fatalf("%s output %d: sample %v is outside of [%v, %v]", m.name, output, v, nomMin, nomMax)
+				}
+				minV, maxV = math.Min(minV, v), math.Max(maxV, v)
+				bins[int(Clamp((v-nomMin)/(nomMax-nomMin)*10, 0, 9))]++
+			}
+			// normalizing only stretches the calibrated range, so the rare extremes are needed to get close to the ends
+			if minSpan := map[NoiseOutput]float64{NoiseOutputNormalized: 0.75, NoiseOutputEqualized: 0.95}[output]; maxV-minV < minSpan*(nomMax-nomMin) {
+				t.Errorf("%s output %d: samples only span [%v, %v] of [%v, %v]", m.name, output, minV, maxV, nomMin, nomMax)
+			}
+			if output != NoiseOutputEqualized {
+				continue
+			}
+			for i, n := range bins {
+				if n < 4000/10*7/10 || n > 4000/10*13/10 {
+					t.Errorf("%s: equalized histogram bin %d holds %d of 4000 samples, want about 400", m.name, i, n)
+				}
+			}
+		}
+	}
+}
+
+func TestCoherentNoiseOutputConsistency(t *testing.T) {
+	for _, output := range []NoiseOutput{NoiseOutputRaw, NoiseOutputNormalized, NoiseOutputEqualized} {
+		cnoise := NewCoherentNoiseWithBase(NewPerlinNoise(3), 0.05, 3, 2, 0.5)
+		cnoise.Output = output
+		const w, h = 13, 7
+		dst := make([]float64, w*h)
+		cnoise.EvalGrid2(dst, 1, 2, 1.5, 0.5, w, h)
+		for iy := 0; iy < h; iy++ {
+			for ix := 0; ix < w; ix++ {
+				x, y := 1+float64(ix)*1.5, 2+float64(iy)*0.5
+				if dst[iy*w+ix] != cnoise.Eval2(x, y) {
+					t.Fatalf("output %d: grid pixel (%d, %d) differs from Eval2", output, ix, iy)
+				}
+				// the derivatives follow the output mapping through the chain rule
+				v, dx, dy := cnoise.Eval2Deriv(x, y)
+				rv, rderiv := cnoise.layerDeriv(func(freq float64) (float64, [3]float64) {
+					return cnoise.baseEval2Deriv(x*freq, y*freq)
+				})
+				rdx, rdy := rderiv[0], rderiv[1]
+				mv, slope := cnoise.mapOutput(rv, cnoise.quantiles(2))
+				if v != cnoise.Eval2(x, y) || v != mv || dx != rdx*slope || dy != rdy*slope {
+					t.Fatalf("output %d: Eval2Deriv(%v, %v) = (%v, %v, %v), want (%v, %v, %v)", output, x, y, v, dx, dy, mv, rdx*slope, rdy*slope)
+				}
+			}
+		}
+	}
+}
+
+func TestCoherentNoiseCalibrationSignature(t *testing.T) {
+	a, b := NewCoherentNoise(23, 0.02, 4, 2, 0.5), NewCoherentNoise(23, 0.02, 4, 2, 0.5)
+	qa, qb := a.quantiles(3), b.quantiles(3)
+	if &qa[0] != &qb[0] {
+		t.Errorf("noises with equal parameters do not share their calibration")
+	}
+	b.Persistence = 0.6
+	if qc := b.quantiles(3); &qa[0] == &qc[0] {
+		t.Errorf("changing a parameter keeps the old calibration")
+	}
+	b.Persistence = 0.5
+	b.Output = NoiseOutputEqualized
+	if qc := b.quantiles(3); &qa[0] != &qc[0] {
+		t.Errorf("changing the output mode measures the raw values again")
+	}
+	signatures := [][]byte{}
+	for _, output := range []NoiseOutput{NoiseOutputRaw, NoiseOutputNormalized, NoiseOutputEqualized} {
+		a.Output = output
+		for _, other := range signatures {
+			if bytes.Equal(a.GetParamSignature(), other) {
+				t.Errorf("output %d has the same signature as another output", output)
+			}
+		}
+		signatures = append(signatures, a.GetParamSignature())
+	}
+	// base noises without a signature are not calibrated, and pass through their raw value
+	unsigned := NewCoherentNoiseWithBase(noDerivNoise{NewPerlinNoise(1)}, 0.05, 3, 2, 0.5)
+	unsigned.Output = NoiseOutputEqualized
+	if unsigned.quantiles(2) != nil {
+		t.Errorf("base noise without a signature was calibrated")
+	}
+	if emin, emax := unsigned.GetEvalRange(); emin != -1 || emax != 1 {
+		t.Errorf("uncalibrated GetEvalRange() = [%v, %v], want the nominal range", emin, emax)
+	}
+	if got, want := unsigned.Eval2(3, 4), unsigned.evalRaw([4]float64{3, 4}, 2); got != want {
+		t.Errorf("uncalibrated Eval2(3, 4) = %v, want the raw %v", got, want)
+	}
+}
+
+func TestCoherentNoiseCalibrationCache(t *testing.T) {
+	cnoise := NewCoherentNoise(24, 0.02, 3, 2, 0.5)
+	cnoise.Output = NoiseOutputEqualized
+	want := cnoise.Eval2(3, 4)
+	// once calibrated, evaluations with unchanged parameters neither build the signature nor wait for the global calibrations
+	done := make(chan float64)
+	noiseCalibrations.Lock()
+	go func() {
+		done <- cnoise.Eval2(3, 4)
+	}()
+	select {
+	case got := <-done:
+		if got != want {
+			t.Errorf("cached Eval2(3, 4) = %v, want %v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Eval2 waited for the global calibrations")
+	}
+	noiseCalibrations.Unlock()
+	// noises created without a constructor have no cache, and look up the global calibrations instead
+	literal := &CoherentNoise{Noise: cnoise.Noise, Scale: 0.02, Octaves: 3, Lacunarity: 2, Persistence: 0.5, Output: NoiseOutputEqualized}
+	if got := literal.Eval2(3, 4); got != want {
+		t.Errorf("literal Eval2(3, 4) = %v, want %v", got, want)
+	}
+	// base noises that can not be compared are not cached, but still calibrated
+	uncomparable := NewCoherentNoiseWithBase(sliceNoise{[]Noise{NewPerlinNoise(1)}}, 0.05, 3, 2, 0.5)
+	if uncomparable.quantiles(2) == nil || uncomparable.calibration.last.Load() != nil {
+		t.Errorf("uncomparable base noise was not calibrated, or cached")
+	}
+}
+
+// sliceNoise is a base noise that can not be compared, because it holds a slice
+type sliceNoise struct {
+	noises []Noise
+}
+
+func (n sliceNoise) Eval2(x, y float64) float64       { return n.noises[0].Eval2(x, y) }
+func (n sliceNoise) Eval3(x, y, z float64) float64    { return n.noises[0].Eval3(x, y, z) }
+func (n sliceNoise) Eval4(x, y, z, w float64) float64 { return n.noises[0].Eval4(x, y, z, w) }
+func (n sliceNoise) GetParamSignature() []byte        { return []byte("slice") }
+
+func TestCoherentNoiseCalibrationLimit(t *testing.T) {
+	first := NewCoherentNoise(25, 0.02, 2, 2, 0.5)
+	q := first.quantiles(2)
+	for i := 0; i < 2*noiseCalibrationLimit; i++ {
+		NewCoherentNoise(26, 0.02+float64(i)*1e-3, 2, 2, 0.5).quantiles(2)
+	}
+	noiseCalibrations.Lock()
+	n, order := len(noiseCalibrations.m), len(noiseCalibrations.order)
+	noiseCalibrations.Unlock()
+	if n > noiseCalibrationLimit || order != n {
+		t.Errorf("%d calibrations kept in order of %d, want at most %d", n, order, noiseCalibrationLimit)
+	}
+	// the noise keeps its dropped calibration, and another noise with the same parameters measures the same one again
+	if qa := first.quantiles(2); &qa[0] != &q[0] {
+		t.Errorf("noise lost its calibration when it was dropped")
+	}
+	again := NewCoherentNoise(25, 0.02, 2, 2, 0.5).quantiles(2)
+	if &again[0] == &q[0] {
+		t.Errorf("dropped calibration is still shared")
+	}
+	for i := range q {
+		if again[i] != q[i] {
+			t.Fatalf("measuring the calibration again gives quantile %d = %v, want %v", i, again[i], q[i])
+		}
+	}
+}
+
+func TestMapOutput(t *testing.T) {
+	cnoise := &CoherentNoise{Mode: FractalFBM, Output: NoiseOutputNormalized}
+	q := make([]float64, NoiseCalibrationQuantiles+1)
+	for i := range q {
+		q[i] = -0.5 + float64(i)/NoiseCalibrationQuantiles
+	}
+	tests := []struct {
+		output    NoiseOutput
+		q         []float64
+		v         float64
+		want      float64
+		wantSlope float64
+	}{
+		{NoiseOutputNormalized, q, 0, 0, 2},
+		{NoiseOutputNormalized, q, 0.25, 0.5, 2},
+		{NoiseOutputNormalized, q, -0.7, -1, 0},
+		{NoiseOutputNormalized, q, 0.9, 1, 0},
+		{NoiseOutputEqualized, q, 0.25, 0.5, 2},
+		{NoiseOutputEqualized, q, -0.5, -1, 0},
+		{NoiseOutputNormalized, []float64{0.3, 0.3}, 0.3, 0, 0},
+		{NoiseOutputNormalized, nil, 0.3, 0.3, 1},
+		{NoiseOutputRaw, q, 0.3, 0.3, 1},
+		{NoiseOutputRaw, q, 0.9, 0.5, 0},
+		{NoiseOutputRaw, q, -0.7, -0.5, 0},
+		{NoiseOutputRaw, nil, 0.9, 0.9, 1},
+	}
+	for _, tt := range tests {
+		cnoise.Output = tt.output
+		got, slope := cnoise.mapOutput(tt.v, tt.q)
+		if math.Abs(got-tt.want) > 1e-12 || math.Abs(slope-tt.wantSlope) > 1e-9 {
+			t.Errorf("output %d: mapOutput(%v) = (%v, %v), want (%v, %v)", tt.output, tt.v, got, slope, tt.want, tt.wantSlope)
+		}
+	}
+}
@@ -1,10 +1,8 @@
 package gah
 
 import (
-	"fmt"
+	"container/heap"
 	"math"
-
-	"github.com/fogleman/gg"
 )
 
 type quadTreeQuadrant int
@@ -16,20 +14,81 @@ const (
 	bottomRight quadTreeQuadrant = 3
 )
 
-// QuadTreeLeafThreshold defines the maximum amount of points allowed in a QuadTree leafnode before it is split
+// QuadTreeLeafThreshold defines the default maximum amount of points allowed in a QuadTree leafnode before it is split
 const QuadTreeLeafThreshold = 10
 
-// QuadTree is a typical 2D QuadTree containing points
+// QuadTreeDefaultMaxDepth defines the default depth beyond which a QuadTree is not split any further
+const QuadTreeDefaultMaxDepth = 24
+
+// quadTreeConfig holds the settings shared by all nodes of a QuadTree
+type quadTreeConfig struct {
+	leafCapacity int // maximum amount of points in a leaf before it is split
+	maxDepth     int // leafs at this depth are never split, they grow beyond their capacity instead
+}
+
+// QuadTreeOption configures a QuadTree on creation
+type QuadTreeOption func(cfg *quadTreeConfig)
+
+// QuadTreeLeafCapacity sets the maximum amount of points allowed in a leafnode before it is split, defaults to QuadTreeLeafThreshold
+func QuadTreeLeafCapacity(capacity int) QuadTreeOption {
+	return func(cfg *quadTreeConfig) {
+		if capacity < 1 {
+			capacity = 1
+		}
+		cfg.leafCapacity = capacity
+	}
+}
+
+// QuadTreeMaxDepth sets the depth beyond which the tree is not split any further, defaults to QuadTreeDefaultMaxDepth
+// leafs at the maximum depth grow beyond the leaf capacity instead, so many coincident points can not subdivide forever
+func QuadTreeMaxDepth(depth int) QuadTreeOption {
+	return func(cfg *quadTreeConfig) {
+		if depth < 0 {
+			depth = 0
+		}
+		cfg.maxDepth = depth
+	}
+}
+
+// QuadTreeItem is a point stored in a QuadTree together with arbitrary user data
+// the plain point methods of QuadTree store and return items with a zero ID and nil Data
+type QuadTreeItem struct {
+	Pos  Vec2f
+	ID   int         // user defined identifier, tells apart items at the same position on removal
+	Data interface{} // user payload, returned as is by all item queries
+}
+
+// quadBounds is the axis aligned bounding box of a quad, shared by all quadtree variants
+type quadBounds struct {
+	x, y, w, h float64 // position and extent of the quad
+}
+
+// QuadTree is a typical 2D QuadTree containing points, optionally carrying payloads as QuadTreeItem
 type QuadTree struct {
-	x, y, w, h     float64 // position and extent of the quad
+	quadBounds
+	cfg            *quadTreeConfig
+	depth          int // depth of this node, the root is at depth 0
 	subTrees       [4]*QuadTree
-	leafPoints     [QuadTreeLeafThreshold]Vec2f
+	leafItems      []QuadTreeItem
 	leafPointCount int // counts the number of leafs points, internal nodes accumulate their childrens counts
 }
 
-// NewQuadTree creates a new QuadTree with the given dimensions
-func NewQuadTree(x float64, y float64, w float64, h float64) (qt *QuadTree) {
-	return &QuadTree{x, y, w, h, [4]*QuadTree{}, [QuadTreeLeafThreshold]Vec2f{}, 0}
+// NewQuadTree creates a new QuadTree with the given dimensions, configured by the given options
+func NewQuadTree(x float64, y float64, w float64, h float64, opts ...QuadTreeOption) (qt *QuadTree) {
+	cfg := &quadTreeConfig{QuadTreeLeafThreshold, QuadTreeDefaultMaxDepth}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &QuadTree{quadBounds{x, y, w, h}, cfg, 0, [4]*QuadTree{}, nil, 0}
+}
+
+// itemPositions returns the positions of the given items
+func itemPositions(items []QuadTreeItem) []Vec2f {
+	points := make([]Vec2f, len(items))
+	for i, item := range items {
+		points[i] = item.Pos
+	}
+	return points
 }
 
 func (qt *QuadTree) isLeaf() bool {
@@ -42,43 +101,68 @@ func (qt *QuadTree) isLeaf() bool {
 }
 
 // InsertPoint inserts the given point into the QuadTree, branching the tree where necessary
-//TODO make this iterative
 func (qt *QuadTree) InsertPoint(p Vec2f) {
+	qt.InsertItem(QuadTreeItem{Pos: p})
+}
+
+// InsertItem inserts the given item at its position into the QuadTree, branching the tree where necessary
+func (qt *QuadTree) InsertItem(item QuadTreeItem) {
+	p := item.Pos
 	if ok, _ := qt.Contains(p); !ok {
 		return // do nothing if point if outside of tree
 	}
-	// point is inside of tree
-	if qt.isLeaf() {
-		// is leaf
-		if qt.leafPointCount < QuadTreeLeafThreshold {
-			// insert into leaf with space
-			qt.leafPoints[qt.leafPointCount] = p
-			qt.leafPointCount++
-			return
-		}
-		// set node to internal, and insert points into generated subtrees
-		//TODO sparsely generate subtrees
-		hw := qt.w / 2
-		hh := qt.h / 2
-		qt.subTrees[topRight] = NewQuadTree(qt.x+hw, qt.y, hw, hh)
-		qt.subTrees[topLeft] = NewQuadTree(qt.x, qt.y, hw, hh)
-		qt.subTrees[bottomLeft] = NewQuadTree(qt.x, qt.y+hh, hw, hh)
-		qt.subTrees[bottomRight] = NewQuadTree(qt.x+hw, qt.y+hh, hw, hh)
-		for _, lp := range qt.leafPoints {
-			_, quad := qt.Contains(lp)
-			qt.subTrees[quad].InsertPoint(lp)
-		}
-		_, quad := qt.Contains(p)
-		qt.subTrees[quad].InsertPoint(p)
-		return
-	}
-	// is internal, iterate tree until bounding leaf is found, insert there
+	// point is inside of tree, iterate tree until bounding leaf is found, insert there
 	cqt := qt
 	for !cqt.isLeaf() {
-		_, quad := qt.Contains(p)
+		cqt.leafPointCount++ // internal nodes accumulate their childrens counts
+		_, quad := cqt.Contains(p)
 		cqt = cqt.subTrees[quad]
 	}
-	cqt.InsertPoint(p)
+	if cqt.leafPointCount < cqt.cfg.leafCapacity || cqt.depth >= cqt.cfg.maxDepth {
+		// insert into leaf with space, or grow the leaf if it may not be split any further
+		cqt.leafItems = append(cqt.leafItems, item)
+		cqt.leafPointCount++
+		return
+	}
+	// set node to internal, and insert points into generated subtrees
+	cqt.split()
+	for _, li := range cqt.leafItems {
+		_, quad := cqt.Contains(li.Pos)
+		cqt.subTrees[quad].InsertItem(li)
+	}
+	cqt.leafItems = nil
+	cqt.leafPointCount++
+	_, quad := cqt.Contains(p)
+	cqt.subTrees[quad].InsertItem(item)
+}
+
+// split turns a leaf into an internal node by generating its four subtrees, the caller has to redistribute the leaf points
+//TODO sparsely generate subtrees
+func (qt *QuadTree) split() {
+	for i := range qt.subTrees {
+		qb := qt.quadrantBounds(quadTreeQuadrant(i))
+		qt.subTrees[i] = &QuadTree{qb, qt.cfg, qt.depth + 1, [4]*QuadTree{}, nil, 0}
+	}
+}
+
+// collapse turns an internal node back into a leaf holding all points of its subtrees
+// the caller has to ensure that the accumulated count fits into a leaf
+func (qt *QuadTree) collapse() {
+	items := qt.collectItems(make([]QuadTreeItem, 0, qt.leafPointCount))
+	qt.subTrees = [4]*QuadTree{}
+	qt.leafItems = items
+	qt.leafPointCount = len(items)
+}
+
+// collectItems appends all items of the tree to dst and returns the extended slice
+func (qt *QuadTree) collectItems(dst []QuadTreeItem) []QuadTreeItem {
+	if qt.isLeaf() {
+		return append(dst, qt.leafItems[:qt.leafPointCount]...)
+	}
+	for _, st := range qt.subTrees {
+		dst = st.collectItems(dst)
+	}
+	return dst
 }
 
 // InsertPoints inserts the given points into the QuadTree, branching the tree where necessary
@@ -88,34 +172,246 @@ func (qt *QuadTree) InsertPoints(p []Vec2f) {
 	}
 }
 
-func (qt *QuadTree) Contains(p Vec2f) (bool, quadTreeQuadrant) {
-	if p.X < qt.x && p.X > qt.x+qt.w && p.Y < qt.y && p.Y > qt.y+qt.h {
+// InsertItems inserts the given items into the QuadTree, branching the tree where necessary
+func (qt *QuadTree) InsertItems(items []QuadTreeItem) {
+	for _, item := range items {
+		qt.InsertItem(item)
+	}
+}
+
+// RemovePoint removes one item at the given position from the QuadTree, regardless of its ID
+// returns false if no item was found there
+func (qt *QuadTree) RemovePoint(p Vec2f) bool {
+	return qt.removeMatching(p, func(QuadTreeItem) bool { return true })
+}
+
+// RemoveItem removes the item with the same position and ID as the given one from the QuadTree
+// returns false if it was not found
+func (qt *QuadTree) RemoveItem(item QuadTreeItem) bool {
+	return qt.removeMatching(item.Pos, func(li QuadTreeItem) bool { return li.ID == item.ID })
+}
+
+// removeMatching removes the first item at position p for which match returns true
+func (qt *QuadTree) removeMatching(p Vec2f, match func(QuadTreeItem) bool) bool {
+	if ok, _ := qt.Contains(p); !ok {
+		return false
+	}
+	// descend to the bounding leaf, remembering the path to update the accumulated counts
+	path := []*QuadTree{}
+	cqt := qt
+	for !cqt.isLeaf() {
+		path = append(path, cqt)
+		_, quad := cqt.Contains(p)
+		cqt = cqt.subTrees[quad]
+	}
+	for i, li := range cqt.leafItems[:cqt.leafPointCount] {
+		if li.Pos != p || !match(li) {
+			continue
+		}
+		// move last point into the freed slot
+		cqt.leafPointCount--
+		cqt.leafItems[i] = cqt.leafItems[cqt.leafPointCount]
+		cqt.leafItems[cqt.leafPointCount] = QuadTreeItem{}
+		cqt.leafItems = cqt.leafItems[:cqt.leafPointCount]
+		for _, pqt := range path {
+			pqt.leafPointCount--
+		}
+		// merge the topmost subtree that dropped below the threshold back into a single leaf
+		for _, pqt := range path {
+			if pqt.leafPointCount < pqt.cfg.leafCapacity {
+				pqt.collapse()
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// leafFor returns the leaf that p is routed to by insertions and lookups, p must be contained in the tree
+func (qt *QuadTree) leafFor(p Vec2f) *QuadTree {
+	cqt := qt
+	for !cqt.isLeaf() {
+		_, quad := cqt.Contains(p)
+		cqt = cqt.subTrees[quad]
+	}
+	return cqt
+}
+
+// MovePoint moves one item at the position from to the position to, restructuring the tree where necessary
+// returns false and leaves the tree untouched if from was not found or to lies outside of the tree
+func (qt *QuadTree) MovePoint(from Vec2f, to Vec2f) bool {
+	return qt.moveMatching(from, to, func(QuadTreeItem) bool { return true })
+}
+
+// MoveItem moves the item with the same position and ID as the given one to the position to, keeping its data
+// returns false and leaves the tree untouched if the item was not found or to lies outside of the tree
+func (qt *QuadTree) MoveItem(item QuadTreeItem, to Vec2f) bool {
+	return qt.moveMatching(item.Pos, to, func(li QuadTreeItem) bool { return li.ID == item.ID })
+}
+
+// moveMatching moves the first item at position from for which match returns true
+func (qt *QuadTree) moveMatching(from Vec2f, to Vec2f, match func(QuadTreeItem) bool) bool {
+	if ok, _ := qt.Contains(to); !ok {
+		return false
+	}
+	if ok, _ := qt.Contains(from); !ok {
+		return false
+	}
+	cqt := qt.leafFor(from)
+	for i, li := range cqt.leafItems[:cqt.leafPointCount] {
+		if li.Pos != from || !match(li) {
+			continue
+		}
+		if qt.leafFor(to) == cqt {
+			// still routed to the same leaf, update in place
+			// the bounds of a leaf include its edges, but points on a split line are routed to only one side, so the bounds are not enough
+			cqt.leafItems[i].Pos = to
+			return true
+		}
+		qt.removeMatching(from, match) // removes the very same first match
+		li.Pos = to
+		qt.InsertItem(li)
+		return true
+	}
+	return false
+}
+
+// Rebuild discards the contents of the QuadTree and bulk loads it top down from the given points
+// this is faster than inserting the points one by one, e.g. when all particles of a scene moved
+func (qt *QuadTree) Rebuild(points []Vec2f) {
+	items := make([]QuadTreeItem, len(points))
+	for i, p := range points {
+		items[i].Pos = p
+	}
+	qt.RebuildItems(items)
+}
+
+// RebuildItems discards the contents of the QuadTree and bulk loads it top down from the given items
+func (qt *QuadTree) RebuildItems(items []QuadTreeItem) {
+	inside := make([]QuadTreeItem, 0, len(items))
+	for _, item := range items {
+		if ok, _ := qt.Contains(item.Pos); ok {
+			inside = append(inside, item)
+		}
+	}
+	qt.subTrees = [4]*QuadTree{}
+	qt.leafItems = nil
+	qt.bulkLoad(inside)
+}
+
+// bulkLoad fills an empty node with the given items, which must all be contained in it
+func (qt *QuadTree) bulkLoad(items []QuadTreeItem) {
+	qt.leafPointCount = len(items)
+	if len(items) <= qt.cfg.leafCapacity || qt.depth >= qt.cfg.maxDepth {
+		qt.leafItems = append([]QuadTreeItem{}, items...)
+		return
+	}
+	qt.split()
+	var quadItems [4][]QuadTreeItem
+	for _, item := range items {
+		_, quad := qt.Contains(item.Pos)
+		quadItems[quad] = append(quadItems[quad], item)
+	}
+	for i, st := range qt.subTrees {
+		st.bulkLoad(quadItems[i])
+	}
+}
+
+// Contains returns whether p lies inside of the bounds, and if so, which quadrant of them it lies in
+func (qb quadBounds) Contains(p Vec2f) (bool, quadTreeQuadrant) {
+	if p.X < qb.x || p.X > qb.x+qb.w || p.Y < qb.y || p.Y > qb.y+qb.h {
 		return false, -1
 	}
-	xsign := int(math.Copysign(1, (qt.x+qt.w/2)-p.X)+1) / 2
-	ysign := int(math.Copysign(1, (qt.y+qt.h/2)-p.Y)+1) / 2
+	xsign := int(math.Copysign(1, (qb.x+qb.w/2)-p.X)+1) / 2
+	ysign := int(math.Copysign(1, (qb.y+qb.h/2)-p.Y)+1) / 2
 	ysign = ysign ^ 1 // graphics coordinate system is other way around, so switch y axis
 	return true, quadTreeQuadrant((xsign ^ ysign) + ysign + ysign)
 }
 
-func (qt *QuadTree) Intersects(x float64, y float64, w float64, h float64) bool {
-	return !(qt.x+qt.w < x || x+w < qt.x || qt.y+qt.h < y || y+h < qt.y)
+// Intersects returns true if the given region overlaps or touches the bounds
+func (qb quadBounds) Intersects(x float64, y float64, w float64, h float64) bool {
+	return !(qb.x+qb.w < x || x+w < qb.x || qb.y+qb.h < y || y+h < qb.y)
 }
 
-//TODO make this iterative
-func (qt *QuadTree) GetPoints() (results []Vec2f) {
-	if qt.isLeaf() {
-		return qt.leafPoints[:qt.leafPointCount]
+// quadrantBounds returns the bounds of the given quadrant
+func (qb quadBounds) quadrantBounds(quad quadTreeQuadrant) quadBounds {
+	hw := qb.w / 2
+	hh := qb.h / 2
+	switch quad {
+	case topRight:
+		return quadBounds{qb.x + hw, qb.y, hw, hh}
+	case topLeft:
+		return quadBounds{qb.x, qb.y, hw, hh}
+	case bottomLeft:
+		return quadBounds{qb.x, qb.y + hh, hw, hh}
 	}
-	for _, st := range qt.subTrees {
-		results = append(results, st.GetPoints()...)
+	return quadBounds{qb.x + hw, qb.y + hh, hw, hh}
+}
+
+// Bounds returns the position and extent of the quad
+func (qb quadBounds) Bounds() Rect2f {
+	return Rect2f{qb.x, qb.y, qb.w, qb.h}
+}
+
+// Depth returns the depth of this node, the root of a tree is at depth 0
+func (qt *QuadTree) Depth() int {
+	return qt.depth
+}
+
+// Len returns the number of points stored in the tree
+func (qt *QuadTree) Len() int {
+	return qt.leafPointCount
+}
+
+// IsLeaf returns true if this node holds points directly instead of subtrees
+func (qt *QuadTree) IsLeaf() bool {
+	return qt.isLeaf()
+}
+
+// GetPoints returns all points stored in the tree
+func (qt *QuadTree) GetPoints() []Vec2f {
+	return itemPositions(qt.GetItems())
+}
+
+// GetItems returns all items stored in the tree
+func (qt *QuadTree) GetItems() []QuadTreeItem {
+	return qt.collectItems(make([]QuadTreeItem, 0, qt.leafPointCount))
+}
+
+// QuadTreeNodeInfo describes a single node of a QuadTree as seen by Walk
+type QuadTreeNodeInfo struct {
+	Bounds Rect2f
+	Depth  int
+	Leaf   bool
+	Count  int            // number of points in this node and all its subtrees
+	Items  []QuadTreeItem // items of a leaf, nil for internal nodes, must not be modified
+}
+
+// Walk visits all nodes of the tree depth first, parents before their children in quadrant order
+// if visit returns false the children of that node are skipped
+func (qt *QuadTree) Walk(visit func(node QuadTreeNodeInfo) bool) {
+	stack := []*QuadTree{qt}
+	for len(stack) > 0 {
+		cqt := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		info := QuadTreeNodeInfo{cqt.Bounds(), cqt.depth, cqt.isLeaf(), cqt.leafPointCount, nil}
+		if info.Leaf {
+			info.Items = cqt.leafItems[:cqt.leafPointCount]
+		}
+		if !visit(info) || info.Leaf {
+			continue
+		}
+		// push in reverse so the first quadrant is visited first
+		for i := len(cqt.subTrees) - 1; i >= 0; i-- {
+			stack = append(stack, cqt.subTrees[i])
+		}
 	}
-	return nil
 }
 
-// SignedDistanceToPoint returns a relative distance value from p to the QuadTrees bounding box
+// SignedDistanceToPoint returns a relative distance value from p to the bounding box
 // returns >0 if outside the box, <0 if inside, and 0 when exactly on the line
-func (qt *QuadTree) SignedDistanceToPoint(p Vec2f) float64 {
+func (qb quadBounds) SignedDistanceToPoint(p Vec2f) float64 {
 	/* https://stackoverflow.com/questions/30545052/calculate-signed-distance-between-point-and-rectangle#30545544
 	float sdAxisAlignedRect(vec2 uv, vec2 tl, vec2 br)
 	{
@@ -123,8 +419,8 @@ func (qt *QuadTree) SignedDistanceToPoint(p Vec2f) float64 {
 		return length(max(vec2(0.0), d)) + min(0.0, max(d.x, d.y));
 	}*/
 	d := Vec2f{
-		math.Max(qt.x-p.X, p.X-(qt.x+qt.w)),
-		math.Max(qt.y-p.Y, p.Y-(qt.y+qt.h)),
+		math.Max(qb.x-p.X, p.X-(qb.x+qb.w)),
+		math.Max(qb.y-p.Y, p.Y-(qb.y+qb.h)),
 	}
 	l := math.Hypot(
 		math.Max(0, d.X),
@@ -134,79 +430,182 @@ func (qt *QuadTree) SignedDistanceToPoint(p Vec2f) float64 {
 }
 
 // QueryRange returns all leaf points of QuadTree inside the given region
+func (qt *QuadTree) QueryRange(x float64, y float64, w float64, h float64) []Vec2f {
+	return itemPositions(qt.QueryRangeItems(x, y, w, h))
+}
+
+// QueryRangeItems returns all items of QuadTree inside the given region
+func (qt *QuadTree) QueryRangeItems(x float64, y float64, w float64, h float64) []QuadTreeItem {
+	return qt.QueryShapeItems(Rect2f{x, y, w, h})
+}
+
+// QueryRadius returns all leaf points of QuadTree within distance r of the point p
+func (qt *QuadTree) QueryRadius(p Vec2f, r float64) []Vec2f {
+	return itemPositions(qt.QueryRadiusItems(p, r))
+}
+
+// QueryRadiusItems returns all items of QuadTree within distance r of the point p
+func (qt *QuadTree) QueryRadiusItems(p Vec2f, r float64) []QuadTreeItem {
+	return qt.QueryShapeItems(Circle2f{p, r})
+}
+
+// QueryPolygon returns all leaf points of QuadTree inside the polygon given by its vertices
+func (qt *QuadTree) QueryPolygon(vertices []Vec2f) []Vec2f {
+	return itemPositions(qt.QueryShapeItems(Polygon2f{vertices}))
+}
+
+// QueryShape returns all leaf points of QuadTree inside the given shape
+// any shape can be used, as long as it can tell whether it intersects the bounds of a node and whether it contains a point
+func (qt *QuadTree) QueryShape(s Shape2f) []Vec2f {
+	return itemPositions(qt.QueryShapeItems(s))
+}
+
+// QueryShapeItems returns all items of QuadTree inside the given shape
 //TODO make this iterative
-func (qt *QuadTree) QueryRange(x float64, y float64, w float64, h float64) (results []Vec2f) {
-	//TODO optimization: if a subtree is entirely contained in the range, skip checking and append all its combined points
-	results = []Vec2f{}
-	if !qt.Intersects(x, y, w, h) {
-		// return empty list if range doesnt intersect this tree
+func (qt *QuadTree) QueryShapeItems(s Shape2f) (results []QuadTreeItem) {
+	//TODO optimization: if a subtree is entirely contained in the shape, skip checking and append all its combined points
+	results = []QuadTreeItem{}
+	if qt.leafPointCount == 0 || !s.IntersectsRect(qt.x, qt.y, qt.w, qt.h) {
+		// return empty list if the shape doesnt intersect this tree
 		return
 	}
 	if qt.isLeaf() {
 		// is leaf, check and add children where neccessary
-		for _, p := range qt.leafPoints[:qt.leafPointCount] {
-			if !(p.X < qt.x && p.X > qt.x+qt.w && p.Y < qt.y && p.Y > qt.y+qt.h) {
+		for _, li := range qt.leafItems[:qt.leafPointCount] {
+			if s.ContainsPoint(li.Pos) {
 				// point contained, append
-				results = append(results, p)
+				results = append(results, li)
 			}
 		}
 		return
 	}
 	for _, st := range qt.subTrees {
-		results = append(results, st.QueryRange(x, y, w, h)...)
+		results = append(results, st.QueryShapeItems(s)...)
 	}
 	return
 }
 
-// QueryKNN returns the k nearest neighbors to the given point p
-//TODO make this iterative
+// quadTreeKNNEntry is a subtree waiting in the QueryKNN priority queue
+type quadTreeKNNEntry struct {
+	dist float64 // lower bound for the distance of all items in the subtree from the query point
+	tree *QuadTree
+}
+
+// quadTreeKNNQueue is a min-heap of quadTreeKNNEntry ordered by distance, for use with container/heap
+type quadTreeKNNQueue []quadTreeKNNEntry
+
+func (q quadTreeKNNQueue) Len() int            { return len(q) }
+func (q quadTreeKNNQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q quadTreeKNNQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *quadTreeKNNQueue) Push(x interface{}) { *q = append(*q, x.(quadTreeKNNEntry)) }
+func (q *quadTreeKNNQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// push works as heap.Push does, but avoids boxing the entry into an interface
+func (q *quadTreeKNNQueue) push(e quadTreeKNNEntry) {
+	*q = append(*q, e)
+	heap.Fix(q, len(*q)-1)
+}
+
+// pop works as heap.Pop does, but avoids boxing the entry into an interface
+func (q *quadTreeKNNQueue) pop() quadTreeKNNEntry {
+	old := *q
+	e := old[0]
+	old[0] = old[len(old)-1]
+	*q = old[:len(old)-1]
+	if len(*q) > 0 {
+		heap.Fix(q, 0)
+	}
+	return e
+}
+
+// quadTreeKNNCandidate is one of the k closest items found so far by QueryKNN
+type quadTreeKNNCandidate struct {
+	dist2 float64 // squared distance from the query point
+	item  QuadTreeItem
+}
+
+// QueryKNN returns the k nearest neighbors to the given point p, sorted by ascending distance
+// uses a best-first search: subtrees are visited in order of their distance to p, and the search stops
+// as soon as k points have been found that are closer than any remaining subtree
 func (qt *QuadTree) QueryKNN(p Vec2f, k int) []Vec2f {
-	/*//TODO use a proper priority queue
-	type prioTree struct {
-		priority float64
-		tree     *QuadTree
-	}
-	ptqPush := func(ptq *[]prioTree, npt prioTree) {
-		for i, pt := range *ptq {
-			if pt.priority > npt.priority {
-				*ptq = append((*ptq)[:i+1], (*ptq)[i:]...)
-				(*ptq)[i] = npt
-				return
-			}
+	return itemPositions(qt.QueryKNNItems(p, k))
+}
+
+// QueryKNNItems returns the k items nearest to the given point p, sorted by ascending distance
+func (qt *QuadTree) QueryKNNItems(p Vec2f, k int) (results []QuadTreeItem) {
+	if k <= 0 || qt.leafPointCount == 0 {
+		return nil
+	}
+	// the k closest items so far are kept sorted, subtrees farther away than the k-th of them can not contribute anymore
+	candidates := make([]quadTreeKNNCandidate, 0, k)
+	bound2 := func() float64 {
+		if len(candidates) < k {
+			return math.Inf(1)
 		}
-		*ptq = append(*ptq, npt)
+		return candidates[k-1].dist2
 	}
-	ptqPop := func(ptq *[]prioTree) (pt prioTree) {
-		pt = (*ptq)[0]
-		*ptq = (*ptq)[1:]
-		return
-	}*/
-	return nil //TODO
-}
-
-//DEBUG REMOVE
-func DrawQuadTree(dc *gg.Context, tree *QuadTree) {
-	// draw border os my quad
-	dc.SetRGB(0, 0, 0)
-	dc.SetLineWidth(1)
-	dc.DrawRectangle(tree.x, tree.y, tree.w, tree.h)
-	dc.Stroke()
-	if tree.isLeaf() {
-		for i, p := range tree.leafPoints {
-			if i >= tree.leafPointCount {
-				break
+	pq := &quadTreeKNNQueue{{math.Max(0, qt.SignedDistanceToPoint(p)), qt}}
+	for pq.Len() > 0 {
+		e := pq.pop()
+		if e.dist*e.dist > bound2() {
+			break // nothing left in the queue is closer
+		}
+		if e.tree.isLeaf() {
+			for _, li := range e.tree.leafItems[:e.tree.leafPointCount] {
+				dx, dy := p.X-li.Pos.X, p.Y-li.Pos.Y
+				d2 := dx*dx + dy*dy
+				if d2 >= bound2() {
+					continue
+				}
+				// insertion into the short sorted candidate list
+				i := len(candidates)
+				if i < k {
+					candidates = append(candidates, quadTreeKNNCandidate{})
+				} else {
+					i--
+				}
+				for ; i > 0 && candidates[i-1].dist2 > d2; i-- {
+					candidates[i] = candidates[i-1]
+				}
+				candidates[i] = quadTreeKNNCandidate{d2, li}
 			}
-			i++
-			// draw point
-			dc.SetRGB(1, 0, 0)
-			dc.DrawCircle(p.X, p.Y, 2)
-			dc.Fill()
-		}
-		dc.SetRGB(0, 0, 0)
-		dc.DrawStringAnchored(fmt.Sprintf("%d", tree.leafPointCount), tree.x+tree.w/2, tree.y+tree.h/2, 0.5, 0.5)
-		return
+			continue
+		}
+		for _, st := range e.tree.subTrees {
+			if st.leafPointCount == 0 {
+				continue // skip empty subtrees entirely
+			}
+			if d := math.Max(0, st.SignedDistanceToPoint(p)); d*d <= bound2() {
+				pq.push(quadTreeKNNEntry{d, st})
+			}
+		}
 	}
-	for _, st := range tree.subTrees {
-		DrawQuadTree(dc, st)
+	results = make([]QuadTreeItem, len(candidates))
+	for i, c := range candidates {
+		results[i] = c.item
+	}
+	return results
+}
+
+// Nearest returns the point in the QuadTree closest to p, ok is false if the tree is empty
+func (qt *QuadTree) Nearest(p Vec2f) (nearest Vec2f, ok bool) {
+	knn := qt.QueryKNN(p, 1)
+	if len(knn) == 0 {
+		return Vec2f{}, false
+	}
+	return knn[0], true
+}
+
+// NearestItem returns the item in the QuadTree closest to p, ok is false if the tree is empty
+func (qt *QuadTree) NearestItem(p Vec2f) (nearest QuadTreeItem, ok bool) {
+	knn := qt.QueryKNNItems(p, 1)
+	if len(knn) == 0 {
+		return QuadTreeItem{}, false
 	}
+	return knn[0], true
 }
@@ -0,0 +1,504 @@
+package gah
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// randomPoints returns n points spread uniformly over [0, w] x [0, h]
+func randomPoints(rng *rand.Rand, n int, w float64, h float64) []Vec2f {
+	points := make([]Vec2f, n)
+	for i := range points {
+		points[i] = Vec2f{rng.Float64() * w, rng.Float64() * h}
+	}
+	return points
+}
+
+// bruteKNNDistances returns the distances from p to its k nearest points in ascending order, by sorting all of them
+func bruteKNNDistances(points []Vec2f, p Vec2f, k int) []float64 {
+	dists := make([]float64, len(points))
+	for i, q := range points {
+		dists[i] = math.Hypot(p.X-q.X, p.Y-q.Y)
+	}
+	sort.Float64s(dists)
+	if k < len(dists) {
+		dists = dists[:k]
+	}
+	return dists
+}
+
+// checkKNN compares the result of a KNN query against bruteKNNDistances, ties may be broken in any order
+func checkKNN(t *testing.T, points []Vec2f, p Vec2f, k int, got []Vec2f) {
+	t.Helper()
+	want := bruteKNNDistances(points, p, k)
+	if k <= 0 {
+		want = nil
+	}
+	if len(got) != len(want) {
+		t.Fatalf("QueryKNN(%v, %d) returned %d points, want %d", p, k, len(got), len(want))
+	}
+	for i, q := range got {
+		if d := math.Hypot(p.X-q.X, p.Y-q.Y); d != want[i] {
+			t.Fatalf("QueryKNN(%v, %d)[%d] = %v at distance %v, want distance %v", p, k, i, q, d, want[i])
+		}
+	}
+}
+
+func TestQuadTreeQueryKNN(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	clustered := randomPoints(rng, 100, 1000, 1000)
+	for i := 0; i < 200; i++ {
+		clustered = append(clustered, Vec2f{500 + rng.NormFloat64()*20, 300 + rng.NormFloat64()*20})
+	}
+	grid := []Vec2f{}
+	for x := 0.0; x <= 1000; x += 125 {
+		for y := 0.0; y <= 1000; y += 125 {
+			grid = append(grid, Vec2f{x, y})
+		}
+	}
+	pointSets := []struct {
+		name   string
+		points []Vec2f
+	}{
+		{"empty", nil},
+		{"single", []Vec2f{{10, 20}}},
+		{"uniform", randomPoints(rng, 500, 1000, 1000)},
+		{"clustered", clustered},
+		{"grid with ties", grid},
+	}
+	for _, set := range pointSets {
+		t.Run(set.name, func(t *testing.T) {
+			qt := NewQuadTree(0, 0, 1000, 1000)
+			qt.InsertPoints(set.points)
+			queries := append(randomPoints(rng, 50, 1000, 1000), Vec2f{-300, 500}, Vec2f{1200, 1400}, Vec2f{500, 500})
+			for _, p := range queries {
+				for _, k := range []int{0, 1, 3, 10, len(set.points), len(set.points) + 5} {
+					checkKNN(t, set.points, p, k, qt.QueryKNN(p, k))
+				}
+			}
+		})
+	}
+}
+
+// checkQuadTreeStructure verifies the accumulated counts of the tree, that every point lies in its leaf,
+// that no leaf above the maximum depth is overfull and that no internal node was left uncollapsed
+func checkQuadTreeStructure(t *testing.T, qt *QuadTree) {
+	t.Helper()
+	if qt.isLeaf() {
+		if len(qt.leafItems) != qt.leafPointCount {
+			t.Fatalf("leaf at depth %d holds %d points but counts %d", qt.depth, len(qt.leafItems), qt.leafPointCount)
+		}
+		if qt.leafPointCount > qt.cfg.leafCapacity && qt.depth < qt.cfg.maxDepth {
+			t.Fatalf("leaf at depth %d holds %d points, above the threshold", qt.depth, qt.leafPointCount)
+		}
+		for _, li := range qt.leafItems {
+			if ok, _ := qt.Contains(li.Pos); !ok {
+				t.Fatalf("leaf %v holds the point %v outside of it", []float64{qt.x, qt.y, qt.w, qt.h}, li.Pos)
+			}
+		}
+		return
+	}
+	sum := 0
+	for _, st := range qt.subTrees {
+		if st.depth != qt.depth+1 || st.cfg != qt.cfg {
+			t.Fatalf("subtree at depth %d below a node at depth %d, or with another config", st.depth, qt.depth)
+		}
+		checkQuadTreeStructure(t, st)
+		sum += st.leafPointCount
+	}
+	if sum != qt.leafPointCount {
+		t.Fatalf("internal node at depth %d counts %d points, its subtrees hold %d", qt.depth, qt.leafPointCount, sum)
+	}
+	if qt.leafPointCount < qt.cfg.leafCapacity {
+		t.Fatalf("internal node at depth %d holds only %d points, it should have been collapsed", qt.depth, qt.leafPointCount)
+	}
+}
+
+func TestQuadTreeRemoveCollapse(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomPoints(rng, 300, 1000, 1000)
+	qt := NewQuadTree(0, 0, 1000, 1000)
+	qt.InsertPoints(points)
+	checkQuadTreeStructure(t, qt)
+	rng.Shuffle(len(points), func(i, j int) { points[i], points[j] = points[j], points[i] })
+	for len(points) > 0 {
+		p := points[len(points)-1]
+		points = points[:len(points)-1]
+		if !qt.RemovePoint(p) {
+			t.Fatalf("RemovePoint(%v) = false", p)
+		}
+		if qt.RemovePoint(p) {
+			t.Fatalf("RemovePoint(%v) = true for a point that was already removed", p)
+		}
+		checkQuadTreeStructure(t, qt)
+		if len(points)%50 == 0 {
+			checkSamePoints(t, "QueryRange", qt.QueryRange(0, 0, 1000, 1000), points)
+		}
+	}
+	if !qt.isLeaf() || qt.leafPointCount != 0 {
+		t.Errorf("tree is not a single empty leaf after removing every point")
+	}
+}
+
+// TestQuadTreeMoveOntoSplitLine moves points onto the split lines of internal nodes, where the leaf bounds of both sides contain them
+func TestQuadTreeMoveOntoSplitLine(t *testing.T) {
+	// the root splits at 500, its top right quadrant holds more points than a leaf and splits at (750, 250)
+	points := []Vec2f{
+		{250, 250}, {250, 750}, {750, 750},
+		{600, 100}, {750, 250}, {900, 400}, {550, 50}, {950, 50}, {550, 450}, {950, 450}, {700, 300}, {800, 200}, {650, 150}, {850, 350},
+	}
+	tests := []struct {
+		name     string
+		from, to Vec2f
+	}{
+		{"vertical root split", Vec2f{750, 250}, Vec2f{500, 250}},
+		{"horizontal root split", Vec2f{250, 750}, Vec2f{250, 500}},
+		{"root center", Vec2f{750, 750}, Vec2f{500, 500}},
+		{"nested split", Vec2f{600, 100}, Vec2f{750, 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt := NewQuadTree(0, 0, 1000, 1000)
+			qt.InsertPoints(points)
+			if !qt.MovePoint(tt.from, tt.to) {
+				t.Fatalf("MovePoint(%v, %v) = false", tt.from, tt.to)
+			}
+			checkQuadTreeStructure(t, qt)
+			if got := qt.QueryRadius(tt.to, 0); len(got) != 1 {
+				t.Errorf("QueryRadius(%v, 0) found %d points, want 1", tt.to, len(got))
+			}
+			if !qt.RemovePoint(tt.to) {
+				t.Errorf("RemovePoint(%v) = false after moving there", tt.to)
+			}
+			if qt.leafPointCount != len(points)-1 {
+				t.Errorf("tree holds %d points, want %d", qt.leafPointCount, len(points)-1)
+			}
+		})
+	}
+}
+
+// TestQuadTreeMoveRemoveRandom moves random points on an integer grid, so that many of them land on split lines
+func TestQuadTreeMoveRemoveRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randPoint := func() Vec2f {
+		return Vec2f{float64(rng.Intn(17) * 64), float64(rng.Intn(17) * 64)}
+	}
+	qt := NewQuadTree(0, 0, 1024, 1024)
+	points := make([]Vec2f, 400)
+	for i := range points {
+		points[i] = randPoint()
+		qt.InsertPoint(points[i])
+	}
+	for i := 0; i < 4000; i++ {
+		j := rng.Intn(len(points))
+		to := randPoint()
+		if !qt.MovePoint(points[j], to) {
+			t.Fatalf("move %d: MovePoint(%v, %v) = false", i, points[j], to)
+		}
+		points[j] = to
+	}
+	checkQuadTreeStructure(t, qt)
+	if qt.MovePoint(Vec2f{1, 1}, Vec2f{2, 2}) || qt.MovePoint(points[0], Vec2f{-1, 0}) {
+		t.Errorf("MovePoint from a missing point or to the outside = true")
+	}
+	for _, p := range points {
+		if !qt.RemovePoint(p) {
+			t.Fatalf("RemovePoint(%v) = false after moving there", p)
+		}
+	}
+	if qt.leafPointCount != 0 {
+		t.Errorf("tree holds %d points after removing every point, want 0", qt.leafPointCount)
+	}
+}
+
+func TestQuadTreeRebuild(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomPoints(rng, 500, 1000, 1000)
+	qt := NewQuadTree(0, 0, 1000, 1000)
+	qt.InsertPoints(randomPoints(rng, 100, 1000, 1000)) // replaced by the rebuild
+	qt.Rebuild(append(points, Vec2f{-5, 500}, Vec2f{500, 1001}))
+	checkQuadTreeStructure(t, qt)
+	checkSamePoints(t, "QueryRange", qt.QueryRange(0, 0, 1000, 1000), points)
+	for _, p := range randomPoints(rng, 20, 1000, 1000) {
+		checkKNN(t, points, p, 10, qt.QueryKNN(p, 10))
+	}
+	// the rebuilt tree keeps working with single insertions and removals
+	qt.InsertPoint(Vec2f{1, 2})
+	if !qt.RemovePoint(points[0]) || !qt.RemovePoint(Vec2f{1, 2}) {
+		t.Errorf("RemovePoint after Rebuild = false")
+	}
+	checkQuadTreeStructure(t, qt)
+}
+
+// TestQuadTreeDuplicates stores more coincident points than fit into a leaf, which can not be separated by splitting
+func TestQuadTreeDuplicates(t *testing.T) {
+	p := Vec2f{300, 700}
+	duplicates := make([]Vec2f, 5*QuadTreeLeafThreshold)
+	for i := range duplicates {
+		duplicates[i] = p
+	}
+	others := []Vec2f{{10, 10}, {990, 10}, {500, 500}}
+	for _, bulk := range []bool{false, true} {
+		qt := NewQuadTree(0, 0, 1000, 1000)
+		if bulk {
+			qt.Rebuild(append(duplicates, others...))
+		} else {
+			qt.InsertPoints(append(duplicates, others...))
+		}
+		checkQuadTreeStructure(t, qt)
+		if got := qt.QueryRadius(p, 0); len(got) != len(duplicates) {
+			t.Fatalf("bulk %v: QueryRadius found %d duplicates, want %d", bulk, len(got), len(duplicates))
+		}
+		if got := qt.QueryKNN(p, len(duplicates)+1); len(got) != len(duplicates)+1 || got[len(duplicates)] != others[2] {
+			t.Fatalf("bulk %v: QueryKNN(%v, %d) = %v", bulk, p, len(duplicates)+1, got)
+		}
+		if !qt.MovePoint(p, Vec2f{301, 700}) {
+			t.Fatalf("bulk %v: MovePoint of a duplicate = false", bulk)
+		}
+		for range duplicates[1:] {
+			if !qt.RemovePoint(p) {
+				t.Fatalf("bulk %v: RemovePoint of a duplicate = false", bulk)
+			}
+		}
+		checkQuadTreeStructure(t, qt)
+		if qt.leafPointCount != len(others)+1 {
+			t.Errorf("bulk %v: tree holds %d points, want %d", bulk, qt.leafPointCount, len(others)+1)
+		}
+	}
+}
+
+func TestQuadTreeItems(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomPoints(rng, 300, 1000, 1000)
+	items := make([]QuadTreeItem, len(points))
+	for i, p := range points {
+		items[i] = QuadTreeItem{p, i, i * 10}
+	}
+	// several items at the same position, only told apart by their ID
+	for i := 0; i < 3; i++ {
+		items = append(items, QuadTreeItem{points[0], len(items), len(items) * 10})
+	}
+	checkItems := func(t *testing.T, qt *QuadTree, items []QuadTreeItem) {
+		t.Helper()
+		byID := map[int]QuadTreeItem{}
+		for _, item := range items {
+			byID[item.ID] = item
+		}
+		check := func(query string, got []QuadTreeItem, want int) {
+			t.Helper()
+			if len(got) != want {
+				t.Fatalf("%s returned %d items, want %d", query, len(got), want)
+			}
+			seen := map[int]bool{}
+			for _, item := range got {
+				if byID[item.ID] != item || seen[item.ID] {
+					t.Fatalf("%s returned the item %v, which was not stored or returned twice", query, item)
+				}
+				seen[item.ID] = true
+			}
+		}
+		for _, p := range randomPoints(rng, 20, 1000, 1000) {
+			inRange, inRadius := 0, 0
+			for _, item := range items {
+				if item.Pos.X >= p.X && item.Pos.X <= p.X+200 && item.Pos.Y >= p.Y && item.Pos.Y <= p.Y+100 {
+					inRange++
+				}
+				if math.Hypot(p.X-item.Pos.X, p.Y-item.Pos.Y) <= 80 {
+					inRadius++
+				}
+			}
+			check("QueryRangeItems", qt.QueryRangeItems(p.X, p.Y, 200, 100), inRange)
+			check("QueryRadiusItems", qt.QueryRadiusItems(p, 80), inRadius)
+			knn := qt.QueryKNNItems(p, 5)
+			check("QueryKNNItems", knn, 5)
+			checkKNN(t, itemPositions(items), p, 5, itemPositions(knn))
+			if nearest, ok := qt.NearestItem(p); !ok || byID[nearest.ID] != nearest || math.Hypot(p.X-nearest.Pos.X, p.Y-nearest.Pos.Y) != math.Hypot(p.X-knn[0].Pos.X, p.Y-knn[0].Pos.Y) {
+				t.Fatalf("NearestItem(%v) = %v, %v, want an item as close as %v", p, nearest, ok, knn[0])
+			}
+		}
+	}
+	for _, bulk := range []bool{false, true} {
+		qt := NewQuadTree(0, 0, 1000, 1000)
+		if bulk {
+			qt.RebuildItems(items)
+		} else {
+			qt.InsertItems(items)
+		}
+		current := append([]QuadTreeItem{}, items...)
+		checkItems(t, qt, current)
+		// remove the duplicate with the last ID, then move the one with the first ID
+		last := current[len(current)-1]
+		if qt.RemoveItem(QuadTreeItem{last.Pos, -1, nil}) {
+			t.Fatalf("RemoveItem with an unknown ID = true")
+		}
+		if !qt.RemoveItem(last) {
+			t.Fatalf("RemoveItem(%v) = false", last)
+		}
+		current = current[:len(current)-1]
+		to := Vec2f{999, 1}
+		if !qt.MoveItem(current[0], to) {
+			t.Fatalf("MoveItem(%v, %v) = false", current[0], to)
+		}
+		current[0].Pos = to
+		checkQuadTreeStructure(t, qt)
+		checkItems(t, qt, current)
+		if got := qt.QueryRadiusItems(to, 0); len(got) != 1 || got[0] != current[0] {
+			t.Fatalf("QueryRadiusItems(%v, 0) = %v after MoveItem, want %v", to, got, current[0])
+		}
+		// plain points are items with a zero ID and nil Data
+		qt.InsertPoint(Vec2f{1, 1})
+		if got, _ := qt.NearestItem(Vec2f{0, 0}); got != (QuadTreeItem{Vec2f{1, 1}, 0, nil}) {
+			t.Fatalf("NearestItem returned %v for a plain point", got)
+		}
+	}
+}
+
+// quadTreeMaxLeafDepth returns the depth of the deepest leaf of the tree
+func quadTreeMaxLeafDepth(qt *QuadTree) int {
+	if qt.isLeaf() {
+		return qt.depth
+	}
+	depth := 0
+	for _, st := range qt.subTrees {
+		if d := quadTreeMaxLeafDepth(st); d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+func TestQuadTreeOptions(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomPoints(rng, 300, 1000, 1000)
+	// a tight cluster that would need deep subdivisions at small capacities
+	for i := 0; i < 40; i++ {
+		points = append(points, Vec2f{700 + rng.Float64()*1e-6, 200 + rng.Float64()*1e-6})
+	}
+	tests := []struct {
+		name         string
+		opts         []QuadTreeOption
+		leafCapacity int
+		maxDepth     int
+	}{
+		{"defaults", nil, QuadTreeLeafThreshold, QuadTreeDefaultMaxDepth},
+		{"capacity 1", []QuadTreeOption{QuadTreeLeafCapacity(1)}, 1, QuadTreeDefaultMaxDepth},
+		{"capacity 2 depth 3", []QuadTreeOption{QuadTreeLeafCapacity(2), QuadTreeMaxDepth(3)}, 2, 3},
+		{"depth 0", []QuadTreeOption{QuadTreeMaxDepth(0)}, QuadTreeLeafThreshold, 0},
+		{"invalid values are clamped", []QuadTreeOption{QuadTreeLeafCapacity(0), QuadTreeMaxDepth(-2)}, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, bulk := range []bool{false, true} {
+				qt := NewQuadTree(0, 0, 1000, 1000, tt.opts...)
+				if qt.cfg.leafCapacity != tt.leafCapacity || qt.cfg.maxDepth != tt.maxDepth {
+					t.Fatalf("config = %+v, want capacity %d and depth %d", *qt.cfg, tt.leafCapacity, tt.maxDepth)
+				}
+				if bulk {
+					qt.Rebuild(points)
+				} else {
+					qt.InsertPoints(points)
+				}
+				checkQuadTreeStructure(t, qt)
+				if depth := quadTreeMaxLeafDepth(qt); depth > tt.maxDepth {
+					t.Fatalf("bulk %v: deepest leaf at depth %d, above the maximum of %d", bulk, depth, tt.maxDepth)
+				}
+				for _, p := range randomPoints(rng, 10, 1000, 1000) {
+					checkKNN(t, points, p, 8, qt.QueryKNN(p, 8))
+				}
+				for _, p := range points[:100] {
+					if !qt.RemovePoint(p) {
+						t.Fatalf("bulk %v: RemovePoint(%v) = false", bulk, p)
+					}
+				}
+				checkQuadTreeStructure(t, qt)
+			}
+		})
+	}
+}
+
+func TestQuadTreeQueryShape(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomPoints(rng, 1000, 1000, 1000)
+	// points on the vertices and edges of the shapes below
+	points = append(points, Vec2f{100, 100}, Vec2f{500, 300}, Vec2f{200, 500}, Vec2f{300, 400})
+	star := make([]Vec2f, 10)
+	for i := range star {
+		r := 400.0
+		if i%2 == 1 {
+			r = 150
+		}
+		angle := float64(i) * math.Pi / 5
+		star[i] = Vec2f{500 + r*math.Cos(angle), 500 + r*math.Sin(angle)}
+	}
+	shapes := []struct {
+		name  string
+		shape Shape2f
+	}{
+		{"rect", Rect2f{100, 100, 400, 200}},
+		{"circle", Circle2f{Vec2f{300, 500}, 100 * math.Sqrt(2)}},
+		{"concave polygon", Polygon2f{[]Vec2f{{100, 100}, {900, 100}, {900, 900}, {500, 300}, {100, 900}}}},
+		{"star", Polygon2f{star}},
+		{"polygon outside", Polygon2f{[]Vec2f{{-100, -100}, {-10, -100}, {-10, -10}}}},
+		{"empty polygon", Polygon2f{}},
+	}
+	for _, qs := range shapes {
+		t.Run(qs.name, func(t *testing.T) {
+			for _, opts := range [][]QuadTreeOption{nil, {QuadTreeLeafCapacity(1)}} {
+				qt := NewQuadTree(0, 0, 1000, 1000, opts...)
+				qt.InsertPoints(points)
+				want := []Vec2f{}
+				for _, p := range points {
+					if qs.shape.ContainsPoint(p) {
+						want = append(want, p)
+					}
+				}
+				checkSamePoints(t, "QueryShape", qt.QueryShape(qs.shape), want)
+				if poly, ok := qs.shape.(Polygon2f); ok {
+					checkSamePoints(t, "QueryPolygon", qt.QueryPolygon(poly.Points), want)
+				}
+				checkSamePoints(t, "QueryShapeItems", itemPositions(qt.QueryShapeItems(qs.shape)), want)
+			}
+		})
+	}
+}
+
+func TestQuadTreeWalk(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomPoints(rng, 200, 1000, 1000)
+	qt := NewQuadTree(0, 0, 1000, 1000)
+	qt.InsertPoints(points)
+	if qt.Len() != len(points) || qt.Depth() != 0 || qt.IsLeaf() {
+		t.Fatalf("root Len() = %d, Depth() = %d, IsLeaf() = %v", qt.Len(), qt.Depth(), qt.IsLeaf())
+	}
+	checkSamePoints(t, "GetPoints", qt.GetPoints(), points)
+	walked := []Vec2f{}
+	prevDepth := -1
+	qt.Walk(func(node QuadTreeNodeInfo) bool {
+		if node.Depth > prevDepth+1 {
+			t.Fatalf("Walk went from depth %d to %d, parents have to come before their children", prevDepth, node.Depth)
+		}
+		prevDepth = node.Depth
+		if node.Leaf != (node.Items != nil || node.Count == 0) || len(node.Items) > node.Count {
+			t.Fatalf("inconsistent node %+v", node)
+		}
+		for _, item := range node.Items {
+			if !node.Bounds.ContainsPoint(item.Pos) {
+				t.Fatalf("node %v holds the point %v outside of it", node.Bounds, item.Pos)
+			}
+			walked = append(walked, item.Pos)
+		}
+		return true
+	})
+	checkSamePoints(t, "Walk", walked, points)
+	// skipping the children of the root only visits the root
+	visits := 0
+	qt.Walk(func(node QuadTreeNodeInfo) bool {
+		visits++
+		return false
+	})
+	if visits != 1 {
+		t.Errorf("Walk visited %d nodes when skipping the children of the root, want 1", visits)
+	}
+}
@@ -0,0 +1,183 @@
+package gah
+
+import (
+	"container/heap"
+	"image"
+	"image/color"
+	"math"
+)
+
+// QuadTreeArtLeaf is a leaf of an adaptively subdivided image, carrying the average color of the region it covers
+type QuadTreeArtLeaf struct {
+	Bounds Rect2f
+	Depth  int
+	Color  color.RGBA
+	Error  float64 // standard deviation of the colors inside of the region, averaged over the channels
+}
+
+// QuadTreeArtOptions control how far BuildImageQuadTree and BuildTextureQuadTree subdivide
+// subdivision always picks the leaf with the highest error weighted by the 4th root of its area next
+type QuadTreeArtOptions struct {
+	MaxLeafs int     // stop once there are this many leafs, <= 0 for no limit
+	MaxError float64 // leafs with an error at or below this are not split any further
+	MinSize  float64 // leafs are not split if that would make them smaller than this in pixels, clamped to at least 1
+	MaxDepth int     // leafs at this depth are not split any further, <= 0 for QuadTreeDefaultMaxDepth
+}
+
+// quadTreeArtSource provides the summed area tables of a pixel source, to query region statistics in constant time
+type quadTreeArtSource struct {
+	w, h int
+	sum  [4][]float64 // per channel inclusive prefix sums, (w+1)*(h+1) entries each with a zero border
+	sum2 [4][]float64 // same for the squared channel values
+}
+
+// newQuadTreeArtSource samples every pixel of the w x h area once through sample, which returns channel values in [0, 255]
+func newQuadTreeArtSource(w int, h int, sample func(x, y int) [4]float64) *quadTreeArtSource {
+	src := &quadTreeArtSource{w: w, h: h}
+	for c := 0; c < 4; c++ {
+		src.sum[c] = make([]float64, (w+1)*(h+1))
+		src.sum2[c] = make([]float64, (w+1)*(h+1))
+	}
+	stride := w + 1
+	for iy := 0; iy < h; iy++ {
+		for ix := 0; ix < w; ix++ {
+			v := sample(ix, iy)
+			i := (iy+1)*stride + ix + 1
+			for c := 0; c < 4; c++ {
+				src.sum[c][i] = v[c] + src.sum[c][i-1] + src.sum[c][i-stride] - src.sum[c][i-stride-1]
+				src.sum2[c][i] = v[c]*v[c] + src.sum2[c][i-1] + src.sum2[c][i-stride] - src.sum2[c][i-stride-1]
+			}
+		}
+	}
+	return src
+}
+
+// pixelRect returns the pixel range covered by the bounds, rounded to whole pixels and clamped to the source
+func (src *quadTreeArtSource) pixelRect(b Rect2f) (x0, y0, x1, y1 int) {
+	clampI := func(v int, max int) int {
+		if v < 0 {
+			return 0
+		}
+		if v > max {
+			return max
+		}
+		return v
+	}
+	x0 = clampI(int(math.Round(b.X)), src.w)
+	y0 = clampI(int(math.Round(b.Y)), src.h)
+	x1 = clampI(int(math.Round(b.X+b.W)), src.w)
+	y1 = clampI(int(math.Round(b.Y+b.H)), src.h)
+	return
+}
+
+// stats returns the average color and the error of the given region
+func (src *quadTreeArtSource) stats(b Rect2f) (avg color.RGBA, err float64) {
+	x0, y0, x1, y1 := src.pixelRect(b)
+	n := float64((x1 - x0) * (y1 - y0))
+	if n == 0 {
+		return color.RGBA{}, 0
+	}
+	stride := src.w + 1
+	var mean [4]float64
+	for c := 0; c < 4; c++ {
+		s := src.sum[c][y1*stride+x1] - src.sum[c][y0*stride+x1] - src.sum[c][y1*stride+x0] + src.sum[c][y0*stride+x0]
+		s2 := src.sum2[c][y1*stride+x1] - src.sum2[c][y0*stride+x1] - src.sum2[c][y1*stride+x0] + src.sum2[c][y0*stride+x0]
+		mean[c] = s / n
+		if c < 3 {
+			err += math.Sqrt(math.Max(0, s2/n-mean[c]*mean[c]))
+		}
+	}
+	err /= 3
+	return color.RGBA{uint8(math.Round(mean[0])), uint8(math.Round(mean[1])), uint8(math.Round(mean[2])), uint8(math.Round(mean[3]))}, err
+}
+
+// quadTreeArtEntry is a leaf waiting to be split, with its statistics
+type quadTreeArtEntry struct {
+	node  *QuadTree
+	leaf  QuadTreeArtLeaf
+	score float64
+}
+
+// quadTreeArtQueue is a max-heap of quadTreeArtEntry ordered by score, for use with container/heap
+type quadTreeArtQueue []quadTreeArtEntry
+
+func (q quadTreeArtQueue) Len() int            { return len(q) }
+func (q quadTreeArtQueue) Less(i, j int) bool  { return q[i].score > q[j].score }
+func (q quadTreeArtQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *quadTreeArtQueue) Push(x interface{}) { *q = append(*q, x.(quadTreeArtEntry)) }
+func (q *quadTreeArtQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// buildArtQuadTree subdivides the source area by error, see BuildImageQuadTree
+func buildArtQuadTree(src *quadTreeArtSource, opts QuadTreeArtOptions) (*QuadTree, []QuadTreeArtLeaf) {
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = QuadTreeDefaultMaxDepth
+	}
+	minSize := math.Max(opts.MinSize, 1)
+	qt := NewQuadTree(0, 0, float64(src.w), float64(src.h), QuadTreeMaxDepth(maxDepth))
+	newEntry := func(node *QuadTree) quadTreeArtEntry {
+		b := node.Bounds()
+		avg, err := src.stats(b)
+		leaf := QuadTreeArtLeaf{b, node.depth, avg, err}
+		return quadTreeArtEntry{node, leaf, err * math.Pow(b.W*b.H, 0.25)}
+	}
+	splittable := func(e quadTreeArtEntry) bool {
+		return e.leaf.Error > opts.MaxError && e.node.depth < maxDepth && e.leaf.Bounds.W/2 >= minSize && e.leaf.Bounds.H/2 >= minSize
+	}
+	pq := &quadTreeArtQueue{}
+	done := []quadTreeArtEntry{}
+	if root := newEntry(qt); splittable(root) {
+		heap.Push(pq, root)
+	} else {
+		done = append(done, root)
+	}
+	for pq.Len() > 0 && (opts.MaxLeafs <= 0 || pq.Len()+len(done)+3 <= opts.MaxLeafs) {
+		e := heap.Pop(pq).(quadTreeArtEntry)
+		e.node.split()
+		for _, st := range e.node.subTrees {
+			ce := newEntry(st)
+			if splittable(ce) {
+				heap.Push(pq, ce)
+			} else {
+				done = append(done, ce)
+			}
+		}
+	}
+	done = append(done, (*pq)...)
+	// every leaf carries a single item at its center, so the leafs can also be found through the regular queries
+	leafs := make([]QuadTreeArtLeaf, len(done))
+	for i, e := range done {
+		leafs[i] = e.leaf
+		b := e.leaf.Bounds
+		qt.InsertItem(QuadTreeItem{Pos: Vec2f{b.X + b.W/2, b.Y + b.H/2}, ID: i, Data: e.leaf})
+	}
+	return qt, leafs
+}
+
+// BuildImageQuadTree adaptively subdivides the image by color variance instead of point count, for the classic quadtree art effect
+// returns the subdivided QuadTree, in which every leaf holds one item at its center with its QuadTreeArtLeaf as Data, and a list of all leafs
+// the tree covers the area (0, 0) to the size of the image
+func BuildImageQuadTree(img image.Image, opts QuadTreeArtOptions) (*QuadTree, []QuadTreeArtLeaf) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	src := newQuadTreeArtSource(w, h, func(x, y int) [4]float64 {
+		r, g, b, a := ImgGetRGBA(img, x, y)
+		return [4]float64{float64(r), float64(g), float64(b), float64(a)}
+	})
+	return buildArtQuadTree(src, opts)
+}
+
+// BuildTextureQuadTree works as BuildImageQuadTree does, but subdivides the grayscale output of the given texture provider
+// the provider is sampled once for every pixel in the area (x, y) to (x+w, y+h), the tree covers (0, 0) to (w, h)
+func BuildTextureQuadTree(provider TextureCachable, x int, y int, w int, h int, opts QuadTreeArtOptions) (*QuadTree, []QuadTreeArtLeaf) {
+	emin, emax := provider.GetEvalRange()
+	src := newQuadTreeArtSource(w, h, func(ix, iy int) [4]float64 {
+		c := 255 * scaleEvalRange(provider.Eval2(float64(x+ix), float64(y+iy)), emin, emax)
+		return [4]float64{c, c, c, 255}
+	})
+	return buildArtQuadTree(src, opts)
+}
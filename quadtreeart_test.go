@@ -0,0 +1,144 @@
+package gah
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// fillImage returns a w x h image colored by the given function
+func fillImage(w int, h int, at func(x, y int) color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, at(x, y))
+		}
+	}
+	return img
+}
+
+// checkArtLeafs checks that the leafs tile the w x h area exactly and that the tree holds one item per leaf at its center
+func checkArtLeafs(t *testing.T, qt *QuadTree, leafs []QuadTreeArtLeaf, w int, h int) {
+	t.Helper()
+	area := 0.0
+	for _, l := range leafs {
+		area += l.Bounds.W * l.Bounds.H
+	}
+	if area != float64(w*h) {
+		t.Errorf("leafs cover an area of %v, want %v", area, w*h)
+	}
+	items := qt.QueryRangeItems(0, 0, float64(w), float64(h))
+	if len(items) != len(leafs) {
+		t.Fatalf("tree holds %d items, want one for each of the %d leafs", len(items), len(leafs))
+	}
+	for _, item := range items {
+		l := leafs[item.ID]
+		if item.Data.(QuadTreeArtLeaf) != l || item.Pos != (Vec2f{l.Bounds.X + l.Bounds.W/2, l.Bounds.Y + l.Bounds.H/2}) {
+			t.Errorf("item %+v does not match its leaf %+v", item, l)
+		}
+	}
+}
+
+func TestBuildImageQuadTreeUniform(t *testing.T) {
+	c := color.RGBA{10, 20, 30, 255}
+	img := fillImage(64, 48, func(x, y int) color.RGBA { return c })
+	qt, leafs := BuildImageQuadTree(img, QuadTreeArtOptions{})
+	if len(leafs) != 1 || leafs[0].Color != c || leafs[0].Error != 0 || leafs[0].Bounds != (Rect2f{0, 0, 64, 48}) {
+		t.Fatalf("uniform image leafs = %+v, want one leaf over the whole image", leafs)
+	}
+	checkArtLeafs(t, qt, leafs, 64, 48)
+}
+
+func TestBuildImageQuadTreeQuadrants(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	img := fillImage(64, 64, func(x, y int) color.RGBA {
+		if x < 32 {
+			return red
+		}
+		return blue
+	})
+	qt, leafs := BuildImageQuadTree(img, QuadTreeArtOptions{})
+	if len(leafs) != 4 {
+		t.Fatalf("two colored halves give %d leafs, want the 4 quadrants", len(leafs))
+	}
+	for _, l := range leafs {
+		want := red
+		if l.Bounds.X >= 32 {
+			want = blue
+		}
+		if l.Color != want || l.Error != 0 || l.Depth != 1 {
+			t.Errorf("leaf %+v, want color %v at depth 1 without error", l, want)
+		}
+	}
+	checkArtLeafs(t, qt, leafs, 64, 64)
+}
+
+func TestBuildImageQuadTreeLimits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	img := fillImage(100, 80, func(x, y int) color.RGBA {
+		return color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+	})
+	tests := []struct {
+		name string
+		opts QuadTreeArtOptions
+	}{
+		{"max leafs", QuadTreeArtOptions{MaxLeafs: 40}},
+		{"min size", QuadTreeArtOptions{MinSize: 10}},
+		{"max depth", QuadTreeArtOptions{MaxDepth: 2}},
+		{"max error", QuadTreeArtOptions{MaxError: 1000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt, leafs := BuildImageQuadTree(img, tt.opts)
+			checkArtLeafs(t, qt, leafs, 100, 80)
+			if tt.opts.MaxLeafs > 0 && (len(leafs) > tt.opts.MaxLeafs || len(leafs) < tt.opts.MaxLeafs-3) {
+				t.Errorf("got %d leafs, want at most %d", len(leafs), tt.opts.MaxLeafs)
+			}
+			for _, l := range leafs {
+				if l.Bounds.W < tt.opts.MinSize || l.Bounds.H < tt.opts.MinSize {
+					t.Errorf("leaf %+v is smaller than %v", l.Bounds, tt.opts.MinSize)
+				}
+				if tt.opts.MaxDepth > 0 && l.Depth > tt.opts.MaxDepth {
+					t.Errorf("leaf %+v is deeper than %d", l.Bounds, tt.opts.MaxDepth)
+				}
+			}
+			if tt.opts.MaxError >= 1000 && len(leafs) != 1 {
+				t.Errorf("got %d leafs, want the root only", len(leafs))
+			}
+		})
+	}
+}
+
+// testStepTexture is a TextureCachable that is 0 left of x = 32 and 1 from there on
+type testStepTexture struct{}
+
+func (testStepTexture) GetParamSignature() []byte { return []byte("step") }
+
+func (testStepTexture) GetEvalRange() (float64, float64) { return 0, 1 }
+
+func (testStepTexture) Eval2(x float64, y float64) float64 {
+	if x < 32 {
+		return 0
+	}
+	return 1
+}
+
+func TestBuildTextureQuadTree(t *testing.T) {
+	// the sampled area starts at x = 16, so the step lies at the quarter of the tree
+	qt, leafs := BuildTextureQuadTree(testStepTexture{}, 16, 100, 64, 64, QuadTreeArtOptions{})
+	checkArtLeafs(t, qt, leafs, 64, 64)
+	if len(leafs) != 10 {
+		t.Errorf("got %d leafs, want 10", len(leafs))
+	}
+	for _, l := range leafs {
+		want := color.RGBA{255, 255, 255, 255}
+		if l.Bounds.X+l.Bounds.W <= 16 {
+			want = color.RGBA{0, 0, 0, 255}
+		}
+		if l.Color != want || math.Abs(l.Error) > 1e-9 {
+			t.Errorf("leaf %+v, want color %v without error", l, want)
+		}
+	}
+}
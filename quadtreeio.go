@@ -0,0 +1,152 @@
+package gah
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// quadTreeMagic identifies serialized QuadTree data
+var quadTreeMagic = [4]byte{'G', 'A', 'Q', 'T'}
+
+// quadTreeFormatVersion is incremented on every incompatible change of the serialization format
+const quadTreeFormatVersion = 1
+
+const (
+	quadTreeNodeLeaf     uint8 = 0
+	quadTreeNodeInternal uint8 = 1
+)
+
+// ErrInvalidQuadTreeData is returned by ReadQuadTree if the input is not a serialized QuadTree
+var ErrInvalidQuadTreeData = errors.New("invalid quadtree data")
+
+// quadTreeHeader is the fixed size header of serialized QuadTree data
+type quadTreeHeader struct {
+	Magic        [4]byte
+	Version      uint32
+	X, Y, W, H   float64
+	LeafCapacity int64
+	MaxDepth     int64
+}
+
+// quadTreeItemRecord is the serialized form of a QuadTreeItem, the Data payload is not stored
+type quadTreeItemRecord struct {
+	X, Y float64
+	ID   int64
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// WriteTo serializes the tree including its configuration and exact structure to w, all values are stored big endian
+// only position and ID of the items are written, their Data payloads are skipped
+func (qt *QuadTree) WriteTo(w io.Writer) (n int64, err error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	header := quadTreeHeader{quadTreeMagic, quadTreeFormatVersion, qt.x, qt.y, qt.w, qt.h, int64(qt.cfg.leafCapacity), int64(qt.cfg.maxDepth)}
+	if err = binary.Write(bw, binary.BigEndian, header); err != nil {
+		return cw.n, err
+	}
+	// nodes are written depth first, parents before their children in quadrant order
+	stack := []*QuadTree{qt}
+	for len(stack) > 0 && err == nil {
+		cqt := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !cqt.isLeaf() {
+			err = bw.WriteByte(quadTreeNodeInternal)
+			for i := len(cqt.subTrees) - 1; i >= 0; i-- {
+				stack = append(stack, cqt.subTrees[i])
+			}
+			continue
+		}
+		if err = bw.WriteByte(quadTreeNodeLeaf); err != nil {
+			break
+		}
+		if err = binary.Write(bw, binary.BigEndian, uint32(cqt.leafPointCount)); err != nil {
+			break
+		}
+		for _, li := range cqt.leafItems[:cqt.leafPointCount] {
+			if err = binary.Write(bw, binary.BigEndian, quadTreeItemRecord{li.Pos.X, li.Pos.Y, int64(li.ID)}); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return cw.n, err
+	}
+	err = bw.Flush()
+	return cw.n, err
+}
+
+// ReadQuadTree deserializes a tree written by QuadTree.WriteTo, all items are restored with nil Data
+func ReadQuadTree(r io.Reader) (*QuadTree, error) {
+	br := bufio.NewReader(r)
+	var header quadTreeHeader
+	if err := binary.Read(br, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != quadTreeMagic || header.Version != quadTreeFormatVersion {
+		return nil, ErrInvalidQuadTreeData
+	}
+	qt := NewQuadTree(header.X, header.Y, header.W, header.H, QuadTreeLeafCapacity(int(header.LeafCapacity)), QuadTreeMaxDepth(int(header.MaxDepth)))
+	if err := qt.readNode(br); err != nil {
+		return nil, err
+	}
+	return qt, nil
+}
+
+// readNode fills the empty node qt and all its subtrees from br
+func (qt *QuadTree) readNode(br *bufio.Reader) error {
+	kind, err := br.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case quadTreeNodeInternal:
+		if qt.depth >= qt.cfg.maxDepth {
+			return ErrInvalidQuadTreeData
+		}
+		qt.split()
+		for _, st := range qt.subTrees {
+			if err := st.readNode(br); err != nil {
+				return err
+			}
+			qt.leafPointCount += st.leafPointCount
+		}
+		return nil
+	case quadTreeNodeLeaf:
+		var count uint32
+		if err := binary.Read(br, binary.BigEndian, &count); err != nil {
+			return err
+		}
+		prealloc := count
+		if prealloc > 1024 {
+			prealloc = 1024 // dont trust the count for large allocations up front
+		}
+		qt.leafItems = make([]QuadTreeItem, 0, prealloc)
+		for i := uint32(0); i < count; i++ {
+			var rec quadTreeItemRecord
+			if err := binary.Read(br, binary.BigEndian, &rec); err != nil {
+				return err
+			}
+			item := QuadTreeItem{Pos: Vec2f{rec.X, rec.Y}, ID: int(rec.ID)}
+			if ok, _ := qt.Contains(item.Pos); !ok {
+				return ErrInvalidQuadTreeData
+			}
+			qt.leafItems = append(qt.leafItems, item)
+		}
+		qt.leafPointCount = len(qt.leafItems)
+		return nil
+	}
+	return ErrInvalidQuadTreeData
+}
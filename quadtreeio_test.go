@@ -0,0 +1,128 @@
+package gah
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+// quadTreeNodes returns the info of all nodes of the tree in the order of Walk
+func quadTreeNodes(qt *QuadTree) []QuadTreeNodeInfo {
+	nodes := []QuadTreeNodeInfo{}
+	qt.Walk(func(node QuadTreeNodeInfo) bool {
+		nodes = append(nodes, node)
+		return true
+	})
+	return nodes
+}
+
+func TestQuadTreeWriteReadRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	trees := []struct {
+		name string
+		qt   *QuadTree
+	}{
+		{"empty", NewQuadTree(-5, 10, 100, 50)},
+		{"defaults", NewQuadTree(0, 0, 1000, 1000)},
+		{"capacity 1 depth 4", NewQuadTree(0, 0, 1000, 1000, QuadTreeLeafCapacity(1), QuadTreeMaxDepth(4))},
+	}
+	for i, tt := range trees[1:] {
+		for j, p := range randomPoints(rng, 200, 1000, 1000) {
+			tt.qt.InsertItem(QuadTreeItem{p, i*1000 + j, "dropped"})
+		}
+		tt.qt.InsertPoints([]Vec2f{{1, 1}, {1, 1}, {1, 1}}) // duplicates below the maximum depth
+	}
+	for _, tt := range trees {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := tt.qt.WriteTo(&buf)
+			if err != nil || n != int64(buf.Len()) {
+				t.Fatalf("WriteTo() = %d, %v, wrote %d bytes", n, err, buf.Len())
+			}
+			read, err := ReadQuadTree(&buf)
+			if err != nil {
+				t.Fatalf("ReadQuadTree() error = %v", err)
+			}
+			if *read.cfg != *tt.qt.cfg {
+				t.Errorf("read config %+v, want %+v", *read.cfg, *tt.qt.cfg)
+			}
+			got, want := quadTreeNodes(read), quadTreeNodes(tt.qt)
+			if len(got) != len(want) {
+				t.Fatalf("read tree has %d nodes, want %d", len(got), len(want))
+			}
+			for i := range want {
+				g, w := got[i], want[i]
+				if g.Bounds != w.Bounds || g.Depth != w.Depth || g.Leaf != w.Leaf || g.Count != w.Count || len(g.Items) != len(w.Items) {
+					t.Fatalf("read node %d = %+v, want %+v", i, g, w)
+				}
+				for j, item := range w.Items {
+					item.Data = nil // the payloads are not serialized
+					if g.Items[j] != item {
+						t.Fatalf("read node %d item %d = %v, want %v", i, j, g.Items[j], item)
+					}
+				}
+			}
+			checkQuadTreeStructure(t, read)
+		})
+	}
+}
+
+func TestReadQuadTreeCorrupt(t *testing.T) {
+	qt := NewQuadTree(0, 0, 100, 100, QuadTreeLeafCapacity(1), QuadTreeMaxDepth(2))
+	qt.InsertItems([]QuadTreeItem{{Vec2f{10, 10}, 1, nil}, {Vec2f{90, 90}, 2, nil}})
+	var buf bytes.Buffer
+	if _, err := qt.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	valid := buf.Bytes()
+	headerSize := binary.Size(quadTreeHeader{})
+	header := func(version uint32, maxDepth int64) []byte {
+		var hb bytes.Buffer
+		binary.Write(&hb, binary.BigEndian, quadTreeHeader{quadTreeMagic, version, 0, 0, 100, 100, 1, maxDepth})
+		return hb.Bytes()
+	}
+	leaf := func(x float64, y float64) []byte {
+		var lb bytes.Buffer
+		lb.WriteByte(quadTreeNodeLeaf)
+		binary.Write(&lb, binary.BigEndian, uint32(1))
+		binary.Write(&lb, binary.BigEndian, quadTreeItemRecord{x, y, 7})
+		return lb.Bytes()
+	}
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	tests := []struct {
+		name        string
+		data        []byte
+		invalidData bool // expect ErrInvalidQuadTreeData instead of a read error
+	}{
+		{"empty", nil, false},
+		{"bad magic", concat([]byte("GAQX"), valid[4:]), true},
+		{"bad version", concat(header(quadTreeFormatVersion+1, 2), valid[headerSize:]), true},
+		{"unknown node kind", concat(header(quadTreeFormatVersion, 2), []byte{7}), true},
+		{"item outside of its leaf", concat(header(quadTreeFormatVersion, 2), []byte{quadTreeNodeInternal}, leaf(10, 10), leaf(10, 10), leaf(10, 90), leaf(90, 90)), true},
+		{"internal node below the maximum depth", concat(header(quadTreeFormatVersion, 0), []byte{quadTreeNodeInternal}), true},
+		{"huge leaf count", concat(header(quadTreeFormatVersion, 2), []byte{quadTreeNodeLeaf, 0xFF, 0xFF, 0xFF, 0xFF}), false},
+	}
+	for cut := 1; cut < len(valid); cut++ {
+		tests = append(tests, struct {
+			name        string
+			data        []byte
+			invalidData bool
+		}{"truncated", valid[:cut], false})
+	}
+	for _, tt := range tests {
+		read, err := ReadQuadTree(bytes.NewReader(tt.data))
+		if err == nil || read != nil {
+			t.Fatalf("%s (%d bytes): ReadQuadTree() = %v, %v, want an error", tt.name, len(tt.data), read, err)
+		}
+		if tt.invalidData && err != ErrInvalidQuadTreeData {
+			t.Errorf("%s: ReadQuadTree() error = %v, want %v", tt.name, err, ErrInvalidQuadTreeData)
+		}
+	}
+	// the valid data itself reads fine
+	if _, err := ReadQuadTree(bytes.NewReader(valid)); err != nil {
+		t.Errorf("ReadQuadTree() of the valid data error = %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package gah
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+
+	"github.com/fogleman/gg"
+)
+
+// QuadTreeStyle configures how a QuadTree is rendered by DrawQuadTreeStyled and WriteQuadTreeSVG
+type QuadTreeStyle struct {
+	LineColors  []color.Color // outline color per depth, the last entry is reused for deeper nodes, no outlines if empty
+	LineWidths  []float64     // outline width per depth, the last entry is reused for deeper nodes, no outlines if empty
+	PointRadius float64       // no points are drawn if <= 0
+	PointColor  color.Color
+	Labels      bool // label every drawn leaf with its point count
+	LabelColor  color.Color
+	MaxDepth    int // nodes below this depth are not drawn, their points are drawn as part of the deepest drawn node; negative for no limit
+}
+
+// DefaultQuadTreeStyle returns the classic debug look: thin black outlines, small red points and point count labels
+func DefaultQuadTreeStyle() QuadTreeStyle {
+	return QuadTreeStyle{
+		LineColors:  []color.Color{color.Black},
+		LineWidths:  []float64{1},
+		PointRadius: 2,
+		PointColor:  color.RGBA{0xFF, 0, 0, 0xFF},
+		Labels:      true,
+		LabelColor:  color.Black,
+		MaxDepth:    -1,
+	}
+}
+
+// lineStyle returns the outline color and width for the given depth, ok is false if no outline should be drawn
+func (style *QuadTreeStyle) lineStyle(depth int) (c color.Color, width float64, ok bool) {
+	if len(style.LineColors) == 0 || len(style.LineWidths) == 0 {
+		return nil, 0, false
+	}
+	c = style.LineColors[len(style.LineColors)-1]
+	if depth < len(style.LineColors) {
+		c = style.LineColors[depth]
+	}
+	width = style.LineWidths[len(style.LineWidths)-1]
+	if depth < len(style.LineWidths) {
+		width = style.LineWidths[depth]
+	}
+	return c, width, width > 0 && c != nil
+}
+
+// quadTreeRenderNode is a node as it should be rendered
+type quadTreeRenderNode struct {
+	bounds    Rect2f
+	depth     int
+	count     int
+	drawnLeaf bool    // true if no children of this node are drawn
+	points    []Vec2f // points to draw in this node including those of all undrawn subtrees, only set on drawn leafs
+}
+
+// renderNodes walks the tree depth first and calls draw for every node to draw according to the style
+func (style *QuadTreeStyle) renderNodes(tree *QuadTree, draw func(node quadTreeRenderNode)) {
+	stack := []*QuadTree{tree}
+	for len(stack) > 0 {
+		cqt := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node := quadTreeRenderNode{cqt.Bounds(), cqt.depth, cqt.leafPointCount, false, nil}
+		if cqt.isLeaf() || (style.MaxDepth >= 0 && cqt.depth >= style.MaxDepth) {
+			node.drawnLeaf = true
+			node.points = cqt.GetPoints()
+			draw(node)
+			continue
+		}
+		draw(node)
+		for i := len(cqt.subTrees) - 1; i >= 0; i-- {
+			stack = append(stack, cqt.subTrees[i])
+		}
+	}
+}
+
+// DrawQuadTree draws the given QuadTree using the DefaultQuadTreeStyle
+func DrawQuadTree(dc *gg.Context, tree *QuadTree) {
+	DrawQuadTreeStyled(dc, tree, DefaultQuadTreeStyle())
+}
+
+// DrawQuadTreeStyled draws the outlines, points and labels of the given QuadTree onto dc
+// outlines of all nodes are drawn first, so that points and labels are always on top
+func DrawQuadTreeStyled(dc *gg.Context, tree *QuadTree, style QuadTreeStyle) {
+	var leafs []quadTreeRenderNode
+	style.renderNodes(tree, func(node quadTreeRenderNode) {
+		if c, width, ok := style.lineStyle(node.depth); ok {
+			dc.SetColor(c)
+			dc.SetLineWidth(width)
+			dc.DrawRectangle(node.bounds.X, node.bounds.Y, node.bounds.W, node.bounds.H)
+			dc.Stroke()
+		}
+		if node.drawnLeaf {
+			leafs = append(leafs, node)
+		}
+	})
+	if style.PointRadius > 0 && style.PointColor != nil {
+		dc.SetColor(style.PointColor)
+		for _, leaf := range leafs {
+			for _, p := range leaf.points {
+				dc.DrawCircle(p.X, p.Y, style.PointRadius)
+			}
+		}
+		dc.Fill()
+	}
+	if style.Labels && style.LabelColor != nil {
+		dc.SetColor(style.LabelColor)
+		for _, leaf := range leafs {
+			dc.DrawStringAnchored(fmt.Sprintf("%d", leaf.count), leaf.bounds.X+leaf.bounds.W/2, leaf.bounds.Y+leaf.bounds.H/2, 0.5, 0.5)
+		}
+	}
+}
+
+// svgColor returns the svg color string and opacity of c
+func svgColor(c color.Color) (string, float64) {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return "none", 0
+	}
+	// undo alpha premultiplication
+	return fmt.Sprintf("rgb(%d,%d,%d)", r*0xFF/a, g*0xFF/a, b*0xFF/a), float64(a) / 0xFFFF
+}
+
+// WriteQuadTreeSVG writes the given QuadTree as a standalone SVG document to w, using the bounds of the tree as the viewbox
+func WriteQuadTreeSVG(w io.Writer, tree *QuadTree, style QuadTreeStyle) error {
+	bw := bufio.NewWriter(w)
+	b := tree.Bounds()
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"%g %g %g %g\" width=\"%g\" height=\"%g\">\n", b.X, b.Y, b.W, b.H, b.W, b.H)
+	var leafs []quadTreeRenderNode
+	fmt.Fprintf(bw, "<g fill=\"none\">\n")
+	style.renderNodes(tree, func(node quadTreeRenderNode) {
+		if c, width, ok := style.lineStyle(node.depth); ok {
+			sc, so := svgColor(c)
+			fmt.Fprintf(bw, "<rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\" stroke=\"%s\" stroke-opacity=\"%g\" stroke-width=\"%g\"/>\n",
+				node.bounds.X, node.bounds.Y, node.bounds.W, node.bounds.H, sc, so, width)
+		}
+		if node.drawnLeaf {
+			leafs = append(leafs, node)
+		}
+	})
+	fmt.Fprintf(bw, "</g>\n")
+	if style.PointRadius > 0 && style.PointColor != nil {
+		sc, so := svgColor(style.PointColor)
+		fmt.Fprintf(bw, "<g fill=\"%s\" fill-opacity=\"%g\">\n", sc, so)
+		for _, leaf := range leafs {
+			for _, p := range leaf.points {
+				fmt.Fprintf(bw, "<circle cx=\"%g\" cy=\"%g\" r=\"%g\"/>\n", p.X, p.Y, style.PointRadius)
+			}
+		}
+		fmt.Fprintf(bw, "</g>\n")
+	}
+	if style.Labels && style.LabelColor != nil {
+		sc, so := svgColor(style.LabelColor)
+		fmt.Fprintf(bw, "<g fill=\"%s\" fill-opacity=\"%g\" text-anchor=\"middle\" dominant-baseline=\"middle\">\n", sc, so)
+		for _, leaf := range leafs {
+			fmt.Fprintf(bw, "<text x=\"%g\" y=\"%g\">%d</text>\n", leaf.bounds.X+leaf.bounds.W/2, leaf.bounds.Y+leaf.bounds.H/2, leaf.count)
+		}
+		fmt.Fprintf(bw, "</g>\n")
+	}
+	fmt.Fprintf(bw, "</svg>\n")
+	return bw.Flush()
+}
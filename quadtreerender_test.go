@@ -0,0 +1,122 @@
+package gah
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+// quadTreeSVG is the part of the document written by WriteQuadTreeSVG that the tests look at
+type quadTreeSVG struct {
+	ViewBox string `xml:"viewBox,attr"`
+	Groups  []struct {
+		Fill  string `xml:"fill,attr"`
+		Rects []struct {
+			Stroke      string  `xml:"stroke,attr"`
+			StrokeWidth float64 `xml:"stroke-width,attr"`
+		} `xml:"rect"`
+		Circles []struct {
+			CX float64 `xml:"cx,attr"`
+			CY float64 `xml:"cy,attr"`
+		} `xml:"circle"`
+		Texts []int `xml:"text"`
+	} `xml:"g"`
+}
+
+// renderTestTree returns a tree over [10, 90] x [10, 90] that splits once at the root and once more in its top left quadrant
+func renderTestTree() *QuadTree {
+	qt := NewQuadTree(10, 10, 80, 80, QuadTreeLeafCapacity(2))
+	qt.InsertPoints([]Vec2f{{15, 15}, {45, 15}, {15, 45}, {70, 70}})
+	return qt
+}
+
+func TestWriteQuadTreeSVG(t *testing.T) {
+	style := DefaultQuadTreeStyle()
+	style.LineColors = []color.Color{color.Black, color.RGBA{0, 0, 0xFF, 0xFF}}
+	style.LineWidths = []float64{3, 1}
+	tests := []struct {
+		name     string
+		maxDepth int
+		lines    bool
+		rects    int
+		labels   []int
+	}{
+		{"all depths", -1, true, 9, []int{0, 1, 1, 1, 0, 0, 1}},
+		{"max depth 1", 1, true, 5, []int{0, 3, 0, 1}},
+		{"max depth 0", 0, true, 1, []int{4}},
+		{"no lines", -1, false, 0, []int{0, 1, 1, 1, 0, 0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			style := style
+			style.MaxDepth = tt.maxDepth
+			if !tt.lines {
+				style.LineWidths = nil
+			}
+			var buf bytes.Buffer
+			if err := WriteQuadTreeSVG(&buf, renderTestTree(), style); err != nil {
+				t.Fatalf("WriteQuadTreeSVG() error = %v", err)
+			}
+			var doc quadTreeSVG
+			if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatalf("written svg does not parse: %v\n%s", err, buf.String())
+			}
+			if doc.ViewBox != "10 10 80 80" {
+				t.Errorf("viewBox = %q, want the tree bounds", doc.ViewBox)
+			}
+			if len(doc.Groups) != 3 {
+				t.Fatalf("svg has %d groups, want outlines, points and labels", len(doc.Groups))
+			}
+			rects := doc.Groups[0].Rects
+			if len(rects) != tt.rects {
+				t.Fatalf("svg has %d outlines, want %d", len(rects), tt.rects)
+			}
+			if len(rects) > 1 && (rects[0].Stroke != "rgb(0,0,0)" || rects[0].StrokeWidth != 3 || rects[1].Stroke != "rgb(0,0,255)" || rects[1].StrokeWidth != 1) {
+				t.Errorf("outline styles by depth = %+v", rects[:2])
+			}
+			if fill := doc.Groups[1].Fill; fill != "rgb(255,0,0)" || len(doc.Groups[1].Circles) != 4 {
+				t.Errorf("svg has %d %s points, want 4 red ones", len(doc.Groups[1].Circles), fill)
+			}
+			if labels := doc.Groups[2].Texts; len(labels) != len(tt.labels) {
+				t.Errorf("labels = %v, want %v", labels, tt.labels)
+			} else {
+				for i := range labels {
+					if labels[i] != tt.labels[i] {
+						t.Errorf("labels = %v, want %v", labels, tt.labels)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDrawQuadTreeStyled(t *testing.T) {
+	style := DefaultQuadTreeStyle()
+	style.LineWidths = []float64{2}
+	style.Labels = false
+	dc := gg.NewContext(100, 100)
+	dc.SetRGB(1, 1, 1)
+	dc.Clear()
+	DrawQuadTreeStyled(dc, renderTestTree(), style)
+	img := dc.Image()
+	tests := []struct {
+		name string
+		x, y int
+		want color.RGBA
+	}{
+		{"root outline", 10, 80, color.RGBA{0, 0, 0, 0xFF}},
+		{"split line", 50, 80, color.RGBA{0, 0, 0, 0xFF}},
+		{"point", 70, 70, color.RGBA{0xFF, 0, 0, 0xFF}},
+		{"empty space", 80, 30, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}},
+		{"outside of the tree", 5, 5, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}},
+	}
+	for _, tt := range tests {
+		if got := color.RGBAModel.Convert(img.At(tt.x, tt.y)); got != tt.want {
+			t.Errorf("%s: pixel (%d, %d) = %v, want %v", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
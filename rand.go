@@ -10,3 +10,27 @@ func Hash64(s string) uint64 {
 	h.Write([]byte(s))
 	return uint64(h.Sum64())
 }
+
+// mix64 is the splitmix64 finalizer, every input bit affects every output bit
+func mix64(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+// HashInts returns a uint64 hash of the seed and all values, e.g. to derive deterministic randomness for lattice coordinates
+func HashInts(seed uint64, values ...int) uint64 {
+	h := mix64(seed)
+	for _, v := range values {
+		h = mix64(h ^ (uint64(v) + 0x9e3779b97f4a7c15))
+	}
+	return h
+}
+
+// HashToUnit returns the upper 53 bits of the hash as a float in [0, 1)
+func HashToUnit(h uint64) float64 {
+	return float64(h>>11) / (1 << 53)
+}
@@ -0,0 +1,206 @@
+package gah
+
+// ShapeQuadTreeMaxDepth limits how often a ShapeQuadTree may be subdivided
+const ShapeQuadTreeMaxDepth = 16
+
+// ShapeQuadTreeItem is a shape stored in a ShapeQuadTree together with arbitrary user data
+type ShapeQuadTreeItem struct {
+	Shape Shape2f
+	ID    int         // user defined identifier, tells apart items with the same bounds on removal
+	Data  interface{} // user payload, returned as is by all queries
+}
+
+// ShapeQuadTree is a region quadtree containing shapes, e.g. for collision free packing of circles and rectangles
+// every shape is kept in the smallest node that fully contains its bounds, so shapes crossing a split line stay in internal nodes
+type ShapeQuadTree struct {
+	quadBounds
+	depth     int
+	subTrees  [4]*ShapeQuadTree
+	items     []ShapeQuadTreeItem // shapes stored directly in this node
+	itemCount int                 // counts the items of this node, accumulated with those of all subtrees
+}
+
+// NewShapeQuadTree creates a new ShapeQuadTree with the given dimensions
+func NewShapeQuadTree(x float64, y float64, w float64, h float64) *ShapeQuadTree {
+	return &ShapeQuadTree{quadBounds{x, y, w, h}, 0, [4]*ShapeQuadTree{}, nil, 0}
+}
+
+func (sqt *ShapeQuadTree) isLeaf() bool {
+	return sqt.subTrees[0] == nil
+}
+
+// Len returns the number of shapes stored in the tree
+func (sqt *ShapeQuadTree) Len() int {
+	return sqt.itemCount
+}
+
+// childContaining returns the subtree that fully contains the given bounds, or nil if there is none
+func (sqt *ShapeQuadTree) childContaining(b Rect2f) *ShapeQuadTree {
+	for _, st := range sqt.subTrees {
+		if st != nil && (Rect2f{st.x, st.y, st.w, st.h}).ContainsRect(b) {
+			return st
+		}
+	}
+	return nil
+}
+
+// Insert inserts the given item into the tree, branching where necessary
+// returns false if the bounds of the shape are not fully inside of the tree
+func (sqt *ShapeQuadTree) Insert(item ShapeQuadTreeItem) bool {
+	b := item.Shape.Bounds()
+	if !(Rect2f{sqt.x, sqt.y, sqt.w, sqt.h}).ContainsRect(b) {
+		return false
+	}
+	cqt := sqt
+	for {
+		cqt.itemCount++
+		if cqt.isLeaf() {
+			cqt.items = append(cqt.items, item)
+			cqt.splitIfFull()
+			return true
+		}
+		child := cqt.childContaining(b)
+		if child == nil {
+			// shape crosses a split line, keep it here
+			cqt.items = append(cqt.items, item)
+			return true
+		}
+		cqt = child
+	}
+}
+
+// InsertShape inserts the given shape into the tree with a zero ID and nil Data
+func (sqt *ShapeQuadTree) InsertShape(s Shape2f) bool {
+	return sqt.Insert(ShapeQuadTreeItem{Shape: s})
+}
+
+// splitIfFull turns a leaf holding too many items into an internal node, pushing down every item that fits into a subtree
+func (sqt *ShapeQuadTree) splitIfFull() {
+	if !sqt.isLeaf() || len(sqt.items) <= QuadTreeLeafThreshold || sqt.depth >= ShapeQuadTreeMaxDepth {
+		return
+	}
+	for i := range sqt.subTrees {
+		qb := sqt.quadrantBounds(quadTreeQuadrant(i))
+		sqt.subTrees[i] = &ShapeQuadTree{qb, sqt.depth + 1, [4]*ShapeQuadTree{}, nil, 0}
+	}
+	kept := sqt.items[:0]
+	for _, item := range sqt.items {
+		child := sqt.childContaining(item.Shape.Bounds())
+		if child == nil {
+			kept = append(kept, item)
+			continue
+		}
+		child.items = append(child.items, item)
+		child.itemCount++
+	}
+	for i := len(kept); i < len(sqt.items); i++ {
+		sqt.items[i] = ShapeQuadTreeItem{} // release references held by the now unused tail
+	}
+	sqt.items = kept
+	for _, st := range sqt.subTrees {
+		st.splitIfFull()
+	}
+}
+
+// Remove removes the item with the same shape bounds and ID as the given one, returns false if it was not found
+func (sqt *ShapeQuadTree) Remove(item ShapeQuadTreeItem) bool {
+	b := item.Shape.Bounds()
+	if !(Rect2f{sqt.x, sqt.y, sqt.w, sqt.h}).ContainsRect(b) {
+		return false
+	}
+	// descend to the node that would hold the item, remembering the path to update the accumulated counts
+	path := []*ShapeQuadTree{}
+	cqt := sqt
+	for cqt != nil {
+		path = append(path, cqt)
+		for i, li := range cqt.items {
+			if li.ID != item.ID || li.Shape.Bounds() != b {
+				continue
+			}
+			cqt.items[i] = cqt.items[len(cqt.items)-1]
+			cqt.items[len(cqt.items)-1] = ShapeQuadTreeItem{}
+			cqt.items = cqt.items[:len(cqt.items)-1]
+			for _, pqt := range path {
+				pqt.itemCount--
+			}
+			// merge the topmost subtree that dropped below the threshold back into a single leaf
+			for _, pqt := range path {
+				if !pqt.isLeaf() && pqt.itemCount < QuadTreeLeafThreshold {
+					pqt.collapse()
+					break
+				}
+			}
+			return true
+		}
+		cqt = cqt.childContaining(b)
+	}
+	return false
+}
+
+// collapse turns an internal node back into a leaf holding all items of its subtrees
+func (sqt *ShapeQuadTree) collapse() {
+	var collect func(node *ShapeQuadTree)
+	collect = func(node *ShapeQuadTree) {
+		for _, st := range node.subTrees {
+			if st == nil {
+				continue
+			}
+			sqt.items = append(sqt.items, st.items...)
+			collect(st)
+		}
+	}
+	collect(sqt)
+	sqt.subTrees = [4]*ShapeQuadTree{}
+}
+
+// QueryIntersecting returns all items whose shape overlaps or touches the given shape
+func (sqt *ShapeQuadTree) QueryIntersecting(s Shape2f) (results []ShapeQuadTreeItem) {
+	results = []ShapeQuadTreeItem{}
+	sqt.visitIntersecting(s, func(item ShapeQuadTreeItem) bool {
+		results = append(results, item)
+		return true
+	})
+	return
+}
+
+// AnyIntersecting returns true if any shape in the tree overlaps or touches the given shape
+// stops at the first hit, so this is the cheapest test for collision free placement
+func (sqt *ShapeQuadTree) AnyIntersecting(s Shape2f) bool {
+	found := false
+	sqt.visitIntersecting(s, func(item ShapeQuadTreeItem) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// QueryPoint returns all items whose shape contains the point p
+func (sqt *ShapeQuadTree) QueryPoint(p Vec2f) (results []ShapeQuadTreeItem) {
+	results = []ShapeQuadTreeItem{}
+	sqt.visitIntersecting(Rect2f{p.X, p.Y, 0, 0}, func(item ShapeQuadTreeItem) bool {
+		if item.Shape.ContainsPoint(p) {
+			results = append(results, item)
+		}
+		return true
+	})
+	return
+}
+
+// visitIntersecting calls visit for every item intersecting s, until visit returns false
+// returns false if the visit was stopped early
+func (sqt *ShapeQuadTree) visitIntersecting(s Shape2f, visit func(item ShapeQuadTreeItem) bool) bool {
+	if sqt.itemCount == 0 || !s.IntersectsRect(sqt.x, sqt.y, sqt.w, sqt.h) {
+		return true
+	}
+	for _, item := range sqt.items {
+		if ShapesIntersect(item.Shape, s) && !visit(item) {
+			return false
+		}
+	}
+	for _, st := range sqt.subTrees {
+		if st != nil && !st.visitIntersecting(s, visit) {
+			return false
+		}
+	}
+	return true
+}
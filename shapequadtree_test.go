@@ -0,0 +1,119 @@
+package gah
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// randomShape returns a random circle, triangle or rectangle, partly reaching outside of [0, 1000] x [0, 1000]
+func randomShape(rng *rand.Rand) Shape2f {
+	switch rng.Intn(3) {
+	case 0:
+		return Circle2f{Vec2f{rng.Float64() * 1000, rng.Float64() * 1000}, rng.Float64()*40 + 1}
+	case 1:
+		p := Vec2f{rng.Float64()*1000 - 20, rng.Float64()*1000 - 20}
+		return Polygon2f{[]Vec2f{p, {p.X + rng.Float64()*80, p.Y + rng.Float64()*20}, {p.X + rng.Float64()*20, p.Y + rng.Float64()*80}}}
+	}
+	return Rect2f{rng.Float64()*1000 - 20, rng.Float64()*1000 - 20, rng.Float64() * 80, rng.Float64() * 80}
+}
+
+// checkShapeQueries compares the queries of the tree against brute force tests of all stored items
+func checkShapeQueries(t *testing.T, sqt *ShapeQuadTree, items []ShapeQuadTreeItem, rng *rand.Rand) {
+	t.Helper()
+	if sqt.Len() != len(items) {
+		t.Fatalf("Len() = %d, want %d", sqt.Len(), len(items))
+	}
+	ids := func(items []ShapeQuadTreeItem) map[int]bool {
+		set := map[int]bool{}
+		for _, item := range items {
+			if set[item.ID] {
+				t.Fatalf("item %d returned twice", item.ID)
+			}
+			set[item.ID] = true
+		}
+		return set
+	}
+	for i := 0; i < 100; i++ {
+		s := randomShape(rng)
+		p := Vec2f{rng.Float64() * 1000, rng.Float64() * 1000}
+		intersecting, containing := []ShapeQuadTreeItem{}, []ShapeQuadTreeItem{}
+		for _, item := range items {
+			if ShapesIntersect(item.Shape, s) {
+				intersecting = append(intersecting, item)
+			}
+			if item.Shape.ContainsPoint(p) {
+				containing = append(containing, item)
+			}
+		}
+		got, want := ids(sqt.QueryIntersecting(s)), ids(intersecting)
+		if len(got) != len(want) {
+			t.Fatalf("QueryIntersecting(%v) found %d items, want %d", s, len(got), len(want))
+		}
+		for id := range want {
+			if !got[id] {
+				t.Fatalf("QueryIntersecting(%v) misses item %d", s, id)
+			}
+		}
+		if any := sqt.AnyIntersecting(s); any != (len(want) > 0) {
+			t.Fatalf("AnyIntersecting(%v) = %v with %d intersecting items", s, any, len(want))
+		}
+		got, want = ids(sqt.QueryPoint(p)), ids(containing)
+		if len(got) != len(want) {
+			t.Fatalf("QueryPoint(%v) found %d items, want %d", p, len(got), len(want))
+		}
+		for id := range want {
+			if !got[id] {
+				t.Fatalf("QueryPoint(%v) misses item %d", p, id)
+			}
+		}
+	}
+}
+
+func TestShapeQuadTreeBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sqt := NewShapeQuadTree(0, 0, 1000, 1000)
+	items := []ShapeQuadTreeItem{}
+	for i := 0; i < 600; i++ {
+		item := ShapeQuadTreeItem{randomShape(rng), i, nil}
+		b := item.Shape.Bounds()
+		inside := b.X >= 0 && b.Y >= 0 && b.X+b.W <= 1000 && b.Y+b.H <= 1000
+		if got := sqt.Insert(item); got != inside {
+			t.Fatalf("Insert(%v) = %v, want %v", item.Shape, got, inside)
+		}
+		if inside {
+			items = append(items, item)
+		}
+	}
+	// tiny shapes on the split lines have to stay in the internal nodes
+	for i := 0; i < 20; i++ {
+		item := ShapeQuadTreeItem{Circle2f{Vec2f{500, float64(i)*50 + 25}, 0.5}, 1000 + i, nil}
+		if !sqt.Insert(item) {
+			t.Fatalf("Insert(%v) = false", item.Shape)
+		}
+		items = append(items, item)
+	}
+	checkShapeQueries(t, sqt, items, rng)
+	// remove every other item, the remaining ones must still be found after the collapses
+	remaining := []ShapeQuadTreeItem{}
+	for i, item := range items {
+		if i%2 == 1 {
+			remaining = append(remaining, item)
+			continue
+		}
+		if sqt.Remove(ShapeQuadTreeItem{item.Shape, -1, nil}) {
+			t.Fatalf("Remove with an unknown ID = true")
+		}
+		if !sqt.Remove(item) {
+			t.Fatalf("Remove(%v) = false", item)
+		}
+	}
+	checkShapeQueries(t, sqt, remaining, rng)
+	for _, item := range remaining {
+		if !sqt.Remove(item) {
+			t.Fatalf("Remove(%v) = false", item)
+		}
+	}
+	if sqt.Len() != 0 || !sqt.isLeaf() {
+		t.Errorf("tree is not a single empty leaf after removing every item")
+	}
+}
@@ -0,0 +1,227 @@
+package gah
+
+import "math"
+
+// Shape2f is a 2D shape that can be tested against axis aligned regions, e.g. the nodes of a quadtree
+type Shape2f interface {
+	Bounds() Rect2f                                                 // axis aligned bounding box of the shape
+	IntersectsRect(x float64, y float64, w float64, h float64) bool // true if the shape overlaps or touches the region
+	ContainsPoint(p Vec2f) bool
+}
+
+// Rect2f is an axis aligned rectangle
+type Rect2f struct {
+	X, Y, W, H float64
+}
+
+// Circle2f is a circle around Center
+type Circle2f struct {
+	Center Vec2f
+	R      float64
+}
+
+// Bounds returns the rectangle itself
+func (r Rect2f) Bounds() Rect2f {
+	return r
+}
+
+// IntersectsRect returns true if both rectangles overlap or touch
+func (r Rect2f) IntersectsRect(x float64, y float64, w float64, h float64) bool {
+	return quadBounds{r.X, r.Y, r.W, r.H}.Intersects(x, y, w, h)
+}
+
+// ContainsPoint returns true if p lies inside of the rectangle or on its border
+func (r Rect2f) ContainsPoint(p Vec2f) bool {
+	return p.X >= r.X && p.X <= r.X+r.W && p.Y >= r.Y && p.Y <= r.Y+r.H
+}
+
+// ContainsRect returns true if the given region lies completely inside of the rectangle
+func (r Rect2f) ContainsRect(o Rect2f) bool {
+	return o.X >= r.X && o.X+o.W <= r.X+r.W && o.Y >= r.Y && o.Y+o.H <= r.Y+r.H
+}
+
+// Bounds returns the bounding square of the circle
+func (c Circle2f) Bounds() Rect2f {
+	return Rect2f{c.Center.X - c.R, c.Center.Y - c.R, 2 * c.R, 2 * c.R}
+}
+
+// IntersectsRect returns true if the circle overlaps or touches the region
+func (c Circle2f) IntersectsRect(x float64, y float64, w float64, h float64) bool {
+	return quadBounds{x, y, w, h}.SignedDistanceToPoint(c.Center) <= c.R
+}
+
+// ContainsPoint returns true if p lies inside of the circle or on its border
+func (c Circle2f) ContainsPoint(p Vec2f) bool {
+	return math.Hypot(p.X-c.Center.X, p.Y-c.Center.Y) <= c.R
+}
+
+// Polygon2f is a simple polygon given by its vertices in order, the closing edge from the last to the first vertex is implicit
+type Polygon2f struct {
+	Points []Vec2f
+}
+
+// Bounds returns the axis aligned bounding box of all vertices
+func (poly Polygon2f) Bounds() Rect2f {
+	if len(poly.Points) == 0 {
+		return Rect2f{}
+	}
+	minX, minY := poly.Points[0].X, poly.Points[0].Y
+	maxX, maxY := minX, minY
+	for _, p := range poly.Points[1:] {
+		minX = math.Min(minX, p.X)
+		minY = math.Min(minY, p.Y)
+		maxX = math.Max(maxX, p.X)
+		maxY = math.Max(maxY, p.Y)
+	}
+	return Rect2f{minX, minY, maxX - minX, maxY - minY}
+}
+
+// ContainsPoint returns true if p lies inside of the polygon, using the even-odd rule
+func (poly Polygon2f) ContainsPoint(p Vec2f) bool {
+	inside := false
+	for i, j := 0, len(poly.Points)-1; i < len(poly.Points); j, i = i, i+1 {
+		a, b := poly.Points[i], poly.Points[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Area returns the area enclosed by the polygon, using the shoelace formula
+func (poly Polygon2f) Area() float64 {
+	area := 0.0
+	for i, j := 0, len(poly.Points)-1; i < len(poly.Points); j, i = i, i+1 {
+		area += poly.Points[j].X*poly.Points[i].Y - poly.Points[i].X*poly.Points[j].Y
+	}
+	return math.Abs(area) / 2
+}
+
+// Centroid returns the center of mass of the area enclosed by the polygon
+// polygons without area return the average of their vertices instead
+func (poly Polygon2f) Centroid() Vec2f {
+	if len(poly.Points) == 0 {
+		return Vec2f{}
+	}
+	var area, cx, cy float64
+	for i, j := 0, len(poly.Points)-1; i < len(poly.Points); j, i = i, i+1 {
+		a, b := poly.Points[j], poly.Points[i]
+		c := a.X*b.Y - b.X*a.Y
+		area += c
+		cx += (a.X + b.X) * c
+		cy += (a.Y + b.Y) * c
+	}
+	if area == 0 {
+		for _, p := range poly.Points {
+			cx += p.X
+			cy += p.Y
+		}
+		return Vec2f{cx / float64(len(poly.Points)), cy / float64(len(poly.Points))}
+	}
+	return Vec2f{cx / (3 * area), cy / (3 * area)}
+}
+
+// IntersectsRect returns true if the polygon overlaps or touches the region
+func (poly Polygon2f) IntersectsRect(x float64, y float64, w float64, h float64) bool {
+	r := Rect2f{x, y, w, h}
+	if len(poly.Points) == 0 || !poly.Bounds().IntersectsRect(x, y, w, h) {
+		return false
+	}
+	return poly.intersectsPolygon(Polygon2f{[]Vec2f{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}}) || r.ContainsPoint(poly.Points[0])
+}
+
+// intersectsPolygon returns true if any edges of the two polygons cross, or one polygon lies inside of the other
+func (poly Polygon2f) intersectsPolygon(o Polygon2f) bool {
+	if len(poly.Points) == 0 || len(o.Points) == 0 {
+		return false
+	}
+	if poly.ContainsPoint(o.Points[0]) || o.ContainsPoint(poly.Points[0]) {
+		return true
+	}
+	for i, j := 0, len(poly.Points)-1; i < len(poly.Points); j, i = i, i+1 {
+		for k, l := 0, len(o.Points)-1; k < len(o.Points); l, k = k, k+1 {
+			if segmentsIntersect(poly.Points[j], poly.Points[i], o.Points[l], o.Points[k]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// intersectsCircle returns true if the polygon overlaps or touches the circle
+func (poly Polygon2f) intersectsCircle(c Circle2f) bool {
+	if len(poly.Points) == 0 {
+		return false
+	}
+	if poly.ContainsPoint(c.Center) {
+		return true
+	}
+	for i, j := 0, len(poly.Points)-1; i < len(poly.Points); j, i = i, i+1 {
+		if segmentDistanceToPoint(poly.Points[j], poly.Points[i], c.Center) <= c.R {
+			return true
+		}
+	}
+	return false
+}
+
+// cross2 returns the z component of the cross product of (b-a) and (c-a)
+func cross2(a Vec2f, b Vec2f, c Vec2f) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+// segmentsIntersect returns true if the segments a1-a2 and b1-b2 cross or touch
+func segmentsIntersect(a1 Vec2f, a2 Vec2f, b1 Vec2f, b2 Vec2f) bool {
+	d1 := cross2(b1, b2, a1)
+	d2 := cross2(b1, b2, a2)
+	d3 := cross2(a1, a2, b1)
+	d4 := cross2(a1, a2, b2)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	// collinear cases, check if an endpoint lies on the other segment
+	onSegment := func(a Vec2f, b Vec2f, p Vec2f) bool {
+		return p.X >= math.Min(a.X, b.X) && p.X <= math.Max(a.X, b.X) && p.Y >= math.Min(a.Y, b.Y) && p.Y <= math.Max(a.Y, b.Y)
+	}
+	return (d1 == 0 && onSegment(b1, b2, a1)) || (d2 == 0 && onSegment(b1, b2, a2)) ||
+		(d3 == 0 && onSegment(a1, a2, b1)) || (d4 == 0 && onSegment(a1, a2, b2))
+}
+
+// segmentDistanceToPoint returns the distance from p to the closest point on the segment a-b
+func segmentDistanceToPoint(a Vec2f, b Vec2f, p Vec2f) float64 {
+	abX, abY := b.X-a.X, b.Y-a.Y
+	l2 := abX*abX + abY*abY
+	t := 0.0
+	if l2 > 0 {
+		t = Clamp(((p.X-a.X)*abX+(p.Y-a.Y)*abY)/l2, 0, 1)
+	}
+	return math.Hypot(p.X-(a.X+t*abX), p.Y-(a.Y+t*abY))
+}
+
+// ShapesIntersect returns true if the two shapes overlap or touch
+// all pairs of rectangles, circles and polygons are tested exactly, other shapes fall back to testing against the bounds of their partner
+func ShapesIntersect(a Shape2f, b Shape2f) bool {
+	if rb, ok := b.(Rect2f); ok {
+		return a.IntersectsRect(rb.X, rb.Y, rb.W, rb.H)
+	}
+	if ra, ok := a.(Rect2f); ok {
+		return b.IntersectsRect(ra.X, ra.Y, ra.W, ra.H)
+	}
+	switch sa := a.(type) {
+	case Circle2f:
+		switch sb := b.(type) {
+		case Circle2f:
+			return math.Hypot(sa.Center.X-sb.Center.X, sa.Center.Y-sb.Center.Y) <= sa.R+sb.R
+		case Polygon2f:
+			return sb.intersectsCircle(sa)
+		}
+	case Polygon2f:
+		switch sb := b.(type) {
+		case Circle2f:
+			return sa.intersectsCircle(sb)
+		case Polygon2f:
+			return sa.intersectsPolygon(sb)
+		}
+	}
+	bb := b.Bounds()
+	return a.IntersectsRect(bb.X, bb.Y, bb.W, bb.H)
+}
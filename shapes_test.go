@@ -0,0 +1,122 @@
+package gah
+
+import (
+	"math"
+	"testing"
+)
+
+// testPolygonL is a concave L shape covering [0, 20] x [0, 20] except for the notch [10, 20] x [10, 20]
+var testPolygonL = Polygon2f{[]Vec2f{{0, 0}, {20, 0}, {20, 10}, {10, 10}, {10, 20}, {0, 20}}}
+
+func TestShapesIntersect(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Shape2f
+		want bool
+	}{
+		{"overlapping circles", Circle2f{Vec2f{0, 0}, 5}, Circle2f{Vec2f{8, 0}, 5}, true},
+		{"touching circles", Circle2f{Vec2f{0, 0}, 5}, Circle2f{Vec2f{10, 0}, 5}, true},
+		{"separate circles", Circle2f{Vec2f{0, 0}, 5}, Circle2f{Vec2f{7, 8}, 5}, false},
+		{"overlapping rects", Rect2f{0, 0, 10, 10}, Rect2f{5, 5, 10, 10}, true},
+		{"touching rects", Rect2f{0, 0, 10, 10}, Rect2f{10, 0, 10, 10}, true},
+		{"separate rects", Rect2f{0, 0, 10, 10}, Rect2f{11, 0, 10, 10}, false},
+		{"circle inside of rect", Circle2f{Vec2f{5, 5}, 1}, Rect2f{0, 0, 10, 10}, true},
+		{"circle beside rect", Circle2f{Vec2f{12, 5}, 2}, Rect2f{0, 0, 10, 10}, true},
+		{"circle near the corner of rect", Circle2f{Vec2f{12, 12}, 2.5}, Rect2f{0, 0, 10, 10}, false},
+		{"rect near the corner of circle", Rect2f{0, 0, 10, 10}, Circle2f{Vec2f{12, 12}, 2.5}, false},
+		{"polygon crossing rect", testPolygonL, Rect2f{5, -5, 2, 30}, true},
+		{"rect inside of polygon", testPolygonL, Rect2f{1, 1, 2, 2}, true},
+		{"polygon inside of rect", testPolygonL, Rect2f{-1, -1, 30, 30}, true},
+		{"rect in the notch of polygon", testPolygonL, Rect2f{12, 12, 5, 5}, false},
+		{"circle in the notch of polygon", testPolygonL, Circle2f{Vec2f{15, 15}, 4}, false},
+		{"circle touching an edge of polygon", testPolygonL, Circle2f{Vec2f{15, 15}, 5}, true},
+		{"circle inside of polygon", testPolygonL, Circle2f{Vec2f{2, 15}, 1}, true},
+		{"polygon inside of circle", testPolygonL, Circle2f{Vec2f{10, 10}, 50}, true},
+		{"crossing polygons", testPolygonL, Polygon2f{[]Vec2f{{5, 5}, {30, 5}, {30, 8}}}, true},
+		{"polygon in the notch of polygon", testPolygonL, Polygon2f{[]Vec2f{{12, 12}, {19, 12}, {19, 19}}}, false},
+		{"polygon sharing a vertex", testPolygonL, Polygon2f{[]Vec2f{{20, 0}, {30, 0}, {30, -10}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShapesIntersect(tt.a, tt.b); got != tt.want {
+				t.Errorf("ShapesIntersect(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := ShapesIntersect(tt.b, tt.a); got != tt.want {
+				t.Errorf("ShapesIntersect(%v, %v) = %v, want %v", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShapeContainsPoint(t *testing.T) {
+	tests := []struct {
+		shape Shape2f
+		p     Vec2f
+		want  bool
+	}{
+		{Rect2f{0, 0, 10, 5}, Vec2f{10, 5}, true},
+		{Rect2f{0, 0, 10, 5}, Vec2f{10, 5.1}, false},
+		{Circle2f{Vec2f{0, 0}, 5}, Vec2f{3, 4}, true},
+		{Circle2f{Vec2f{0, 0}, 5}, Vec2f{4, 4}, false},
+		{testPolygonL, Vec2f{5, 15}, true},
+		{testPolygonL, Vec2f{15, 5}, true},
+		{testPolygonL, Vec2f{15, 15}, false},
+		{testPolygonL, Vec2f{-1, 5}, false},
+		{Polygon2f{}, Vec2f{0, 0}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.shape.ContainsPoint(tt.p); got != tt.want {
+			t.Errorf("%v.ContainsPoint(%v) = %v, want %v", tt.shape, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentsIntersect(t *testing.T) {
+	tests := []struct {
+		name           string
+		a1, a2, b1, b2 Vec2f
+		want           bool
+	}{
+		{"crossing", Vec2f{0, 0}, Vec2f{10, 10}, Vec2f{0, 10}, Vec2f{10, 0}, true},
+		{"parallel", Vec2f{0, 0}, Vec2f{10, 0}, Vec2f{0, 1}, Vec2f{10, 1}, false},
+		{"touching at an end", Vec2f{0, 0}, Vec2f{10, 0}, Vec2f{10, 0}, Vec2f{10, 5}, true},
+		{"t junction", Vec2f{0, 0}, Vec2f{10, 0}, Vec2f{5, 0}, Vec2f{5, 5}, true},
+		{"collinear overlapping", Vec2f{0, 0}, Vec2f{10, 0}, Vec2f{5, 0}, Vec2f{15, 0}, true},
+		{"collinear apart", Vec2f{0, 0}, Vec2f{10, 0}, Vec2f{11, 0}, Vec2f{15, 0}, false},
+		{"passing beside", Vec2f{0, 0}, Vec2f{10, 0}, Vec2f{11, -1}, Vec2f{11, 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := segmentsIntersect(tt.a1, tt.a2, tt.b1, tt.b2); got != tt.want {
+				t.Errorf("segmentsIntersect = %v, want %v", got, tt.want)
+			}
+			if got := segmentsIntersect(tt.b2, tt.b1, tt.a1, tt.a2); got != tt.want {
+				t.Errorf("segmentsIntersect with swapped segments = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolygonAreaCentroid(t *testing.T) {
+	tests := []struct {
+		poly     Polygon2f
+		area     float64
+		centroid Vec2f
+	}{
+		{Polygon2f{[]Vec2f{{0, 0}, {4, 0}, {4, 2}, {0, 2}}}, 8, Vec2f{2, 1}},
+		{Polygon2f{[]Vec2f{{0, 2}, {4, 2}, {4, 0}, {0, 0}}}, 8, Vec2f{2, 1}},
+		{Polygon2f{[]Vec2f{{0, 0}, {3, 0}, {0, 3}}}, 4.5, Vec2f{1, 1}},
+		// the L is a 20 x 10 bar centered at (10, 5) and a 10 x 10 square centered at (5, 15)
+		{testPolygonL, 300, Vec2f{(200*10 + 100*5) / 300.0, (200*5 + 100*15) / 300.0}},
+		{Polygon2f{[]Vec2f{{0, 0}, {1, 1}, {2, 2}}}, 0, Vec2f{1, 1}},
+		{Polygon2f{}, 0, Vec2f{}},
+	}
+	for _, tt := range tests {
+		if got := tt.poly.Area(); math.Abs(got-tt.area) > 1e-12 {
+			t.Errorf("%v.Area() = %v, want %v", tt.poly, got, tt.area)
+		}
+		if got := tt.poly.Centroid(); math.Abs(got.X-tt.centroid.X) > 1e-12 || math.Abs(got.Y-tt.centroid.Y) > 1e-12 {
+			t.Errorf("%v.Centroid() = %v, want %v", tt.poly, got, tt.centroid)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package gah
+
+import (
+	"math"
+	"sort"
+)
+
+// SpatialIndex2D is implemented by structures that index 2D points for fast spatial lookups
+// use whichever backend suits the point distribution:
+// QuadTree adapts to clustered points, UniformGrid2D is fastest for evenly spread points with a known spacing,
+// and KDTree2D is best for point sets that are built once and queried many times
+type SpatialIndex2D interface {
+	InsertPoint(p Vec2f)
+	RemovePoint(p Vec2f) bool
+	QueryRange(x float64, y float64, w float64, h float64) []Vec2f
+	QueryRadius(p Vec2f, r float64) []Vec2f
+	QueryKNN(p Vec2f, k int) []Vec2f
+	Nearest(p Vec2f) (Vec2f, bool)
+}
+
+var (
+	_ SpatialIndex2D = (*QuadTree)(nil)
+	_ SpatialIndex2D = (*UniformGrid2D)(nil)
+	_ SpatialIndex2D = (*KDTree2D)(nil)
+)
+
+// UniformGrid2D is a spatial index that buckets points into a fixed grid of equally sized cells
+// works best if the cell size is close to the typical query radius / point spacing
+type UniformGrid2D struct {
+	x, y, w, h float64 // position and extent of the grid
+	cellSize   float64
+	cols, rows int
+	cells      [][]QuadTreeItem // row major buckets, the plain point methods store items with a zero ID
+	count      int
+}
+
+// NewUniformGrid2D creates a new UniformGrid2D with the given dimensions and cells of cellSize x cellSize
+func NewUniformGrid2D(x float64, y float64, w float64, h float64, cellSize float64) *UniformGrid2D {
+	cols := int(math.Ceil(w / cellSize))
+	rows := int(math.Ceil(h / cellSize))
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return &UniformGrid2D{x, y, w, h, cellSize, cols, rows, make([][]QuadTreeItem, cols*rows), 0}
+}
+
+// cellCoords returns the column and row of the cell that contains p, clamped to the grid
+func (ug *UniformGrid2D) cellCoords(p Vec2f) (cx int, cy int) {
+	cx = int(math.Floor((p.X - ug.x) / ug.cellSize))
+	cy = int(math.Floor((p.Y - ug.y) / ug.cellSize))
+	if cx < 0 {
+		cx = 0
+	}
+	if cx >= ug.cols {
+		cx = ug.cols - 1
+	}
+	if cy < 0 {
+		cy = 0
+	}
+	if cy >= ug.rows {
+		cy = ug.rows - 1
+	}
+	return
+}
+
+// Contains returns true if p lies inside of the grid bounds
+func (ug *UniformGrid2D) Contains(p Vec2f) bool {
+	return !(p.X < ug.x || p.X > ug.x+ug.w || p.Y < ug.y || p.Y > ug.y+ug.h)
+}
+
+// Len returns the number of points stored in the grid
+func (ug *UniformGrid2D) Len() int {
+	return ug.count
+}
+
+// InsertPoint inserts the given point into its bucket, points outside of the grid are ignored
+func (ug *UniformGrid2D) InsertPoint(p Vec2f) {
+	ug.insertItem(QuadTreeItem{Pos: p})
+}
+
+// insertItem inserts the given item into the bucket of its position, items outside of the grid are ignored
+func (ug *UniformGrid2D) insertItem(item QuadTreeItem) {
+	if !ug.Contains(item.Pos) {
+		return
+	}
+	cx, cy := ug.cellCoords(item.Pos)
+	ug.cells[cy*ug.cols+cx] = append(ug.cells[cy*ug.cols+cx], item)
+	ug.count++
+}
+
+// InsertPoints inserts the given points into the grid
+func (ug *UniformGrid2D) InsertPoints(p []Vec2f) {
+	for _, np := range p {
+		ug.InsertPoint(np)
+	}
+}
+
+// RemovePoint removes one item at the given position from the grid, regardless of its ID, returns false if none was found
+func (ug *UniformGrid2D) RemovePoint(p Vec2f) bool {
+	if !ug.Contains(p) {
+		return false
+	}
+	cx, cy := ug.cellCoords(p)
+	cell := ug.cells[cy*ug.cols+cx]
+	for i, item := range cell {
+		if item.Pos != p {
+			continue
+		}
+		cell[i] = cell[len(cell)-1]
+		ug.cells[cy*ug.cols+cx] = cell[:len(cell)-1]
+		ug.count--
+		return true
+	}
+	return false
+}
+
+// QueryRange returns all points of the grid inside the given region
+func (ug *UniformGrid2D) QueryRange(x float64, y float64, w float64, h float64) (results []Vec2f) {
+	results = []Vec2f{}
+	cx0, cy0 := ug.cellCoords(Vec2f{x, y})
+	cx1, cy1 := ug.cellCoords(Vec2f{x + w, y + h})
+	for cy := cy0; cy <= cy1; cy++ {
+		for cx := cx0; cx <= cx1; cx++ {
+			for _, item := range ug.cells[cy*ug.cols+cx] {
+				if p := item.Pos; p.X >= x && p.X <= x+w && p.Y >= y && p.Y <= y+h {
+					results = append(results, p)
+				}
+			}
+		}
+	}
+	return
+}
+
+// QueryRadius returns all points of the grid within distance r of the point p
+func (ug *UniformGrid2D) QueryRadius(p Vec2f, r float64) (results []Vec2f) {
+	results = []Vec2f{}
+	cx0, cy0 := ug.cellCoords(Vec2f{p.X - r, p.Y - r})
+	cx1, cy1 := ug.cellCoords(Vec2f{p.X + r, p.Y + r})
+	for cy := cy0; cy <= cy1; cy++ {
+		for cx := cx0; cx <= cx1; cx++ {
+			for _, item := range ug.cells[cy*ug.cols+cx] {
+				if cp := item.Pos; math.Hypot(p.X-cp.X, p.Y-cp.Y) <= r {
+					results = append(results, cp)
+				}
+			}
+		}
+	}
+	return
+}
+
+// QueryKNN returns the k nearest neighbors to the given point p, sorted by ascending distance
+// searches rings of cells around p until no unvisited cell can contain a closer point
+func (ug *UniformGrid2D) QueryKNN(p Vec2f, k int) (results []Vec2f) {
+	if k <= 0 || ug.count == 0 {
+		return nil
+	}
+	type distPoint struct {
+		dist float64
+		p    Vec2f
+	}
+	candidates := []distPoint{}
+	pcx, pcy := ug.cellCoords(p)
+	maxRing := ug.cols
+	if ug.rows > maxRing {
+		maxRing = ug.rows
+	}
+	for ring := 0; ring <= maxRing; ring++ {
+		// visit all cells on the border of the (2*ring+1)^2 block around p
+		for cy := pcy - ring; cy <= pcy+ring; cy++ {
+			if cy < 0 || cy >= ug.rows {
+				continue
+			}
+			step := 1
+			if cy != pcy-ring && cy != pcy+ring {
+				step = 2 * ring // only left and right border cells for inner rows
+			}
+			for cx := pcx - ring; cx <= pcx+ring; cx += step {
+				if cx >= 0 && cx < ug.cols {
+					for _, item := range ug.cells[cy*ug.cols+cx] {
+						cp := item.Pos
+						candidates = append(candidates, distPoint{math.Hypot(p.X-cp.X, p.Y-cp.Y), cp})
+					}
+				}
+			}
+		}
+		if len(candidates) < k {
+			continue
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].dist < candidates[j].dist
+		})
+		// any point in an unvisited cell is at least as far away as the border of the visited block
+		bx0 := ug.x + float64(pcx-ring)*ug.cellSize
+		by0 := ug.y + float64(pcy-ring)*ug.cellSize
+		bx1 := ug.x + float64(pcx+ring+1)*ug.cellSize
+		by1 := ug.y + float64(pcy+ring+1)*ug.cellSize
+		bound := math.Min(math.Min(p.X-bx0, bx1-p.X), math.Min(p.Y-by0, by1-p.Y))
+		if candidates[k-1].dist <= bound {
+			break
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].dist < candidates[j].dist
+	})
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	results = make([]Vec2f, len(candidates))
+	for i, c := range candidates {
+		results[i] = c.p
+	}
+	return results
+}
+
+// nearestWithin returns the item in the grid closest to p among those with a squared distance below maxDist2, ok is false if there is none
+// unlike QueryKNN it does not allocate, for callers that look up many positions and already know an upper bound of the distance
+func (ug *UniformGrid2D) nearestWithin(p Vec2f, maxDist2 float64) (nearest QuadTreeItem, ok bool) {
+	r := math.Sqrt(maxDist2)
+	cx0, cy0 := ug.cellCoords(Vec2f{p.X - r, p.Y - r})
+	cx1, cy1 := ug.cellCoords(Vec2f{p.X + r, p.Y + r})
+	for cy := cy0; cy <= cy1; cy++ {
+		for cx := cx0; cx <= cx1; cx++ {
+			for _, item := range ug.cells[cy*ug.cols+cx] {
+				cp := item.Pos
+				if dist2 := (p.X-cp.X)*(p.X-cp.X) + (p.Y-cp.Y)*(p.Y-cp.Y); dist2 < maxDist2 {
+					nearest, maxDist2, ok = item, dist2, true
+				}
+			}
+		}
+	}
+	return nearest, ok
+}
+
+// Nearest returns the point in the grid closest to p, ok is false if the grid is empty
+func (ug *UniformGrid2D) Nearest(p Vec2f) (nearest Vec2f, ok bool) {
+	knn := ug.QueryKNN(p, 1)
+	if len(knn) == 0 {
+		return Vec2f{}, false
+	}
+	return knn[0], true
+}
@@ -0,0 +1,158 @@
+package gah
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// spatialIndexBackends returns constructors for empty instances of every SpatialIndex2D backend covering [0, 1000] x [0, 1000]
+func spatialIndexBackends() []struct {
+	name     string
+	newIndex func() SpatialIndex2D
+} {
+	return []struct {
+		name     string
+		newIndex func() SpatialIndex2D
+	}{
+		{"quadtree", func() SpatialIndex2D { return NewQuadTree(0, 0, 1000, 1000) }},
+		{"uniform grid", func() SpatialIndex2D { return NewUniformGrid2D(0, 0, 1000, 1000, 50) }},
+		{"kd tree", func() SpatialIndex2D { return NewKDTree2D(nil) }},
+	}
+}
+
+// sortedPoints returns a sorted copy of the points, to compare query results regardless of their order
+func sortedPoints(points []Vec2f) []Vec2f {
+	sorted := append([]Vec2f{}, points...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].X != sorted[j].X {
+			return sorted[i].X < sorted[j].X
+		}
+		return sorted[i].Y < sorted[j].Y
+	})
+	return sorted
+}
+
+// checkSamePoints fails the test if got and want do not contain the same points with the same multiplicity
+func checkSamePoints(t *testing.T, query string, got []Vec2f, want []Vec2f) {
+	t.Helper()
+	got, want = sortedPoints(got), sortedPoints(want)
+	if len(got) != len(want) {
+		t.Fatalf("%s returned %d points, want %d", query, len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s returned %v, want %v", query, got, want)
+		}
+	}
+}
+
+// checkSpatialIndex compares all queries of the index against brute force searches over the points it should contain
+func checkSpatialIndex(t *testing.T, index SpatialIndex2D, points []Vec2f, rng *rand.Rand) {
+	t.Helper()
+	for i := 0; i < 30; i++ {
+		p := Vec2f{rng.Float64()*1100 - 50, rng.Float64()*1100 - 50}
+		x, y, w, h := p.X-100, p.Y-50, rng.Float64()*300, rng.Float64()*300
+		r := rng.Float64() * 150
+		inRange, inRadius := []Vec2f{}, []Vec2f{}
+		for _, q := range points {
+			if q.X >= x && q.X <= x+w && q.Y >= y && q.Y <= y+h {
+				inRange = append(inRange, q)
+			}
+			if math.Hypot(p.X-q.X, p.Y-q.Y) <= r {
+				inRadius = append(inRadius, q)
+			}
+		}
+		checkSamePoints(t, "QueryRange", index.QueryRange(x, y, w, h), inRange)
+		checkSamePoints(t, "QueryRadius", index.QueryRadius(p, r), inRadius)
+		for _, k := range []int{0, 1, 7, len(points) + 1} {
+			checkKNN(t, points, p, k, index.QueryKNN(p, k))
+		}
+		nearest, ok := index.Nearest(p)
+		if ok != (len(points) > 0) {
+			t.Fatalf("Nearest(%v) ok = %v with %d points", p, ok, len(points))
+		}
+		if ok {
+			if want := bruteKNNDistances(points, p, 1)[0]; math.Hypot(p.X-nearest.X, p.Y-nearest.Y) != want {
+				t.Fatalf("Nearest(%v) = %v, want a point at distance %v", p, nearest, want)
+			}
+		}
+	}
+}
+
+func TestSpatialIndexQueries(t *testing.T) {
+	for _, backend := range spatialIndexBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			index := backend.newIndex()
+			checkSpatialIndex(t, index, nil, rng)
+			points := randomPoints(rng, 400, 1000, 1000)
+			// duplicates and points on the border of the covered area
+			points = append(points, points[0], points[0], points[1], Vec2f{0, 0}, Vec2f{1000, 1000}, Vec2f{1000, 0})
+			for _, p := range points {
+				index.InsertPoint(p)
+			}
+			checkSpatialIndex(t, index, points, rng)
+			// remove every other point, including one of each duplicate
+			remaining := []Vec2f{}
+			for i, p := range points {
+				if i%2 == 1 {
+					remaining = append(remaining, p)
+					continue
+				}
+				if !index.RemovePoint(p) {
+					t.Fatalf("RemovePoint(%v) = false", p)
+				}
+			}
+			if index.RemovePoint(Vec2f{-1, -1}) || index.RemovePoint(Vec2f{123.5, 456.5}) {
+				t.Fatalf("RemovePoint of a point that was never inserted = true")
+			}
+			checkSpatialIndex(t, index, remaining, rng)
+		})
+	}
+}
+
+func TestKDTree2DConcurrentQueries(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomPoints(rng, 1000, 1000, 1000)
+	kdt := NewKDTree2D(points[:500])
+	kdt.InsertPoints(points[500:]) // leaves the tree dirty, so the queries below race to rebuild it
+	queries := randomPoints(rng, 64, 1000, 1000)
+	results := make([][]Vec2f, len(queries))
+	var wg sync.WaitGroup
+	for i := range queries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = kdt.QueryKNN(queries[i], 5)
+		}(i)
+	}
+	wg.Wait()
+	for i, p := range queries {
+		checkKNN(t, points, p, 5, results[i])
+	}
+}
+
+func TestUniformGrid2DNearestWithin(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomPoints(rng, 300, 1000, 1000)
+	ug := NewUniformGrid2D(0, 0, 1000, 1000, 40)
+	for i, p := range points {
+		ug.insertItem(QuadTreeItem{Pos: p, ID: i})
+	}
+	for i := 0; i < 200; i++ {
+		p := Vec2f{rng.Float64()*1100 - 50, rng.Float64()*1100 - 50}
+		nearest := bruteKNNDistances(points, p, 1)[0]
+		for _, bound := range []float64{nearest * 0.99, nearest * 1.01, nearest + 300} {
+			got, ok := ug.nearestWithin(p, bound*bound)
+			if ok != (nearest < bound) {
+				t.Fatalf("nearestWithin(%v, %v) ok = %v with the nearest point at distance %v", p, bound, ok, nearest)
+			}
+			if ok && (math.Hypot(p.X-got.Pos.X, p.Y-got.Pos.Y) != nearest || points[got.ID] != got.Pos) {
+				t.Fatalf("nearestWithin(%v, %v) = %v, want the item of a point at distance %v", p, bound, got, nearest)
+			}
+		}
+	}
+}
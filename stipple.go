@@ -0,0 +1,171 @@
+package gah
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Stipple is a dot placed by StippleImage or StippleTexture
+type Stipple struct {
+	Pos     Vec2f
+	Density float64 // average density of the region closest to the stipple in [0, 1], e.g. to scale the dot size
+}
+
+// StippleOptions control the weighted voronoi stippling of StippleImage and StippleTexture
+type StippleOptions struct {
+	Points      int     // number of stipples to place
+	Iterations  int     // maximum number of relaxation steps
+	Tolerance   float64 // stop early once no stipple moved further than this in pixels during a step
+	Supersample int     // centroids are integrated over this many samples per pixel along each axis, clamped to at least 1
+	Invert      bool    // place stipples in bright image regions or low texture values instead
+	Seed        uint64
+}
+
+// stippleDensity is a raster of w x h pixels with a density in [0, 1] each
+type stippleDensity struct {
+	w, h int
+	d    []float64
+}
+
+// newStippleDensity samples every pixel of the w x h area once through sample, which returns the density in [0, 1]
+func newStippleDensity(w int, h int, sample func(x, y int) float64) *stippleDensity {
+	src := &stippleDensity{w, h, make([]float64, w*h)}
+	for iy := 0; iy < h; iy++ {
+		for ix := 0; ix < w; ix++ {
+			src.d[iy*w+ix] = Clamp(sample(ix, iy), 0, 1)
+		}
+	}
+	return src
+}
+
+// initialPoints returns n points distributed randomly according to the density
+func (src *stippleDensity) initialPoints(n int, rng *rand.Rand) []Vec2f {
+	cdf := make([]float64, len(src.d))
+	total := 0.0
+	for i, d := range src.d {
+		total += d
+		cdf[i] = total
+	}
+	if total == 0 {
+		return []Vec2f{}
+	}
+	points := make([]Vec2f, n)
+	for i := range points {
+		px := sort.SearchFloat64s(cdf, rng.Float64()*total)
+		if px >= len(cdf) {
+			px = len(cdf) - 1
+		}
+		points[i] = Vec2f{float64(px%src.w) + rng.Float64(), float64(px/src.w) + rng.Float64()}
+	}
+	return points
+}
+
+// stippleCell accumulates the samples closest to one stipple
+type stippleCell struct {
+	weight   float64
+	wx, wy   float64 // positions of the samples weighted by their density
+	nSamples int
+}
+
+// accumulate integrates the density over the region closest to every point, at ss x ss samples per pixel
+func (src *stippleDensity) accumulate(points []Vec2f, ss int) []stippleCell {
+	cells := make([]stippleCell, len(points))
+	// the grid is sized for about 2 points per cell, every point carries the index of its stipple as ID
+	grid := NewUniformGrid2D(0, 0, float64(src.w), float64(src.h), math.Max(math.Sqrt(2*float64(src.w*src.h)/float64(len(points))), 1))
+	for i, p := range points {
+		grid.insertItem(QuadTreeItem{Pos: p, ID: i})
+	}
+	rowHint := 0
+	for sy := 0; sy < src.h*ss; sy++ {
+		y := (float64(sy) + 0.5) / float64(ss)
+		hint := rowHint
+		for sx := 0; sx < src.w*ss; sx++ {
+			x := (float64(sx) + 0.5) / float64(ss)
+			// consecutive samples are usually closest to the same or a neighboring point, so the distance to the previous one keeps the search small
+			h := points[hint]
+			if nearest, ok := grid.nearestWithin(Vec2f{x, y}, (x-h.X)*(x-h.X)+(y-h.Y)*(y-h.Y)); ok {
+				hint = nearest.ID
+			}
+			if sx == 0 {
+				rowHint = hint
+			}
+			d := src.d[(sy/ss)*src.w+sx/ss]
+			c := &cells[hint]
+			c.weight += d
+			c.wx += d * x
+			c.wy += d * y
+			c.nSamples++
+		}
+	}
+	return cells
+}
+
+// stipple places the stipples on the density raster, see StippleImage
+func stipple(src *stippleDensity, opts StippleOptions) []Stipple {
+	ss := opts.Supersample
+	if ss < 1 {
+		ss = 1
+	}
+	points := src.initialPoints(opts.Points, rand.New(rand.NewSource(int64(opts.Seed))))
+	if len(points) == 0 {
+		return []Stipple{}
+	}
+	cells := src.accumulate(points, ss)
+	for it := 0; it < opts.Iterations; it++ {
+		// move every stipple to the density weighted centroid of its region, stipples without any density keep their place
+		moved := 0.0
+		for i, c := range cells {
+			if c.weight == 0 {
+				continue
+			}
+			centroid := Vec2f{c.wx / c.weight, c.wy / c.weight}
+			moved = math.Max(moved, math.Hypot(centroid.X-points[i].X, centroid.Y-points[i].Y))
+			points[i] = centroid
+		}
+		cells = src.accumulate(points, ss)
+		if moved <= opts.Tolerance {
+			break
+		}
+	}
+	stipples := make([]Stipple, len(points))
+	for i, p := range points {
+		stipples[i] = Stipple{p, 0}
+		if cells[i].nSamples > 0 {
+			stipples[i].Density = cells[i].weight / float64(cells[i].nSamples)
+		}
+	}
+	return stipples
+}
+
+// StippleImage distributes stipples over the image so that their density follows its darkness, using weighted voronoi stippling (Secord)
+// starting from a random distribution, every step moves each stipple to the centroid of its voronoi region weighted by the density
+// the stipples are placed in the area (0, 0) to the size of the image, transparent pixels get no stipples
+func StippleImage(img image.Image, opts StippleOptions) []Stipple {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	src := newStippleDensity(w, h, func(x, y int) float64 {
+		r, g, b, a := ImgGetRGBA(img, x, y)
+		// the color components are premultiplied, so the luminance is already scaled by the alpha
+		lum := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 255
+		if opts.Invert {
+			return lum
+		}
+		return float64(a)/255 - lum
+	})
+	return stipple(src, opts)
+}
+
+// StippleTexture works as StippleImage does, but uses the output of the given texture provider scaled from its eval range as density
+// the provider is sampled once for every pixel in the area (x, y) to (x+w, y+h), the stipples are placed in (0, 0) to (w, h)
+func StippleTexture(provider TextureCachable, x int, y int, w int, h int, opts StippleOptions) []Stipple {
+	emin, emax := provider.GetEvalRange()
+	src := newStippleDensity(w, h, func(ix, iy int) float64 {
+		d := scaleEvalRange(provider.Eval2(float64(x+ix), float64(y+iy)), emin, emax)
+		if opts.Invert {
+			return 1 - d
+		}
+		return d
+	})
+	return stipple(src, opts)
+}
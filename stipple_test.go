@@ -0,0 +1,123 @@
+package gah
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// stippleTestImage is black on the left half, white on the right half and transparent in the bottom quarter
+func stippleTestImage(w int, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			switch {
+			case y >= h*3/4:
+				img.Set(x, y, color.NRGBA{0, 0, 0, 0})
+			case x < w/2:
+				img.Set(x, y, color.NRGBA{0, 0, 0, 255})
+			default:
+				img.Set(x, y, color.NRGBA{255, 255, 255, 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestStippleImage(t *testing.T) {
+	img := stippleTestImage(80, 80)
+	opts := StippleOptions{Points: 200, Iterations: 20, Tolerance: 0.01, Supersample: 2, Seed: 4}
+	stipples := StippleImage(img, opts)
+	if len(stipples) != opts.Points {
+		t.Fatalf("got %d stipples, want %d", len(stipples), opts.Points)
+	}
+	spacing := math.Inf(1)
+	for i, s := range stipples {
+		// all stipples land in the opaque black region
+		if s.Pos.X < 0 || s.Pos.X > 40 || s.Pos.Y < 0 || s.Pos.Y > 60 {
+			t.Fatalf("stipple %d at %v lies outside of the dark region", i, s.Pos)
+		}
+		if s.Density <= 0 || s.Density > 1 {
+			t.Fatalf("stipple %d has density %v", i, s.Density)
+		}
+		for _, o := range stipples[:i] {
+			spacing = math.Min(spacing, math.Hypot(s.Pos.X-o.Pos.X, s.Pos.Y-o.Pos.Y))
+		}
+	}
+	// 200 stipples evenly spread over 40 x 60 pixels are about 3.5 pixels apart, random placement would leave some much closer
+	if spacing < 1.5 {
+		t.Errorf("closest stipples are %v apart, want a relaxed spacing", spacing)
+	}
+	// the same seed places the same stipples
+	again := StippleImage(img, opts)
+	for i := range stipples {
+		if stipples[i] != again[i] {
+			t.Fatalf("stipple %d is %v, then %v with the same seed", i, stipples[i], again[i])
+		}
+	}
+	// inverted, the stipples land in the opaque white region
+	opts.Invert = true
+	for i, s := range StippleImage(img, opts) {
+		if s.Pos.X < 40 || s.Pos.Y > 60 {
+			t.Fatalf("inverted stipple %d at %v lies outside of the bright region", i, s.Pos)
+		}
+	}
+	// a fully transparent image gets no stipples
+	if got := StippleImage(image.NewNRGBA(image.Rect(0, 0, 10, 10)), opts); len(got) != 0 {
+		t.Errorf("transparent image got %d stipples", len(got))
+	}
+}
+
+func TestStippleAccumulate(t *testing.T) {
+	src := newStippleDensity(20, 10, func(x, y int) float64 { return float64(x+y) / 28 })
+	// coincident stipples are separate entries, the samples closest to their position go to one of them and none get lost
+	points := []Vec2f{{4, 4}, {15, 3}, {4, 4}, {9, 8}, {15, 3}}
+	cells := src.accumulate(points, 2)
+	total, weight := 0, 0.0
+	for _, c := range cells {
+		total += c.nSamples
+		weight += c.weight
+	}
+	if want := 20 * 10 * 2 * 2; total != want {
+		t.Errorf("cells hold %d samples, want %d", total, want)
+	}
+	// every sample goes to the closest stipple, so coincident ones together get what a single stipple at their position gets
+	single := src.accumulate([]Vec2f{{4, 4}, {15, 3}, {9, 8}}, 2)
+	for j, group := range [][]int{{0, 2}, {1, 4}, {3}} {
+		var shared stippleCell
+		for _, i := range group {
+			shared.nSamples += cells[i].nSamples
+			shared.weight += cells[i].weight
+		}
+		if shared.nSamples != single[j].nSamples || math.Abs(shared.weight-single[j].weight) > 1e-9 {
+			t.Errorf("stipples %v hold %d samples of weight %v, want %d of weight %v", group, shared.nSamples, shared.weight, single[j].nSamples, single[j].weight)
+		}
+	}
+	if math.Abs(weight-single[0].weight-single[1].weight-single[2].weight) > 1e-9 {
+		t.Errorf("cells hold a weight of %v, want the total density", weight)
+	}
+}
+
+// stippleRamp is a texture provider rising linearly from 0 at x = 0 to 1 at x = 100
+type stippleRamp struct{}
+
+func (stippleRamp) Eval2(x, y float64) float64       { return x / 100 }
+func (stippleRamp) GetEvalRange() (float64, float64) { return 0, 1 }
+func (stippleRamp) GetParamSignature() []byte        { return []byte("stippleRamp") }
+
+func TestStippleTexture(t *testing.T) {
+	// the texture is sampled from its offset, the density in the stippled area rises from 0.5 to 1
+	stipples := StippleTexture(stippleRamp{}, 50, 0, 50, 50, StippleOptions{Points: 400, Iterations: 30, Supersample: 1, Seed: 1})
+	counts := [2]int{}
+	for _, s := range stipples {
+		if s.Pos.X < 0 || s.Pos.X > 50 || s.Pos.Y < 0 || s.Pos.Y > 50 {
+			t.Fatalf("stipple at %v lies outside of the area", s.Pos)
+		}
+		counts[int(s.Pos.X)/25]++
+	}
+	// the right half carries 0.875 / 0.625 = 1.4 times the density of the left half
+	if ratio := float64(counts[1]) / float64(counts[0]); ratio < 1.2 || ratio > 1.6 {
+		t.Errorf("stipples in the left and right half are %v, want a ratio near 1.4", counts)
+	}
+}
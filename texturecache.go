@@ -65,7 +65,7 @@ func NewTextureCache(provider TextureCachable, x int, y int, w int, h int, path
 		for iy := y; iy < y+h; iy++ {
 			ixf := float64(ix)
 			iyf := float64(iy)
-			c := uint8(ScaleF2I(provider.Eval2(ixf, iyf), emin, emax, 0, 255))
+			c := evalRangeToGray(provider.Eval2(ixf, iyf), emin, emax)
 			tc.texture.SetRGBA(ix, iy, color.RGBA{c, c, c, 0xFF})
 		}
 	}
@@ -73,6 +73,23 @@ func NewTextureCache(provider TextureCachable, x int, y int, w int, h int, path
 	return tc
 }
 
+// scaleEvalRange scales the value from the eval range of a provider to [0, 1], clamping values outside of the range
+// degenerate ranges, e.g. of constant providers, map everything to 0.5
+func scaleEvalRange(v float64, emin float64, emax float64) float64 {
+	if !(emax > emin) {
+		return 0.5
+	}
+	return Clamp(ScaleF2F(v, emin, emax, 0, 1), 0, 1)
+}
+
+// evalRangeToGray scales the value from the eval range of a provider to a gray level in [0, 255], see scaleEvalRange
+func evalRangeToGray(v float64, emin float64, emax float64) uint8 {
+	if !(emax > emin) {
+		return 128
+	}
+	return uint8(ScaleF2I(Clamp(v, emin, emax), emin, emax, 0, 255))
+}
+
 // Sample returns the grayscale value [0, 1] at the given position in the cached texture
 func (tc *TextureCache) Sample(x int, y int) float64 {
 	if x < tc.x || x >= tc.x+tc.w || y < tc.y || y >= tc.y+tc.h {
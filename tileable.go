@@ -0,0 +1,41 @@
+package gah
+
+import "math"
+
+// TextureCachable4D is a TextureCachable that can also be sampled in 4 dimensions, like CoherentNoise
+type TextureCachable4D interface {
+	TextureCachable
+	Eval4(x, y, z, w float64) float64
+}
+
+var _ TextureCachable4D = (*CoherentNoise)(nil)
+
+// TileableNoise2D samples a 4D source on a torus, so that its Eval2 repeats seamlessly with a period of W and H
+// the radii of the torus are chosen so that features keep the size they have in the Eval2 of the source
+type TileableNoise2D struct {
+	Source     TextureCachable4D
+	X, Y, W, H float64 // the tile, positions outside of it are repeated
+}
+
+// NewTileableNoise2D returns a tileable version of the source, repeating the area (x, y) to (x+w, y+h)
+func NewTileableNoise2D(source TextureCachable4D, x float64, y float64, w float64, h float64) *TileableNoise2D {
+	return &TileableNoise2D{source, x, y, w, h}
+}
+
+// GetParamSignature returns a byte slice containing all relevant unique parameters
+func (tn *TileableNoise2D) GetParamSignature() []byte {
+	return graphSignature("tileable", []float64{tn.X, tn.Y, tn.W, tn.H}, tn.Source)
+}
+
+// GetEvalRange returns the min and max values that can be expected from the Eval2, which is the 4D range of the source
+func (tn *TileableNoise2D) GetEvalRange() (outMin float64, outMax float64) {
+	return evalRangeN(tn.Source, 4)
+}
+
+// Eval2 returns the source sampled at the point of the torus corresponding to the given position
+func (tn *TileableNoise2D) Eval2(x, y float64) float64 {
+	a := 2 * math.Pi * (x - tn.X) / tn.W
+	b := 2 * math.Pi * (y - tn.Y) / tn.H
+	rx, ry := tn.W/(2*math.Pi), tn.H/(2*math.Pi)
+	return tn.Source.Eval4(rx*math.Cos(a), rx*math.Sin(a), ry*math.Cos(b), ry*math.Sin(b))
+}
@@ -0,0 +1,86 @@
+package gah
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestTileableNoise2D(t *testing.T) {
+	tn := NewTileableNoise2D(NewCoherentNoise(4, 0.02, 4, 2, 0.5), 30, -20, 256, 128)
+	emin, emax := tn.GetEvalRange()
+	for x := 30.0; x < 286; x += 7.9 {
+		for y := -20.0; y < 108; y += 5.3 {
+			v := tn.Eval2(x, y)
+			if v < emin || v > emax {
+				t.Fatalf("Eval2(%v, %v) = %v, outside of [%v, %v]", x, y, v, emin, emax)
+			}
+			for _, p := range [][2]float64{{x + 256, y}, {x - 256, y}, {x, y + 128}, {x + 512, y - 384}} {
+				if w := tn.Eval2(p[0], p[1]); math.Abs(w-v) > 1e-9 {
+					t.Fatalf("Eval2(%v, %v) = %v, want the value %v at (%v, %v)", p[0], p[1], w, v, x, y)
+				}
+			}
+		}
+	}
+	// no seam at the tile borders
+	for y := -20.0; y < 108; y += 3.1 {
+		if d := math.Abs(tn.Eval2(30.01, y) - tn.Eval2(285.99, y)); d > 0.01 {
+			t.Errorf("noise jumps by %v across the left and right border at y = %v", d, y)
+		}
+	}
+	if bytes.Equal(tn.GetParamSignature(), NewTileableNoise2D(tn.Source, 30, -20, 256, 129).GetParamSignature()) {
+		t.Errorf("different tiles give the same signature")
+	}
+}
+
+func TestTileableVoronoiDiagram2D(t *testing.T) {
+	for _, k := range []int{0, 1, -1} {
+		vd := NewTileableVoronoiDiagram2D(3, 100, 50, 300, 200, 30, k, 30)
+		for i, p := range vd.Points {
+			if p.X < vd.X || p.X >= vd.X+vd.W || p.Y < vd.Y || p.Y >= vd.Y+vd.H {
+				t.Fatalf("point %v is outside of the tile", p)
+			}
+			for _, q := range vd.Points[i+1:] {
+				if d := vd.dist(p.X, p.Y, q); d < vd.Scale {
+					t.Fatalf("points %v and %v are only %v apart across the wrap", p, q, d)
+				}
+			}
+		}
+		for x := 100.0; x < 400; x += 9.7 {
+			for y := 50.0; y < 250; y += 8.3 {
+				v := vd.Eval2(x, y)
+				if v <= 0 || v > 1 {
+					t.Fatalf("k %d: Eval2(%v, %v) = %v, outside of (0, 1]", k, x, y, v)
+				}
+				for _, p := range [][2]float64{{x + 300, y}, {x - 300, y - 200}, {x + 900, y + 400}} {
+					if w := vd.Eval2(p[0], p[1]); math.Abs(w-v) > 1e-9 {
+						t.Fatalf("k %d: Eval2(%v, %v) = %v, want the value %v at (%v, %v)", k, p[0], p[1], w, v, x, y)
+					}
+				}
+			}
+		}
+	}
+	plain := NewVoronoiDiagram2D(3, 100, 50, 300, 200, 30, 0, 30)
+	if plain.Eval2(450, 100) != 0 {
+		t.Errorf("plain diagram is not 0 out of bounds")
+	}
+	if bytes.Equal(plain.GetParamSignature(), NewTileableVoronoiDiagram2D(3, 100, 50, 300, 200, 30, 0, 30).GetParamSignature()) {
+		t.Errorf("tileable and plain diagram have the same signature")
+	}
+}
+
+func TestVoronoiDiagram2DCoverage(t *testing.T) {
+	// the sites of a diagram that is not at the origin still cover all of it
+	vd := NewVoronoiDiagram2D(8, 500, 300, 200, 150, 20, 0, 30)
+	for x := 500.0; x < 700; x += 5 {
+		for y := 300.0; y < 450; y += 5 {
+			nearest := math.Inf(1)
+			for _, p := range vd.Points {
+				nearest = math.Min(nearest, math.Hypot(x-p.X, y-p.Y))
+			}
+			if nearest > 2*vd.Scale {
+				t.Fatalf("closest site to (%v, %v) is %v away", x, y, nearest)
+			}
+		}
+	}
+}
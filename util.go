@@ -18,6 +18,11 @@ type Vec2f struct {
 	X, Y float64
 }
 
+// Vec3f is a simple 3D float vector
+type Vec3f struct {
+	X, Y, Z float64
+}
+
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
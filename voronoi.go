@@ -4,6 +4,7 @@ import (
 	"math"
 	"math/rand"
 	"sort"
+	"sync"
 
 	"github.com/fogleman/poissondisc"
 )
@@ -16,18 +17,105 @@ type VoronoiDiagram2D struct {
 	Scale      float64
 	K          int
 	PdsTrys    int
+	Tileable   bool           // if set, distances wrap around the W and H period and the diagram repeats seamlessly in every direction
+	Metric     DistanceMetric // measures the distances to the sites, nil for euclidean
+	Mode       VoronoiMode
+	Output     VoronoiOutput
+	index      *voronoiSiteIndex
+}
+
+// VoronoiMode selects which distances to the closest sites make up the output of a VoronoiDiagram2D
+// F1 is the distance to the closest site, F2 to the second closest, and so on
+type VoronoiMode int
+
+const (
+	VoronoiKNearest  VoronoiMode = iota // the distance to the k-th nearest site, or crackle if K is negative
+	VoronoiF1                           // F1, the same as VoronoiKNearest with K = 0
+	VoronoiF2                           // F2, the same as VoronoiKNearest with K = 1
+	VoronoiF2MinusF1                    // F2 - F1, which is 0 on the cell borders
+	VoronoiF1TimesF2                    // F1 * F2, which is 0 on the sites
+	VoronoiBorder                       // the exact distance to the closest cell border, the same as VoronoiKNearest with a negative K, see BorderDistance
+)
+
+// VoronoiOutput selects what Eval2 of a VoronoiDiagram2D returns
+type VoronoiOutput int
+
+const (
+	VoronoiOutputDistance  VoronoiOutput = iota // the distances selected by the mode, see Eval2
+	VoronoiOutputCellValue                      // the CellValue of the closest site, so that every cell is one flat value
+)
+
+// voronoiSiteIndex holds the QuadTree of the sites of a VoronoiDiagram2D, built lazily on the first lookup
+// every item has the index of its site in Points as ID, tileable diagrams also contain the 8 wrapped copies of every site
+// the index keeps a copy of the sites and the period it was built from, so that lookups notice when Points was modified
+type voronoiSiteIndex struct {
+	mu       sync.RWMutex
+	tree     *QuadTree
+	points   []Vec2f
+	tileable bool
+	w, h     float64
+}
+
+// current returns whether the index was built from the sites and period the diagram has now
+func (idx *voronoiSiteIndex) current(vd *VoronoiDiagram2D) bool {
+	if idx.tree == nil || len(idx.points) != len(vd.Points) || idx.tileable != vd.Tileable {
+		return false
+	}
+	if vd.Tileable && (idx.w != vd.W || idx.h != vd.H) {
+		return false
+	}
+	for i, p := range vd.Points {
+		if idx.points[i] != p {
+			return false
+		}
+	}
+	return true
+}
+
+// voronoiSite is a site found by a lookup, with its distance to the query position
+type voronoiSite struct {
+	i    int
+	dist float64
 }
 
 // NewVoronoiDiagram2D creates a new voronoi diagram, with points spaced to have a minimum distance given by the scale
-// if k is -1 then crackle will be used instead of k nearest neighbor, i.e. return distance to nearest edge
+// if k is negative then crackle will be used instead of k nearest neighbor, i.e. return distance to nearest edge, see BorderDistance
 func NewVoronoiDiagram2D(seed uint64, x float64, y float64, w float64, h float64, scale float64, k int, pdsTrys int) *VoronoiDiagram2D {
-	vd := &VoronoiDiagram2D{seed, x, y, w, h, []Vec2f{}, scale, k, pdsTrys}
-	for _, sample := range poissondisc.Sample(x-scale, y-scale, w+scale, h+scale, scale, pdsTrys, rand.New(rand.NewSource(int64(seed)))) {
+	vd := &VoronoiDiagram2D{seed, x, y, w, h, []Vec2f{}, scale, k, pdsTrys, false, nil, VoronoiKNearest, VoronoiOutputDistance, &voronoiSiteIndex{}}
+	for _, sample := range poissondisc.Sample(x-scale, y-scale, x+w+scale, y+h+scale, scale, pdsTrys, rand.New(rand.NewSource(int64(seed)))) {
 		vd.Points = append(vd.Points, Vec2f{sample.X, sample.Y})
 	}
 	return vd
 }
 
+// NewTileableVoronoiDiagram2D creates a new voronoi diagram that repeats seamlessly with a period of w and h, see NewVoronoiDiagram2D
+// points are only placed inside of the area, points that would be closer than the scale to another point across the wrap are dropped
+func NewTileableVoronoiDiagram2D(seed uint64, x float64, y float64, w float64, h float64, scale float64, k int, pdsTrys int) *VoronoiDiagram2D {
+	vd := &VoronoiDiagram2D{seed, x, y, w, h, []Vec2f{}, scale, k, pdsTrys, true, nil, VoronoiKNearest, VoronoiOutputDistance, &voronoiSiteIndex{}}
+	nearEdge := func(p Vec2f) bool {
+		return p.X-x < scale || x+w-p.X < scale || p.Y-y < scale || y+h-p.Y < scale
+	}
+	var edgePoints []Vec2f
+	for _, sample := range poissondisc.Sample(x, y, x+w, y+h, scale, pdsTrys, rand.New(rand.NewSource(int64(seed)))) {
+		p := Vec2f{sample.X, sample.Y}
+		if nearEdge(p) {
+			tooClose := false
+			for _, ep := range edgePoints {
+				if vd.dist(p.X, p.Y, ep) < scale {
+					tooClose = true
+					break
+				}
+			}
+			if tooClose {
+				continue
+			}
+			edgePoints = append(edgePoints, p)
+		}
+		vd.Points = append(vd.Points, p)
+	}
+	return vd
+}
+
 // GetParamSignature returns a byte slice containing all relevant unique parameters
 func (vd *VoronoiDiagram2D) GetParamSignature() (signature []byte) {
 	signature = append(signature, IntToBytes(int(vd.Seed))...)
@@ -38,6 +126,18 @@ func (vd *VoronoiDiagram2D) GetParamSignature() (signature []byte) {
 	signature = append(signature, Float64ToBytes(vd.Scale)...)
 	signature = append(signature, IntToBytes(vd.K)...)
 	signature = append(signature, IntToBytes(vd.PdsTrys)...)
+	if vd.Tileable {
+		signature = append(signature, 1)
+	}
+	signature = append(signature, IntToBytes(int(vd.Mode))...)
+	signature = append(signature, IntToBytes(int(vd.Output))...)
+	signature = append(signature, vd.metric().GetParamSignature()...)
+	// the sites can be moved after construction, e.g. by Relax, so they are part of the signature as well
+	h := HashInts(0, len(vd.Points))
+	for _, p := range vd.Points {
+		h = HashInts(h, int(math.Float64bits(p.X)), int(math.Float64bits(p.Y)))
+	}
+	signature = append(signature, IntToBytes(int(h))...)
 	return signature
 }
 
@@ -46,34 +146,304 @@ func (vd *VoronoiDiagram2D) GetEvalRange() (outMin float64, outMax float64) {
 	return 0, 1
 }
 
-// Eval2 returns the distance to the k nearest neighbor
-// returns within range [0, 1]; or 0 for out of bounds; 1 is closest to a point
+// metric returns the metric measuring the distances to the sites
+func (vd *VoronoiDiagram2D) metric() DistanceMetric {
+	if vd.Metric == nil {
+		return &EuclideanMetric{}
+	}
+	return vd.Metric
+}
+
+// dist returns the distance from the position to the point by the metric, wrapped around the period of the diagram if it is tileable
+func (vd *VoronoiDiagram2D) dist(x float64, y float64, p Vec2f) float64 {
+	dx, dy := x-p.X, y-p.Y
+	if vd.Tileable {
+		dx -= vd.W * math.Round(dx/vd.W)
+		dy -= vd.H * math.Round(dy/vd.H)
+	}
+	return vd.metric().Distance(dx, dy)
+}
+
+// CellValue returns a random value in [0, 1] for the cell of the given site, e.g. to color the cells from a ColorRamp
+// the value only depends on the seed of the diagram and the index of the site
+func (vd *VoronoiDiagram2D) CellValue(site int) float64 {
+	return HashToUnit(HashInts(vd.Seed, site))
+}
+
+// wrap returns the position wrapped into the area if the diagram is tileable, otherwise the position itself
+func (vd *VoronoiDiagram2D) wrap(x float64, y float64) (float64, float64) {
+	if vd.Tileable {
+		x = vd.X + math.Mod(math.Mod(x-vd.X, vd.W)+vd.W, vd.W)
+		y = vd.Y + math.Mod(math.Mod(y-vd.Y, vd.H)+vd.H, vd.H)
+	}
+	return x, y
+}
+
+// wrappedPos returns the position of the site, or of its wrapped copy closest to p if the diagram is tileable
+func (vd *VoronoiDiagram2D) wrappedPos(p Vec2f, site int) Vec2f {
+	q := vd.Points[site]
+	if vd.Tileable {
+		q.X += math.Round((p.X-q.X)/vd.W) * vd.W
+		q.Y += math.Round((p.Y-q.Y)/vd.H) * vd.H
+	}
+	return q
+}
+
+// extent is the largest distance between two positions in the area, it replaces distances to sites that do not exist
+func (vd *VoronoiDiagram2D) extent() float64 {
+	if vd.Tileable {
+		return vd.metric().Distance(vd.W/2, vd.H/2)
+	}
+	return vd.metric().Distance(vd.W, vd.H)
+}
+
+// Eval2 returns the distance selected by the mode, scaled by the distance to the next further site and inverted
+// returns within range [0, 1]; or 0 for out of bounds; 1 is closest to a point, or closest to a cell border for crackle
+// K >= 0 selects the distance to the k nearest neighbor, where 0 is the closest one, any negative K selects crackle
+// sites that do not exist because the diagram has too few of them are infinitely far away,
+// so the output is 0 if the selected site is missing, and the extent of the area is used for scaling if the next further one is
+// tileable diagrams are never out of bounds, positions outside of the area are wrapped into it
 func (vd *VoronoiDiagram2D) Eval2(x, y float64) float64 {
+	v, _ := vd.Eval2Site(x, y)
+	return v
+}
+
+// Eval2Site works as Eval2 does, but also returns the index in Points of the site closest to the position, or -1 for out of bounds
+func (vd *VoronoiDiagram2D) Eval2Site(x, y float64) (v float64, site int) {
+	x, y = vd.wrap(x, y)
 	if x < vd.X || x >= vd.X+vd.W || y < vd.Y || y >= vd.Y+vd.H {
+		return 0, -1
+	}
+	mode, k := vd.Mode, vd.K
+	if mode == VoronoiKNearest && k < 0 { // if crackle
+		mode = VoronoiBorder
+	}
+	if vd.Output == VoronoiOutputCellValue {
+		distances := vd.nearestSites(x, y, 1)
+		if len(distances) == 0 {
+			return 0, -1
+		}
+		return vd.CellValue(distances[0].i), distances[0].i
+	}
+	if mode == VoronoiBorder {
+		borderDist, site := vd.BorderDistance(x, y)
+		if site < 0 {
+			return 0, -1
+		}
+		return vd.distanceValue(borderDist, borderDist+vd.dist(x, y, vd.Points[site])), site
+	}
+	n := k + 2
+	switch mode {
+	case VoronoiF1:
+		n = 2
+	case VoronoiF2, VoronoiF2MinusF1, VoronoiF1TimesF2:
+		n = 3
+	}
+	distances := vd.nearestSites(x, y, n)
+	if len(distances) == 0 {
+		return 0, -1
+	}
+	target := func(i int) float64 {
+		if i < len(distances) {
+			return distances[i].dist
+		}
+		return math.Inf(1)
+	}
+	border := func(i int) float64 {
+		if i < len(distances) {
+			return distances[i].dist
+		}
+		return vd.extent()
+	}
+	switch mode {
+	case VoronoiF1:
+		v = vd.distanceValue(target(0), border(1))
+	case VoronoiF2:
+		v = vd.distanceValue(target(1), border(2))
+	case VoronoiF2MinusF1:
+		v = vd.distanceValue(target(1)-target(0), border(2))
+	case VoronoiF1TimesF2:
+		v = vd.distanceValue(target(0)*target(1), border(2)*border(2))
+	default:
+		v = vd.distanceValue(target(k), border(k+1))
+	}
+	return v, distances[0].i
+}
+
+// distanceValue maps the target distance to the output range of Eval2, scaled by the border distance and inverted
+// a target distance of 0 gives 1, an infinite one 0
+func (vd *VoronoiDiagram2D) distanceValue(targetDist float64, borderDist float64) float64 {
+	if math.IsInf(targetDist, 1) {
 		return 0
 	}
-	// calc dist to every point
-	type distIndex struct {
-		i    int
-		dist float64
+	return Clamp(1-ScaleF2F(targetDist, 0, borderDist+1, 0, 1), 0, 1)
+}
+
+// BorderDistance returns the distance from the position to the closest border of its cell, and the index in Points of the site of that cell
+// for the euclidean metric the distance is exact, it is the smallest distance to the perpendicular bisectors between the site and its neighbors
+// other metrics return (F2 - F1) / 2 instead, which is a lower bound of the distance that is exact on the line between the two closest sites
+// the distance is infinite if there is only one site, and the site is -1 if there are none
+func (vd *VoronoiDiagram2D) BorderDistance(x, y float64) (dist float64, site int) {
+	x, y = vd.wrap(x, y)
+	closest := vd.nearestSites(x, y, 2)
+	if len(closest) == 0 {
+		return math.Inf(1), -1
+	}
+	site = closest[0].i
+	if len(closest) == 1 {
+		return math.Inf(1), site
+	}
+	if _, euclidean := vd.metric().(*EuclideanMetric); !euclidean {
+		return (closest[1].dist - closest[0].dist) / 2, site
+	}
+	p := Vec2f{x, y}
+	a := vd.wrappedPos(p, site)
+	// the second closest site always shares a border, and any site b with a closer bisector has |p-b| - |p-a| < 2*dist
+	dist = bisectorDistance(p, a, vd.wrappedPos(p, closest[1].i))
+	radius := closest[0].dist + 2*dist
+	if tree := vd.siteTree(); tree != nil {
+		for _, item := range tree.QueryRadiusItems(p, radius) {
+			dist = math.Min(dist, bisectorDistance(p, a, item.Pos))
+		}
+		return dist, site
 	}
-	var distances []distIndex
+	for i := range vd.Points {
+		if b := vd.wrappedPos(p, i); math.Hypot(p.X-b.X, p.Y-b.Y) <= radius {
+			dist = math.Min(dist, bisectorDistance(p, a, b))
+		}
+	}
+	return dist, site
+}
+
+// bisectorDistance returns the distance from p to the perpendicular bisector between the sites a and b, where p is closer to a
+// returns +Inf if both sites are at the same position
+func bisectorDistance(p Vec2f, a Vec2f, b Vec2f) float64 {
+	ab := math.Hypot(b.X-a.X, b.Y-a.Y)
+	if ab == 0 {
+		return math.Inf(1)
+	}
+	pa2 := (p.X-a.X)*(p.X-a.X) + (p.Y-a.Y)*(p.Y-a.Y)
+	pb2 := (p.X-b.X)*(p.X-b.X) + (p.Y-b.Y)*(p.Y-b.Y)
+	return (pb2 - pa2) / (2 * ab)
+}
+
+// RebuildIndex discards the site index, it is rebuilt on the next lookup
+// lookups also rebuild the index on their own once they find Points modified, so calling this is only needed to enable the index on diagrams that were not created by a constructor
+func (vd *VoronoiDiagram2D) RebuildIndex() {
+	if vd.index == nil {
+		vd.index = &voronoiSiteIndex{}
+		return
+	}
+	vd.index.mu.Lock()
+	vd.index.tree = nil
+	vd.index.mu.Unlock()
+}
+
+// siteTree returns the QuadTree of the sites, building it if it is missing or stale, or nil if the diagram was not created by a constructor
+// comparing the sites against the copy of the index costs a pass over Points, which is still much cheaper than measuring the distance to every site
+func (vd *VoronoiDiagram2D) siteTree() *QuadTree {
+	if vd.index == nil {
+		return nil
+	}
+	vd.index.mu.RLock()
+	tree, current := vd.index.tree, vd.index.current(vd)
+	vd.index.mu.RUnlock()
+	if current {
+		return tree
+	}
+	vd.index.mu.Lock()
+	defer vd.index.mu.Unlock()
+	if !vd.index.current(vd) {
+		vd.index.tree = vd.buildSiteTree()
+		vd.index.points = append(vd.index.points[:0], vd.Points...)
+		vd.index.tileable, vd.index.w, vd.index.h = vd.Tileable, vd.W, vd.H
+	}
+	return vd.index.tree
+}
+
+// buildSiteTree returns a new QuadTree of all sites, and their wrapped copies if the diagram is tileable
+func (vd *VoronoiDiagram2D) buildSiteTree() *QuadTree {
+	items := make([]QuadTreeItem, 0, len(vd.Points))
+	for i, p := range vd.Points {
+		if !vd.Tileable {
+			items = append(items, QuadTreeItem{Pos: p, ID: i})
+			continue
+		}
+		for ox := -1.0; ox <= 1; ox++ {
+			for oy := -1.0; oy <= 1; oy++ {
+				items = append(items, QuadTreeItem{Pos: Vec2f{p.X + ox*vd.W, p.Y + oy*vd.H}, ID: i})
+			}
+		}
+	}
+	bounds := Rect2f{vd.X, vd.Y, vd.W, vd.H}
+	if len(items) > 0 {
+		bounds = Polygon2f{itemPositions(items)}.Bounds()
+	}
+	// the quadrants of the tree are split from its bounds with rounding, a margin keeps the outermost sites inside of every quadrant containing them
+	margin := 1e-9 * (math.Abs(bounds.X) + math.Abs(bounds.Y) + bounds.W + bounds.H + 1)
+	tree := NewQuadTree(bounds.X-margin, bounds.Y-margin, bounds.W+2*margin, bounds.H+2*margin)
+	tree.RebuildItems(items)
+	return tree
+}
+
+// nearestSites returns the n sites closest to the position by the metric, sorted by ascending distance
+// uses the site index if available and the metric can bound the euclidean distances, otherwise keeps the n closest while scanning all sites
+func (vd *VoronoiDiagram2D) nearestSites(x float64, y float64, n int) []voronoiSite {
+	bounder, bounded := vd.metric().(euclideanBounder)
+	if tree := vd.siteTree(); tree != nil && bounded {
+		p := Vec2f{x, y}
+		sites := vd.closestItems(p, tree.QueryKNNItems(p, n), n)
+		if len(sites) == n {
+			if _, euclidean := vd.metric().(*EuclideanMetric); !euclidean {
+				// the euclidean nearest items are only candidates, every site closer by the metric lies within the bound of the n-th distance
+				sites = vd.closestItems(p, tree.QueryRadiusItems(p, bounder.euclideanBound(sites[n-1].dist)), n)
+			}
+			return sites
+		}
+	}
+	sites := make([]voronoiSite, 0, n+1)
 	for i, p := range vd.Points {
-		distances = append(distances, distIndex{i, math.Hypot(x-p.X, y-p.Y)})
-	}
-	// sort distances
-	sort.Slice(distances, func(i, j int) bool {
-		return distances[i].dist < distances[j].dist
-	})
-	k := vd.K
-	var borderDist float64 = distances[k+1].dist
-	var targetDist float64 = 0
-	if k == -1 { // if crackle
-		k = 0
-		borderDist = (distances[0].dist + distances[1].dist) / 2
-		targetDist = distances[1].dist - distances[0].dist
-	}
-	targetDist = distances[k].dist
-	// return k nearest
-	return 1 - ScaleF2F(targetDist, 0, borderDist+1, 0, 1)
+		d := vd.dist(x, y, p)
+		if len(sites) == n && d >= sites[n-1].dist {
+			continue
+		}
+		// insertion into the short sorted list
+		j := len(sites)
+		if j < n {
+			sites = append(sites, voronoiSite{})
+		} else {
+			j--
+		}
+		for ; j > 0 && sites[j-1].dist > d; j-- {
+			sites[j] = sites[j-1]
+		}
+		sites[j] = voronoiSite{i, d}
+	}
+	return sites
+}
+
+// closestItems returns the n closest distinct sites among the items of the site index by the metric, sorted by ascending distance
+// only the closest of the wrapped copies of a site in a tileable diagram is kept
+func (vd *VoronoiDiagram2D) closestItems(p Vec2f, items []QuadTreeItem, n int) []voronoiSite {
+	metric := vd.metric()
+	sites := make([]voronoiSite, 0, len(items))
+	for _, item := range items {
+		d := metric.Distance(p.X-item.Pos.X, p.Y-item.Pos.Y)
+		duplicate := false
+		for j := range sites {
+			if sites[j].i == item.ID {
+				sites[j].dist = math.Min(sites[j].dist, d)
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			sites = append(sites, voronoiSite{item.ID, d})
+		}
+	}
+	sort.Slice(sites, func(a, b int) bool { return sites[a].dist < sites[b].dist })
+	if len(sites) > n {
+		sites = sites[:n]
+	}
+	return sites
 }
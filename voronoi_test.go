@@ -0,0 +1,411 @@
+package gah
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// bruteVoronoiSite is a site with its distance, found by bruteVoronoiSites
+type bruteVoronoiSite struct {
+	i    int
+	dist float64
+}
+
+// bruteVoronoiSites returns all sites of the diagram sorted by their distance to the position, without using the site index
+// tileable diagrams take the closest of the site and its 8 wrapped copies
+func bruteVoronoiSites(vd *VoronoiDiagram2D, metric DistanceMetric, x float64, y float64) []bruteVoronoiSite {
+	sites := make([]bruteVoronoiSite, len(vd.Points))
+	for i, p := range vd.Points {
+		sites[i] = bruteVoronoiSite{i, math.Inf(1)}
+		for ox := -1.0; ox <= 1; ox++ {
+			for oy := -1.0; oy <= 1; oy++ {
+				if !vd.Tileable && (ox != 0 || oy != 0) {
+					continue
+				}
+				sites[i].dist = math.Min(sites[i].dist, metric.Distance(x-p.X-ox*vd.W, y-p.Y-oy*vd.H))
+			}
+		}
+	}
+	sort.Slice(sites, func(a, b int) bool { return sites[a].dist < sites[b].dist })
+	return sites
+}
+
+// bruteVoronoiEval returns what Eval2Site should return for a position inside of the area, computed from bruteVoronoiSites
+func bruteVoronoiEval(vd *VoronoiDiagram2D, metric DistanceMetric, x float64, y float64) (float64, int) {
+	sites := bruteVoronoiSites(vd, metric, x, y)
+	if len(sites) == 0 {
+		return 0, -1
+	}
+	if vd.Output == VoronoiOutputCellValue {
+		return vd.CellValue(sites[0].i), sites[0].i
+	}
+	extent := metric.Distance(vd.W, vd.H)
+	if vd.Tileable {
+		extent = metric.Distance(vd.W/2, vd.H/2)
+	}
+	// missing sites are infinitely far away as targets, and as far away as the extent of the area for scaling
+	target := func(i int) float64 {
+		if i < len(sites) {
+			return sites[i].dist
+		}
+		return math.Inf(1)
+	}
+	border := func(i int) float64 {
+		if i < len(sites) {
+			return sites[i].dist
+		}
+		return extent
+	}
+	value := func(t float64, b float64) float64 {
+		if math.IsInf(t, 1) {
+			return 0
+		}
+		return Clamp(1-t/(b+1), 0, 1)
+	}
+	k := vd.K
+	switch {
+	case vd.Mode == VoronoiF1:
+		return value(target(0), border(1)), sites[0].i
+	case vd.Mode == VoronoiF2:
+		return value(target(1), border(2)), sites[0].i
+	case vd.Mode == VoronoiF2MinusF1:
+		return value(target(1)-target(0), border(2)), sites[0].i
+	case vd.Mode == VoronoiF1TimesF2:
+		return value(target(0)*target(1), border(2)*border(2)), sites[0].i
+	case vd.Mode == VoronoiBorder || k < 0:
+		d := bruteBorderDistance(vd, metric, x, y, sites)
+		return value(d, d+sites[0].dist), sites[0].i
+	}
+	return value(target(k), border(k+1)), sites[0].i
+}
+
+// bruteBorderDistance returns what BorderDistance should return as distance, given the sorted sites of bruteVoronoiSites
+// for the euclidean metric it is the smallest distance to the bisectors with all other sites and their wrapped copies
+func bruteBorderDistance(vd *VoronoiDiagram2D, metric DistanceMetric, x float64, y float64, sites []bruteVoronoiSite) float64 {
+	if len(sites) < 2 {
+		return math.Inf(1)
+	}
+	if _, euclidean := metric.(*EuclideanMetric); !euclidean {
+		return (sites[1].dist - sites[0].dist) / 2
+	}
+	p := Vec2f{x, y}
+	a := vd.Points[sites[0].i]
+	if vd.Tileable {
+		a.X += math.Round((x-a.X)/vd.W) * vd.W
+		a.Y += math.Round((y-a.Y)/vd.H) * vd.H
+	}
+	dist := math.Inf(1)
+	for _, b := range vd.Points {
+		for ox := -1.0; ox <= 1; ox++ {
+			for oy := -1.0; oy <= 1; oy++ {
+				q := Vec2f{b.X + ox*vd.W, b.Y + oy*vd.H}
+				if (!vd.Tileable && (ox != 0 || oy != 0)) || q == a {
+					continue
+				}
+				// distance from p to the perpendicular bisector of a and q
+				nx, ny := q.X-a.X, q.Y-a.Y
+				mx, my := (a.X+q.X)/2, (a.Y+q.Y)/2
+				dist = math.Min(dist, ((mx-p.X)*nx+(my-p.Y)*ny)/math.Hypot(nx, ny))
+			}
+		}
+	}
+	return dist
+}
+
+// checkVoronoiBruteForce compares Eval2 and Eval2Site against bruteVoronoiEval on a grid over the area of the diagram
+func checkVoronoiBruteForce(t *testing.T, name string, vd *VoronoiDiagram2D) {
+	t.Helper()
+	for x := vd.X; x < vd.X+vd.W; x += vd.W / 37 {
+		for y := vd.Y; y < vd.Y+vd.H; y += vd.H / 29 {
+			want, wantSite := bruteVoronoiEval(vd, vd.metric(), x, y)
+			if got, site := vd.Eval2Site(x, y); math.Abs(got-want) > 1e-9 || site != wantSite {
+				t.Fatalf("%s: Eval2Site(%v, %v) = %v, %d, want %v, %d", name, x, y, got, site, want, wantSite)
+			}
+			if got := vd.Eval2(x, y); math.Abs(got-want) > 1e-9 {
+				t.Fatalf("%s: Eval2(%v, %v) = %v, want %v", name, x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestVoronoiEvalBruteForce(t *testing.T) {
+	for _, k := range []int{-1, 0, 1, 3} {
+		checkVoronoiBruteForce(t, "plain", NewVoronoiDiagram2D(1, 20, 10, 300, 200, 25, k, 30))
+		checkVoronoiBruteForce(t, "tileable", NewTileableVoronoiDiagram2D(1, 20, 10, 300, 200, 25, k, 30))
+	}
+}
+
+func TestVoronoiModesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomSites := make([]Vec2f, 40)
+	for i := range randomSites {
+		randomSites[i] = Vec2f{rng.Float64() * 100, rng.Float64() * 100}
+	}
+	siteSets := []struct {
+		name  string
+		sites []Vec2f
+	}{
+		{"no sites", []Vec2f{}},
+		{"one site", []Vec2f{{30, 40}}},
+		{"two sites", []Vec2f{{30, 40}, {70, 55}}},
+		{"random sites", randomSites},
+	}
+	metrics := []struct {
+		name   string
+		metric DistanceMetric
+	}{
+		{"euclidean", &EuclideanMetric{}},
+		{"manhattan", &ManhattanMetric{}},
+		{"chebyshev", &ChebyshevMetric{}},
+		{"minkowski 3", &MinkowskiMetric{3}},
+		{"minkowski 0.5", &MinkowskiMetric{0.5}},
+		{"custom", &CustomMetric{"hypot", math.Hypot}}, // no euclidean bound, so lookups scan all sites
+	}
+	modes := []struct {
+		name   string
+		mode   VoronoiMode
+		k      int
+		output VoronoiOutput
+	}{
+		{"k nearest 0", VoronoiKNearest, 0, VoronoiOutputDistance},
+		{"k nearest 2", VoronoiKNearest, 2, VoronoiOutputDistance},
+		{"k nearest beyond the sites", VoronoiKNearest, 45, VoronoiOutputDistance},
+		{"crackle", VoronoiKNearest, -1, VoronoiOutputDistance},
+		{"crackle negative", VoronoiKNearest, -3, VoronoiOutputDistance},
+		{"f1", VoronoiF1, 0, VoronoiOutputDistance},
+		{"f2", VoronoiF2, 0, VoronoiOutputDistance},
+		{"f2 minus f1", VoronoiF2MinusF1, 0, VoronoiOutputDistance},
+		{"f1 times f2", VoronoiF1TimesF2, 0, VoronoiOutputDistance},
+		{"border", VoronoiBorder, 0, VoronoiOutputDistance},
+		{"cell value", VoronoiKNearest, 0, VoronoiOutputCellValue},
+	}
+	for _, set := range siteSets {
+		for _, m := range metrics {
+			for _, mode := range modes {
+				for _, tileable := range []bool{false, true} {
+					name := set.name + "/" + m.name + "/" + mode.name
+					if tileable {
+						name += "/tileable"
+					}
+					t.Run(name, func(t *testing.T) {
+						vd := NewVoronoiDiagram2D(1, 0, 0, 100, 100, 10, mode.k, 1)
+						if tileable {
+							vd = NewTileableVoronoiDiagram2D(1, 0, 0, 100, 100, 10, mode.k, 1)
+						}
+						vd.Points = set.sites
+						vd.Metric, vd.Mode, vd.Output = m.metric, mode.mode, mode.output
+						vd.RebuildIndex()
+						prng := rand.New(rand.NewSource(2))
+						for i := 0; i < 200; i++ {
+							x, y := prng.Float64()*100, prng.Float64()*100
+							v, site := vd.Eval2Site(x, y)
+							wantV, wantSite := bruteVoronoiEval(vd, m.metric, x, y)
+							if math.Abs(v-wantV) > 1e-9 || site != wantSite {
+								t.Fatalf("Eval2Site(%v, %v) = %v, %d, want %v, %d", x, y, v, site, wantV, wantSite)
+							}
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestVoronoiOutOfBounds(t *testing.T) {
+	vd := NewVoronoiDiagram2D(1, 0, 0, 100, 100, 10, 0, 1)
+	for _, p := range []Vec2f{{-1, 50}, {50, -1}, {100, 50}, {50, 100}} {
+		if v, site := vd.Eval2Site(p.X, p.Y); v != 0 || site != -1 {
+			t.Errorf("Eval2Site(%v, %v) = %v, %d, want 0, -1", p.X, p.Y, v, site)
+		}
+	}
+	vd = NewTileableVoronoiDiagram2D(1, 0, 0, 100, 100, 10, 0, 1)
+	for _, p := range []Vec2f{{-1, 50}, {50, -1}, {100, 50}, {250, 350}} {
+		v, site := vd.Eval2Site(p.X, p.Y)
+		wantV, wantSite := vd.Eval2Site(vd.wrap(p.X, p.Y))
+		if v != wantV || site != wantSite {
+			t.Errorf("Eval2Site(%v, %v) = %v, %d, want the wrapped %v, %d", p.X, p.Y, v, site, wantV, wantSite)
+		}
+	}
+}
+
+func TestVoronoiBorderDistance(t *testing.T) {
+	tests := []struct {
+		name     string
+		sites    []Vec2f
+		tileable bool
+		metric   DistanceMetric
+		p        Vec2f
+		dist     float64
+		site     int
+	}{
+		{"no sites", []Vec2f{}, false, nil, Vec2f{50, 50}, math.Inf(1), -1},
+		{"one site", []Vec2f{{25, 50}}, false, nil, Vec2f{50, 50}, math.Inf(1), 0},
+		{"on the line between", []Vec2f{{25, 50}, {75, 50}}, false, nil, Vec2f{40, 50}, 10, 0},
+		{"beside the line between", []Vec2f{{25, 50}, {75, 50}}, false, nil, Vec2f{60, 20}, 10, 1},
+		{"diagonal", []Vec2f{{0, 0}, {60, 80}}, false, nil, Vec2f{0, 0}, 50, 0},
+		{"manhattan on the line between", []Vec2f{{25, 50}, {75, 50}}, false, &ManhattanMetric{}, Vec2f{40, 50}, 10, 0},
+		{"manhattan beside the line between", []Vec2f{{25, 50}, {75, 50}}, false, &ManhattanMetric{}, Vec2f{40, 60}, 10, 0},
+		{"tileable across the wrap", []Vec2f{{10, 50}, {60, 50}}, true, nil, Vec2f{90, 50}, 5, 0},
+		{"tileable one site", []Vec2f{{10, 50}}, true, nil, Vec2f{90, 50}, math.Inf(1), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vd := NewVoronoiDiagram2D(1, 0, 0, 100, 100, 10, -1, 1)
+			if tt.tileable {
+				vd = NewTileableVoronoiDiagram2D(1, 0, 0, 100, 100, 10, -1, 1)
+			}
+			vd.Points = tt.sites
+			vd.Metric = tt.metric
+			vd.RebuildIndex()
+			dist, site := vd.BorderDistance(tt.p.X, tt.p.Y)
+			if math.Abs(dist-tt.dist) > 1e-9 && !(math.IsInf(dist, 1) && math.IsInf(tt.dist, 1)) || site != tt.site {
+				t.Errorf("BorderDistance(%v, %v) = %v, %d, want %v, %d", tt.p.X, tt.p.Y, dist, site, tt.dist, tt.site)
+			}
+		})
+	}
+}
+
+func TestVoronoiCellValue(t *testing.T) {
+	vd := NewVoronoiDiagram2D(6, 0, 0, 200, 200, 20, 0, 30)
+	vd.Output = VoronoiOutputCellValue
+	values := map[float64]bool{}
+	for i := range vd.Points {
+		v := vd.CellValue(i)
+		if v < 0 || v > 1 {
+			t.Fatalf("CellValue(%d) = %v, want a value in [0, 1]", i, v)
+		}
+		values[v] = true
+	}
+	if len(values) < len(vd.Points)*9/10 {
+		t.Errorf("only %d distinct cell values for %d sites", len(values), len(vd.Points))
+	}
+	// every site evaluates to its own cell value
+	for i, p := range vd.Points {
+		if p.X < 0 || p.X >= 200 || p.Y < 0 || p.Y >= 200 {
+			continue
+		}
+		if v, site := vd.Eval2Site(p.X, p.Y); site != i || v != vd.CellValue(i) {
+			t.Fatalf("Eval2Site at site %d = %v, %d, want %v, %d", i, v, site, vd.CellValue(i), i)
+		}
+	}
+	if other := NewVoronoiDiagram2D(7, 0, 0, 200, 200, 20, 0, 30); other.CellValue(3) == vd.CellValue(3) {
+		t.Errorf("CellValue does not depend on the seed")
+	}
+	if v, site := vd.Eval2Site(-1, 5); v != 0 || site != -1 {
+		t.Errorf("Eval2Site out of bounds = %v, %d, want 0, -1", v, site)
+	}
+}
+
+func TestVoronoiSignature(t *testing.T) {
+	base := func() *VoronoiDiagram2D { return NewVoronoiDiagram2D(1, 0, 0, 100, 100, 10, 0, 30) }
+	variants := []func(vd *VoronoiDiagram2D){
+		func(vd *VoronoiDiagram2D) {},
+		func(vd *VoronoiDiagram2D) { vd.Metric = &ManhattanMetric{} },
+		func(vd *VoronoiDiagram2D) { vd.Metric = &MinkowskiMetric{3} },
+		func(vd *VoronoiDiagram2D) { vd.Metric = &MinkowskiMetric{4} },
+		func(vd *VoronoiDiagram2D) { vd.Mode = VoronoiF2MinusF1 },
+		func(vd *VoronoiDiagram2D) { vd.Mode = VoronoiF1TimesF2 },
+		func(vd *VoronoiDiagram2D) { vd.Output = VoronoiOutputCellValue },
+	}
+	seen := map[string]int{}
+	for i, variant := range variants {
+		vd := base()
+		variant(vd)
+		sig := string(vd.GetParamSignature())
+		if j, ok := seen[sig]; ok {
+			t.Errorf("variants %d and %d share a signature", j, i)
+		}
+		seen[sig] = i
+	}
+	// a nil metric is the euclidean metric
+	vd := base()
+	vd.Metric = &EuclideanMetric{}
+	if string(vd.GetParamSignature()) != string(base().GetParamSignature()) {
+		t.Errorf("explicit euclidean metric changes the signature")
+	}
+}
+
+func TestVoronoiUnindexed(t *testing.T) {
+	// diagrams that were not created by a constructor have no index and scan all sites
+	rng := rand.New(rand.NewSource(2))
+	vd := &VoronoiDiagram2D{X: 0, Y: 0, W: 100, H: 100, K: 1}
+	for i := 0; i < 30; i++ {
+		vd.Points = append(vd.Points, Vec2f{rng.Float64() * 100, rng.Float64() * 100})
+	}
+	checkVoronoiBruteForce(t, "literal", vd)
+	vd.Tileable = true
+	checkVoronoiBruteForce(t, "tileable literal", vd)
+}
+
+func TestVoronoiRebuildIndex(t *testing.T) {
+	vd := NewVoronoiDiagram2D(3, 0, 0, 200, 200, 20, 0, 30)
+	checkVoronoiBruteForce(t, "before", vd)
+	for i := range vd.Points {
+		vd.Points[i].X += 7
+	}
+	vd.Points = vd.Points[:len(vd.Points)/2]
+	vd.RebuildIndex()
+	checkVoronoiBruteForce(t, "after", vd)
+}
+
+func TestVoronoiModifiedPoints(t *testing.T) {
+	// every modification is followed by lookups without calling RebuildIndex, which have to notice the stale index on their own
+	for _, tileable := range []bool{false, true} {
+		for _, k := range []int{-1, 0, 2} {
+			vd := NewVoronoiDiagram2D(9, 0, 0, 200, 150, 15, k, 30)
+			vd.Tileable = tileable
+			name := fmt.Sprintf("k %d tileable %v", k, tileable)
+			checkVoronoiBruteForce(t, name+": initial", vd)
+			// a single site moved in place, right next to the positions it did not use to be closest to
+			vd.Points[len(vd.Points)/2] = Vec2f{101, 74}
+			checkVoronoiBruteForce(t, name+": moved", vd)
+			vd.Points = append(vd.Points, Vec2f{30, 30}, Vec2f{170, 120})
+			checkVoronoiBruteForce(t, name+": appended", vd)
+			// shrunk below the ids of most sites in the index
+			vd.Points = vd.Points[:5]
+			checkVoronoiBruteForce(t, name+": shrunk", vd)
+			vd.Points = []Vec2f{{50, 50}}
+			checkVoronoiBruteForce(t, name+": one site", vd)
+			vd.Points = nil
+			checkVoronoiBruteForce(t, name+": no sites", vd)
+		}
+	}
+}
+
+func TestVoronoiIndexBounds(t *testing.T) {
+	// the outermost sites of these diagrams used to fall out of the rounded quadrants of the index
+	for _, k := range []int{-1, 0, 2} {
+		checkVoronoiBruteForce(t, "plain", NewVoronoiDiagram2D(5, 20, 10, 300, 200, 25, k, 30))
+		checkVoronoiBruteForce(t, "tileable", NewTileableVoronoiDiagram2D(5, 20, 10, 300, 200, 25, k, 30))
+	}
+}
+
+func TestVoronoiIndexFollowsTileable(t *testing.T) {
+	vd := NewVoronoiDiagram2D(8, 0, 0, 150, 100, 12, 0, 30)
+	checkVoronoiBruteForce(t, "plain", vd)
+	// the wrapped copies have to be added to the index, and removed again, without calling RebuildIndex
+	vd.Tileable = true
+	checkVoronoiBruteForce(t, "made tileable", vd)
+	vd.W, vd.H = 120, 90
+	checkVoronoiBruteForce(t, "new period", vd)
+	vd.Tileable = false
+	checkVoronoiBruteForce(t, "plain again", vd)
+}
+
+func TestVoronoiConcurrentEval(t *testing.T) {
+	vd := NewVoronoiDiagram2D(4, 0, 0, 100, 100, 10, 1, 30)
+	want := make([]float64, 100*100)
+	EvalGrid2(vd, want, 0, 0, 1, 1, 100, 100)
+	// the index is built lazily by whichever goroutine gets there first
+	vd.RebuildIndex()
+	got := make([]float64, 100*100)
+	EvalGrid2Parallel(vd, got, 0, 0, 1, 1, 100, 100, 8)
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("pixel %d is %v evaluated concurrently, want %v", i, got[i], want[i])
+		}
+	}
+}
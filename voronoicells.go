@@ -0,0 +1,188 @@
+package gah
+
+import "math"
+
+// VoronoiCell is the region closest to one site of a VoronoiDiagram2D
+type VoronoiCell struct {
+	Site      int       // index of the site in Points
+	Polygon   Polygon2f // convex outline of the cell, empty if the cell does not reach into the area
+	Neighbors []int     // sites whose cells share an edge with this one, in the order of the edges
+}
+
+// cellVertex is a corner of a cell during clipping, with the site whose bisector forms the edge starting at it, -1 for the border of the area
+type cellVertex struct {
+	p    Vec2f
+	edge int
+}
+
+// clipCell cuts away the part of the convex cell polygon that is closer to site b than to site a
+// the new edge along the bisector is labeled with label
+func clipCell(poly []cellVertex, a Vec2f, b Vec2f, label int) []cellVertex {
+	mid := Vec2f{(a.X + b.X) / 2, (a.Y + b.Y) / 2}
+	nx, ny := b.X-a.X, b.Y-a.Y
+	side := func(q Vec2f) float64 {
+		return (q.X-mid.X)*nx + (q.Y-mid.Y)*ny
+	}
+	var clipped []cellVertex
+	for i, cur := range poly {
+		next := poly[(i+1)%len(poly)]
+		sc, sn := side(cur.p), side(next.p)
+		if sc <= 0 {
+			clipped = append(clipped, cur)
+		}
+		if (sc <= 0) != (sn <= 0) {
+			t := sc / (sc - sn)
+			cut := Vec2f{cur.p.X + t*(next.p.X-cur.p.X), cur.p.Y + t*(next.p.Y-cur.p.Y)}
+			if sc <= 0 {
+				clipped = append(clipped, cellVertex{cut, label}) // leaving, the bisector continues from here
+			} else {
+				clipped = append(clipped, cellVertex{cut, cur.edge}) // entering, the rest of the old edge continues from here
+			}
+		}
+	}
+	return clipped
+}
+
+// voronoiCell returns the cell of site i within the given convex area, sites are the positions of all sites and candidates the possible neighbors of i
+// labels maps the indices of sites to the site indices reported as neighbors
+func voronoiCell(i int, sites []Vec2f, candidates []int, area Rect2f, labels func(j int) int) VoronoiCell {
+	poly := []cellVertex{
+		{Vec2f{area.X, area.Y}, -1},
+		{Vec2f{area.X + area.W, area.Y}, -1},
+		{Vec2f{area.X + area.W, area.Y + area.H}, -1},
+		{Vec2f{area.X, area.Y + area.H}, -1},
+	}
+	for _, j := range candidates {
+		if sites[j] == sites[i] {
+			continue
+		}
+		poly = clipCell(poly, sites[i], sites[j], j)
+		if len(poly) == 0 {
+			break
+		}
+	}
+	cell := VoronoiCell{labels(i), Polygon2f{make([]Vec2f, len(poly))}, []int{}}
+	seen := map[int]bool{}
+	for k, v := range poly {
+		cell.Polygon.Points[k] = v.p
+		next := poly[(k+1)%len(poly)].p
+		if v.edge < 0 || v.p == next {
+			continue
+		}
+		if n := labels(v.edge); n != cell.Site && !seen[n] {
+			seen[n] = true
+			cell.Neighbors = append(cell.Neighbors, n)
+		}
+	}
+	return cell
+}
+
+// delaunayNeighbors returns the neighbors of every point in the triangulation of n points
+// points without any triangle, e.g. if all points are collinear, get all other points as neighbors
+func delaunayNeighbors(n int, tris []DelaunayTriangle) [][]int {
+	neighbors := make([][]int, n)
+	seen := make([]map[int]bool, n)
+	link := func(a int, b int) {
+		if seen[a] == nil {
+			seen[a] = map[int]bool{}
+		}
+		if !seen[a][b] {
+			seen[a][b] = true
+			neighbors[a] = append(neighbors[a], b)
+		}
+	}
+	for _, t := range tris {
+		link(t.A, t.B)
+		link(t.B, t.A)
+		link(t.B, t.C)
+		link(t.C, t.B)
+		link(t.C, t.A)
+		link(t.A, t.C)
+	}
+	for i := range neighbors {
+		if len(neighbors[i]) == 0 {
+			for j := 0; j < n; j++ {
+				if j != i {
+					neighbors[i] = append(neighbors[i], j)
+				}
+			}
+		}
+	}
+	return neighbors
+}
+
+// tileSites returns the sites of a tileable diagram together with their copies wrapped into a margin around the area,
+// and the index of the original site for every entry, the first len(Points) entries are the originals
+func (vd *VoronoiDiagram2D) tileSites(margin float64) (sites []Vec2f, origin []int) {
+	sites = append(sites, vd.Points...)
+	for i := range vd.Points {
+		origin = append(origin, i)
+	}
+	outer := Rect2f{vd.X - margin, vd.Y - margin, vd.W + 2*margin, vd.H + 2*margin}
+	for i, p := range vd.Points {
+		for ox := -1.0; ox <= 1; ox++ {
+			for oy := -1.0; oy <= 1; oy++ {
+				c := Vec2f{p.X + ox*vd.W, p.Y + oy*vd.H}
+				if (ox != 0 || oy != 0) && outer.ContainsPoint(c) {
+					sites = append(sites, c)
+					origin = append(origin, i)
+				}
+			}
+		}
+	}
+	return sites, origin
+}
+
+// tileMargin is how far wrapped copies of the sites of a tileable diagram reach beyond its area when building cells
+// cells stay within half the area of their site, but their neighbors can sit anywhere in the adjacent tiles
+// whatever the spacing of the sites, so all 8 wrapped copies of every site are used
+func (vd *VoronoiDiagram2D) tileMargin() float64 {
+	return math.Max(vd.W, vd.H)
+}
+
+// Delaunay returns the Delaunay triangulation of the sites, the corners are indices into Points
+// the triangulation does not wrap around tileable diagrams
+func (vd *VoronoiDiagram2D) Delaunay() []DelaunayTriangle {
+	return Delaunay2D(vd.Points)
+}
+
+// Cells returns the cell of every site in order of Points, clipped to the area of the diagram
+// cells of tileable diagrams are not clipped, they reach across the border of the area so that drawing them wrapped tiles seamlessly
+func (vd *VoronoiDiagram2D) Cells() []VoronoiCell {
+	area := Rect2f{vd.X, vd.Y, vd.W, vd.H}
+	sites, origin := vd.Points, []int(nil)
+	if vd.Tileable {
+		margin := vd.tileMargin()
+		sites, origin = vd.tileSites(margin)
+		area = Rect2f{vd.X - margin, vd.Y - margin, vd.W + 2*margin, vd.H + 2*margin}
+	}
+	labels := func(j int) int {
+		if origin == nil {
+			return j
+		}
+		return origin[j]
+	}
+	neighbors := delaunayNeighbors(len(sites), Delaunay2D(sites))
+	cells := make([]VoronoiCell, len(vd.Points))
+	for i := range cells {
+		cells[i] = voronoiCell(i, sites, neighbors[i], area, labels)
+	}
+	return cells
+}
+
+// Relax moves every site to the centroid of its cell, repeated for the given number of iterations (Lloyd's algorithm)
+// this evens out the spacing of the sites, sites whose cells do not reach into the area stay where they are
+// sites of tileable diagrams are wrapped back into the area, the site index is rebuilt afterwards
+func (vd *VoronoiDiagram2D) Relax(iterations int) {
+	for it := 0; it < iterations; it++ {
+		for _, cell := range vd.Cells() {
+			if cell.Polygon.Area() == 0 {
+				continue
+			}
+			c := cell.Polygon.Centroid()
+			c.X, c.Y = vd.wrap(c.X, c.Y)
+			vd.Points[cell.Site] = c
+		}
+	}
+	vd.RebuildIndex()
+}
@@ -0,0 +1,165 @@
+package gah
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// checkVoronoiCells checks that the cells tile the area, that every cell vertex is at least as close to its own site as to any other,
+// that cells contain the positions closest to their site and that the adjacency is symmetric
+func checkVoronoiCells(t *testing.T, name string, vd *VoronoiDiagram2D, cells []VoronoiCell) {
+	t.Helper()
+	if len(cells) != len(vd.Points) {
+		t.Fatalf("%s: got %d cells for %d sites", name, len(cells), len(vd.Points))
+	}
+	area := 0.0
+	for i, cell := range cells {
+		if cell.Site != i {
+			t.Fatalf("%s: cell %d belongs to site %d", name, i, cell.Site)
+		}
+		area += polygonArea(cell.Polygon.Points)
+		own := vd.Points[i]
+		for _, v := range cell.Polygon.Points {
+			d := vd.dist(v.X, v.Y, own)
+			for j, other := range vd.Points {
+				if j != i && vd.dist(v.X, v.Y, other) < d-1e-6 {
+					t.Fatalf("%s: vertex %v of cell %d is closer to site %d", name, v, i, j)
+				}
+			}
+		}
+		for _, n := range cell.Neighbors {
+			found := false
+			for _, back := range cells[n].Neighbors {
+				found = found || back == i
+			}
+			if !found {
+				t.Fatalf("%s: cell %d has neighbor %d, but not the other way around", name, i, n)
+			}
+		}
+	}
+	if want := vd.W * vd.H; math.Abs(area-want) > 1e-6*want {
+		t.Fatalf("%s: cells cover an area of %v, want %v", name, area, want)
+	}
+	for x := vd.X + 0.5; x < vd.X+vd.W; x += vd.W / 23 {
+		for y := vd.Y + 0.5; y < vd.Y+vd.H; y += vd.H / 19 {
+			nearest, best := -1, math.Inf(1)
+			for i, p := range vd.Points {
+				if d := vd.dist(x, y, p); d < best {
+					nearest, best = i, d
+				}
+			}
+			if !cells[nearest].Polygon.ContainsPoint(Vec2f{x, y}) && !vd.Tileable {
+				t.Fatalf("%s: cell %d does not contain (%v, %v), which is closest to its site", name, nearest, x, y)
+			}
+		}
+	}
+}
+
+func TestVoronoiCells(t *testing.T) {
+	vd := NewVoronoiDiagram2D(5, 10, 20, 300, 200, 25, 0, 30)
+	cells := vd.Cells()
+	checkVoronoiCells(t, "plain", vd, cells)
+	// sites outside of the area have empty cells, sites inside have at least 2 neighbors
+	for i, cell := range cells {
+		inside := Rect2f{vd.X, vd.Y, vd.W, vd.H}.ContainsPoint(vd.Points[i])
+		if inside && (len(cell.Polygon.Points) < 3 || len(cell.Neighbors) < 2) {
+			t.Errorf("cell %d of a site inside of the area has %d corners and %d neighbors", i, len(cell.Polygon.Points), len(cell.Neighbors))
+		}
+	}
+	// the neighbors across the edges are the delaunay neighbors of the site
+	tris := vd.Delaunay()
+	checkDelaunay(t, "diagram", vd.Points, tris)
+	adjacent := map[[2]int]bool{}
+	for _, tri := range tris {
+		adjacent[[2]int{tri.A, tri.B}], adjacent[[2]int{tri.B, tri.C}], adjacent[[2]int{tri.C, tri.A}] = true, true, true
+	}
+	for i, cell := range cells {
+		for _, n := range cell.Neighbors {
+			if !adjacent[[2]int{i, n}] && !adjacent[[2]int{n, i}] {
+				t.Errorf("cells %d and %d share an edge, but their sites are no delaunay neighbors", i, n)
+			}
+		}
+	}
+}
+
+func TestVoronoiCellsTileable(t *testing.T) {
+	vd := NewTileableVoronoiDiagram2D(6, -50, 30, 240, 160, 20, 0, 30)
+	cells := vd.Cells()
+	checkVoronoiCells(t, "tileable", vd, cells)
+	for i, cell := range cells {
+		if len(cell.Polygon.Points) < 3 || !cell.Polygon.ContainsPoint(vd.Points[i]) {
+			t.Errorf("tileable cell %d does not contain its site", i)
+		}
+	}
+}
+
+func TestVoronoiCellsSmall(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []Vec2f
+	}{
+		{"one site", []Vec2f{{50, 50}}},
+		{"two sites", []Vec2f{{20, 50}, {80, 50}}},
+		{"collinear sites", []Vec2f{{10, 10}, {50, 50}, {90, 90}}},
+	}
+	for _, tt := range tests {
+		vd := &VoronoiDiagram2D{X: 0, Y: 0, W: 100, H: 100, Points: tt.points}
+		checkVoronoiCells(t, tt.name, vd, vd.Cells())
+	}
+}
+
+// minSiteSpacing returns the smallest distance between any two sites of the diagram
+func minSiteSpacing(vd *VoronoiDiagram2D) float64 {
+	spacing := math.Inf(1)
+	for i, p := range vd.Points {
+		for _, q := range vd.Points[:i] {
+			spacing = math.Min(spacing, vd.dist(p.X, p.Y, q))
+		}
+	}
+	return spacing
+}
+
+func TestVoronoiCellsTileableClustered(t *testing.T) {
+	// the sites leave gaps much wider than the scale suggests, the cells still tile the area
+	for _, scale := range []float64{0, 5} {
+		points := []Vec2f{{10, 10}, {14, 12}, {12, 17}, {150, 60}}
+		vd := &VoronoiDiagram2D{X: 0, Y: 0, W: 200, H: 80, Scale: scale, Points: points, Tileable: true}
+		checkVoronoiCells(t, "clustered", vd, vd.Cells())
+	}
+	vd := &VoronoiDiagram2D{X: 0, Y: 0, W: 100, H: 100, Points: []Vec2f{{30, 70}}, Tileable: true}
+	checkVoronoiCells(t, "one site", vd, vd.Cells())
+}
+
+func TestVoronoiRelax(t *testing.T) {
+	for _, tileable := range []bool{false, true} {
+		// clustered sites relax towards an even spacing
+		rng := rand.New(rand.NewSource(3))
+		points := make([]Vec2f, 60)
+		for i := range points {
+			points[i] = Vec2f{20 + rng.Float64()*60, 20 + rng.Float64()*40}
+		}
+		vd := &VoronoiDiagram2D{X: 0, Y: 0, W: 200, H: 100, Points: points, Tileable: tileable}
+		before := minSiteSpacing(vd)
+		vd.Relax(30)
+		if after := minSiteSpacing(vd); after < 2*before || after < 8 {
+			t.Errorf("tileable %v: the closest sites are %v apart after relaxing, %v before", tileable, after, before)
+		}
+		for i, p := range vd.Points {
+			if !(Rect2f{vd.X, vd.Y, vd.W, vd.H}).ContainsPoint(p) {
+				t.Errorf("tileable %v: site %d at %v left the area", tileable, i, p)
+			}
+		}
+		// a relaxed diagram is a fixed point of the relaxation, up to the remaining movement
+		cells := vd.Cells()
+		checkVoronoiCells(t, "relaxed", vd, cells)
+		for i, cell := range cells {
+			c := cell.Polygon.Centroid()
+			if d := math.Hypot(c.X-vd.Points[i].X, c.Y-vd.Points[i].Y); d > 2 {
+				t.Errorf("tileable %v: site %d is %v away from its cell centroid", tileable, i, d)
+			}
+		}
+		// the index follows the moved sites
+		checkVoronoiBruteForce(t, "relaxed", vd)
+	}
+}